@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+)
+
+// syncRun is the JSON form of a recorded sync run.
+type syncRun struct {
+	ID               int64      `json:"id"`
+	MappingID        string     `json:"mappingId"`
+	StartedAt        time.Time  `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"`
+	Status           string     `json:"status"`
+	Synced           int64      `json:"synced"`
+	Skipped          int64      `json:"skipped"`
+	Failed           int64      `json:"failed"`
+	Deleted          int64      `json:"deleted"`
+	BytesTransferred int64      `json:"bytesTransferred"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// runHistory implements the history command:
+//
+//	history [-mapping id] [-status s] [-limit n] [-output table|json]
+func runHistory(w io.Writer, db *database.DB, args []string) error {
+	fs := flag.NewFlagSet("history", flag.ContinueOnError)
+	mappingID := fs.String("mapping", "", "Only runs of this mapping ID (default: all mappings)")
+	status := fs.String("status", "", "Only runs with this status: running, success, partial or failed")
+	limit := fs.Int("limit", 20, "Number of most recent runs to show (0 = all)")
+	format := fs.String("output", "table", "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	runs, err := db.ListSyncRuns(*mappingID, *status, *limit)
+	if err != nil {
+		return err
+	}
+	return writeHistory(w, runs, *format)
+}
+
+func writeHistory(w io.Writer, runs []*database.SyncRun, format string) error {
+	switch format {
+	case "json":
+		list := make([]syncRun, 0, len(runs))
+		for _, run := range runs {
+			item := syncRun{
+				ID:               run.ID,
+				MappingID:        run.MappingID,
+				StartedAt:        run.StartedAt,
+				Status:           run.Status,
+				Synced:           run.Synced,
+				Skipped:          run.Skipped,
+				Failed:           run.Failed,
+				Deleted:          run.Deleted,
+				BytesTransferred: run.BytesTransferred,
+				Error:            run.Error,
+			}
+			if !run.FinishedAt.IsZero() {
+				item.FinishedAt = &run.FinishedAt
+			}
+			list = append(list, item)
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "RUN\tMAPPING\tSTARTED\tDURATION\tSTATUS\tSYNCED\tSKIPPED\tFAILED\tDELETED\tBYTES\tERROR")
+		for _, run := range runs {
+			duration := "-"
+			if !run.FinishedAt.IsZero() {
+				duration = run.FinishedAt.Sub(run.StartedAt).Round(time.Second).String()
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%d\t%s\n", run.ID, run.MappingID,
+				run.StartedAt.Local().Format(time.RFC3339), duration, run.Status,
+				run.Synced, run.Skipped, run.Failed, run.Deleted, run.BytesTransferred, run.Error)
+		}
+		fmt.Fprintf(tw, "%d run(s)\n", len(runs))
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
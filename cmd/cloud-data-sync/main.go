@@ -4,6 +4,8 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
+	"io"
 	"log/slog" // Import slog
 	"os"
 	"os/signal"
@@ -20,11 +22,28 @@ func main() {
 	configPath := flag.String("config", "config.json", "Path to the configuration file")
 	generateConfig := flag.Bool("generate-config", false, "Generate a configuration file with default values")
 	runOnce := flag.Bool("once", false, "Run synchronization once and exit")
-	interval := flag.Int("interval", 300, "Interval between synchronizations (in seconds)")
+	interval := flag.Int("interval", 300, "Interval between synchronizations of mappings without a schedule (in seconds)")
+	dryRun := flag.Bool("dry-run", false, "Report planned uploads, skips and deletions without modifying any bucket")
+	outputFormat := flag.String("output", "table", "Output format for --dry-run: table or json")
+	allowMassDelete := flag.Bool("allow-mass-delete", false, "Proceed with deletions even when a mapping's maxDeletes/maxDeletePercent threshold is exceeded")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [command]\n\nCommands:\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "  quarantine list [-mapping id] [-output table|json]   List objects that failed too many times")
+		fmt.Fprintln(flag.CommandLine.Output(), "  quarantine requeue [-mapping id] (-object key|-all)  Retry quarantined objects on the next run")
+		fmt.Fprintln(flag.CommandLine.Output(), "  history [-mapping id] [-status s] [-limit n] [-output table|json]  Show recent sync runs")
+		fmt.Fprintln(flag.CommandLine.Output(), "  status [-output table|json]   Show the schedule, last run and next run of each mapping")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
-	// Setup structured logger
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+	// Setup structured logger. In dry-run mode and for commands stdout carries
+	// the report, so logs go to stderr.
+	logOutput := os.Stdout
+	if *dryRun || flag.NArg() > 0 {
+		logOutput = os.Stderr
+	}
+	logger := slog.New(slog.NewJSONHandler(logOutput, nil))
 	slog.SetDefault(logger) // Set as default for convenience, though explicit passing is better
 
 	if *generateConfig {
@@ -55,6 +74,14 @@ func main() {
 	defer db.Close()
 	logger.Info("Database initialized successfully", "path", cfg.DatabasePath)
 
+	if flag.NArg() > 0 {
+		if err := runCommand(os.Stdout, cfg, db, time.Duration(*interval)*time.Second, flag.Args()); err != nil {
+			logger.Error("Command failed", "command", flag.Arg(0), "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Pass logger to factory (assuming factory might use it later)
 	factory, err := storage.NewFactory(ctx, cfg, logger)
 	if err != nil {
@@ -65,9 +92,19 @@ func main() {
 	logger.Info("Storage providers initialized successfully")
 
 	// Pass logger to synchronizer
-	synchronizer := syncPkg.NewSynchronizer(db, cfg, factory, logger.With("component", "synchronizer")) // Add component context
+	synchronizer := syncPkg.NewSynchronizer(db, cfg, factory, logger.With("component", "synchronizer"), // Add component context
+		syncPkg.WithAllowMassDeletes(*allowMassDelete))
 	logger.Info("Synchronizer initialized successfully")
 
+	if *dryRun {
+		logger.Info("Computing synchronization plan (dry run)...")
+		if err := runPlan(ctx, os.Stdout, synchronizer, cfg.Mappings, *outputFormat); err != nil {
+			logger.Error("Error computing synchronization plan", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	signalCh := make(chan os.Signal, 1)
 	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
 
@@ -82,35 +119,34 @@ func main() {
 		return // Exit after single run
 	}
 
-	logger.Info("Starting continuous synchronization service", "interval_seconds", *interval)
+	schedules, err := mappingSchedules(cfg.Mappings, time.Duration(*interval)*time.Second)
+	if err != nil {
+		logger.Error("Error scheduling mappings", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Starting continuous synchronization service", "mappings", len(schedules), "interval_seconds", *interval)
 
-	ticker := time.NewTicker(time.Duration(*interval) * time.Second)
-	defer ticker.Stop()
+	go func() {
+		sig := <-signalCh
+		logger.Info("Signal received, shutting down...", "signal", sig.String())
+		cancel() // Trigger context cancellation; runs in progress stop and the scheduler returns
+	}()
 
-	logger.Info("Executing initial synchronization...")
-	if err := synchronizer.SyncAll(ctx); err != nil {
-		logger.Error("Error during initial synchronization", "error", err)
-		// Continue running even if initial sync fails
-	} else {
-		logger.Info("Initial synchronization completed")
-	}
+	newScheduler(synchronizer, logger).run(ctx, schedules)
+}
 
-	for {
-		select {
-		case <-ticker.C:
-			logger.Info("Starting synchronization cycle...")
-			if err := synchronizer.SyncAll(ctx); err != nil {
-				logger.Error("Error during synchronization cycle", "error", err)
-			} else {
-				logger.Info("Synchronization cycle completed")
-			}
-
-		case sig := <-signalCh:
-			logger.Info("Signal received, shutting down...", "signal", sig.String())
-			cancel() // Trigger context cancellation
-			// Allow some time for graceful shutdown if needed, though SyncAll should respect context
-			// time.Sleep(1 * time.Second) // Optional grace period
-			return // Exit main loop
-		}
+// runCommand runs a command given after the flags, which only needs the
+// configuration and database rather than the storage providers. interval is
+// the schedule of mappings without one.
+func runCommand(w io.Writer, cfg *config.Config, db *database.DB, interval time.Duration, args []string) error {
+	switch args[0] {
+	case "quarantine":
+		return runQuarantine(w, db, args[1:])
+	case "history":
+		return runHistory(w, db, args[1:])
+	case "status":
+		return runStatus(w, cfg, db, interval, args[1:])
+	default:
+		return fmt.Errorf("unknown command: %s", args[0])
 	}
 }
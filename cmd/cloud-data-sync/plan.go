@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	syncPkg "github.com/DjonatanS/cloud-data-sync/internal/sync"
+)
+
+// runPlan computes the plan of every mapping target and writes it to w in the
+// requested format ("table" or "json").
+func runPlan(ctx context.Context, w io.Writer, synchronizer *syncPkg.Synchronizer, mappings []config.BucketMapping, format string) error {
+	plans := make([]*syncPkg.Plan, 0, len(mappings))
+	for _, mapping := range mappings {
+		mappingPlans, err := synchronizer.Plan(ctx, mapping)
+		if err != nil {
+			return err
+		}
+		plans = append(plans, mappingPlans...)
+	}
+
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plans)
+	case "table":
+		return writePlanTable(w, plans)
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func writePlanTable(w io.Writer, plans []*syncPkg.Plan) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, plan := range plans {
+		if i > 0 {
+			fmt.Fprintln(tw)
+		}
+		fmt.Fprintf(tw, "MAPPING %s\n", plan.MappingID)
+		fmt.Fprintln(tw, "ACTION\tREASON\tSIZE\tOBJECT\tTARGET")
+		for _, item := range plan.Items {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", item.Action, item.Reason, item.Size, item.Object, item.Target)
+		}
+		fmt.Fprintf(tw, "%d to upload (%d bytes), %d to skip, %d to delete\n",
+			plan.Uploads, plan.BytesToTransfer, plan.Skips, plan.Deletes)
+		for _, warning := range plan.Warnings {
+			fmt.Fprintf(tw, "WARNING: %s\n", warning)
+		}
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+)
+
+// quarantinedObject is the JSON form of a quarantined object.
+type quarantinedObject struct {
+	MappingID  string    `json:"mappingId"`
+	Object     string    `json:"object"`
+	Target     string    `json:"target"`
+	Attempts   int       `json:"attempts"`
+	LastError  string    `json:"lastError"`
+	LastSynced time.Time `json:"lastSynced"`
+}
+
+// runQuarantine implements the quarantine command:
+//
+//	quarantine list [-mapping id] [-output table|json]
+//	quarantine requeue [-mapping id] (-object name | -all)
+func runQuarantine(w io.Writer, db *database.DB, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: quarantine list|requeue [flags]")
+	}
+
+	fs := flag.NewFlagSet("quarantine "+args[0], flag.ContinueOnError)
+	mappingID := fs.String("mapping", "", "Only objects of this mapping ID (default: all mappings)")
+
+	switch args[0] {
+	case "list":
+		format := fs.String("output", "table", "Output format: table or json")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		objects, err := db.ListQuarantined(*mappingID)
+		if err != nil {
+			return err
+		}
+		return writeQuarantined(w, objects, *format)
+
+	case "requeue":
+		object := fs.String("object", "", "Source key of the object to requeue")
+		all := fs.Bool("all", false, "Requeue every quarantined object (of -mapping, if set)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if (*object == "") == !*all {
+			return errors.New("requeue needs exactly one of -object or -all")
+		}
+		n, err := db.RequeueQuarantined(*mappingID, *object)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "%d object(s) requeued\n", n)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown quarantine command: %s", args[0])
+	}
+}
+
+func writeQuarantined(w io.Writer, objects []*database.FileMetadata, format string) error {
+	switch format {
+	case "json":
+		list := make([]quarantinedObject, 0, len(objects))
+		for _, meta := range objects {
+			list = append(list, quarantinedObject{
+				MappingID:  meta.MappingID,
+				Object:     meta.ObjectName,
+				Target:     meta.TargetKey,
+				Attempts:   meta.AttemptCount,
+				LastError:  meta.LastError,
+				LastSynced: meta.LastSynced,
+			})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(list)
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MAPPING\tOBJECT\tATTEMPTS\tLAST ATTEMPT\tLAST ERROR")
+		for _, meta := range objects {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", meta.MappingID, meta.ObjectName, meta.AttemptCount,
+				meta.LastSynced.Format(time.RFC3339), meta.LastError)
+		}
+		fmt.Fprintf(tw, "%d quarantined object(s)\n", len(objects))
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
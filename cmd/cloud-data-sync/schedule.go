@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	gosync "sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/schedule"
+	syncPkg "github.com/DjonatanS/cloud-data-sync/internal/sync"
+)
+
+// scheduledMapping is a mapping with the schedule the service runs it on.
+type scheduledMapping struct {
+	mapping  config.BucketMapping
+	id       string
+	spec     string
+	schedule schedule.Schedule
+}
+
+// mappingSchedules returns the schedule of every mapping. Mappings without a
+// schedule run every interval.
+func mappingSchedules(mappings []config.BucketMapping, interval time.Duration) ([]scheduledMapping, error) {
+	scheduled := make([]scheduledMapping, 0, len(mappings))
+	for i, mapping := range mappings {
+		m := scheduledMapping{mapping: mapping, id: syncPkg.MappingID(mapping), spec: mapping.Schedule}
+		if m.spec == "" {
+			if interval <= 0 {
+				return nil, fmt.Errorf("mapping %d has no schedule and the interval is not positive: %s", i, interval)
+			}
+			m.schedule = schedule.Every(interval)
+			m.spec = schedule.Every(interval).String()
+		} else {
+			var err error
+			if m.schedule, err = schedule.Parse(mapping.Schedule); err != nil {
+				return nil, fmt.Errorf("mapping %d has invalid schedule: %w", i, err)
+			}
+		}
+		scheduled = append(scheduled, m)
+	}
+	return scheduled, nil
+}
+
+// nextRun returns when the service runs a mapping next after now, given the
+// start of its last recorded run. Interval schedules count from the last run
+// and are due now if it is older than the interval.
+func nextRun(m scheduledMapping, lastStart, now time.Time) time.Time {
+	if every, ok := m.schedule.(schedule.Every); ok {
+		if next := every.Next(lastStart); !lastStart.IsZero() && next.After(now) {
+			return next
+		}
+		return now
+	}
+	return m.schedule.Next(now)
+}
+
+// scheduler runs every mapping on its own schedule, so that a slow or
+// infrequent mapping does not hold up the others.
+type scheduler struct {
+	synchronizer *syncPkg.Synchronizer
+	logger       *slog.Logger
+
+	mu      gosync.Mutex
+	running map[string]bool // IDs of the mappings with a run in progress
+}
+
+func newScheduler(synchronizer *syncPkg.Synchronizer, logger *slog.Logger) *scheduler {
+	return &scheduler{synchronizer: synchronizer, logger: logger, running: make(map[string]bool)}
+}
+
+// run runs the mappings until ctx is canceled, then waits for the runs in
+// progress to return.
+func (s *scheduler) run(ctx context.Context, mappings []scheduledMapping) {
+	var wg gosync.WaitGroup
+	for _, m := range mappings {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, m)
+		}()
+	}
+	wg.Wait()
+}
+
+// loop runs a mapping on its schedule until ctx is canceled. A mapping on an
+// interval runs at once and then at the interval from the start of each run;
+// a cron schedule waits for its first matching time. Scheduled times that a
+// run lasted past are skipped, so runs of a mapping never overlap.
+func (s *scheduler) loop(ctx context.Context, m scheduledMapping) {
+	logger := s.logger.With(
+		"mapping_id", m.id,
+		"schedule", m.spec,
+		"source_provider", m.mapping.SourceProviderID,
+		"source_bucket", m.mapping.SourceBucket,
+		"target_provider", m.mapping.TargetProviderID,
+		"target_bucket", m.mapping.TargetBucket,
+	)
+
+	next := nextRun(m, time.Time{}, time.Now())
+	for {
+		if next.IsZero() {
+			logger.Warn("Schedule has no further run times, mapping will not run again")
+			return
+		}
+		logger.Info("Next synchronization scheduled", "next_run", next)
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		started := time.Now()
+		s.runMapping(ctx, m, logger)
+		if ctx.Err() != nil {
+			return
+		}
+
+		next = m.schedule.Next(started)
+		if now := time.Now(); !next.IsZero() && next.Before(now) {
+			logger.Warn("Run lasted past the next scheduled run, skipping it",
+				"skipped_run", next, "duration", now.Sub(started).Round(time.Second))
+			next = m.schedule.Next(now)
+		}
+	}
+}
+
+// runMapping synchronizes a mapping unless a run of it is already in
+// progress, as when two entries of the configuration describe the same
+// mapping.
+func (s *scheduler) runMapping(ctx context.Context, m scheduledMapping, logger *slog.Logger) {
+	s.mu.Lock()
+	if s.running[m.id] {
+		s.mu.Unlock()
+		logger.Warn("Previous run of the mapping is still in progress, skipping this run")
+		return
+	}
+	s.running[m.id] = true
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.running, m.id)
+		s.mu.Unlock()
+	}()
+
+	logger.Info("Starting synchronization for mapping")
+	if err := s.synchronizer.SyncBuckets(ctx, m.mapping, logger); err != nil {
+		logger.Error("Error synchronizing mapping", "error", err)
+		return
+	}
+	logger.Info("Synchronization mapping completed successfully")
+}
+
+// mappingStatus is the JSON form of a mapping's schedule and last run.
+type mappingStatus struct {
+	MappingID  string     `json:"mappingId"`
+	Schedule   string     `json:"schedule"`
+	LastRun    *time.Time `json:"lastRun,omitempty"`
+	LastStatus string     `json:"lastStatus,omitempty"`
+	NextRun    *time.Time `json:"nextRun,omitempty"` // Unset when the schedule never runs again
+}
+
+// runStatus implements the status command:
+//
+//	status [-output table|json]
+func runStatus(w io.Writer, cfg *config.Config, db *database.DB, interval time.Duration, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	format := fs.String("output", "table", "Output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	mappings, err := mappingSchedules(cfg.Mappings, interval)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	statuses := make([]mappingStatus, 0, len(mappings))
+	for _, m := range mappings {
+		status := mappingStatus{MappingID: m.id, Schedule: m.spec}
+		runs, err := db.ListSyncRuns(m.id, "", 1)
+		if err != nil {
+			return err
+		}
+		var lastStart time.Time
+		if len(runs) > 0 {
+			lastStart = runs[0].StartedAt
+			status.LastRun, status.LastStatus = &lastStart, runs[0].Status
+		}
+		if next := nextRun(m, lastStart, now); !next.IsZero() {
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	return writeStatus(w, statuses, *format)
+}
+
+func writeStatus(w io.Writer, statuses []mappingStatus, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(statuses)
+
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MAPPING\tSCHEDULE\tLAST RUN\tLAST STATUS\tNEXT RUN")
+		for _, status := range statuses {
+			lastRun, lastStatus, next := "-", "-", "never"
+			if status.LastRun != nil {
+				lastRun, lastStatus = status.LastRun.Local().Format(time.RFC3339), status.LastStatus
+			}
+			if status.NextRun != nil {
+				next = status.NextRun.Local().Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", status.MappingID, status.Schedule, lastRun, lastStatus, next)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
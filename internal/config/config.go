@@ -2,10 +2,22 @@
 package config
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/envelope"
+	"github.com/DjonatanS/cloud-data-sync/internal/filter"
+	"github.com/DjonatanS/cloud-data-sync/internal/hooks"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/keymap"
+	"github.com/DjonatanS/cloud-data-sync/internal/schedule"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
+	"github.com/DjonatanS/cloud-data-sync/internal/throttle"
 )
 
 type ProviderType string
@@ -17,12 +29,101 @@ const (
 	MINIO ProviderType = "minio"
 )
 
+// DeletePolicy controls what happens to target objects that no longer exist in the source.
+type DeletePolicy string
+
+const (
+	DeletePolicyMirror DeletePolicy = "mirror" // Delete the object from the target
+	DeletePolicyNone   DeletePolicy = "none"   // Never delete from the target
+	DeletePolicyTrash  DeletePolicy = "trash"  // Move the object to a trash prefix or bucket on the target
+)
+
+// DriftPolicy controls what happens when a target object the mapping wrote was
+// changed or removed outside of the sync.
+type DriftPolicy string
+
+const (
+	DriftPolicyReport DriftPolicy = "report" // Log the drift and leave the target as it is
+	DriftPolicyRepair DriftPolicy = "repair" // Upload the object again
+	DriftPolicyIgnore DriftPolicy = "ignore" // Do not compare the target with what was uploaded
+)
+
+// ClientEncryptionMode is the direction of a mapping's client-side encryption.
+type ClientEncryptionMode string
+
+const (
+	ClientEncryptionEncrypt ClientEncryptionMode = "encrypt" // Encrypt objects before uploading them
+	ClientEncryptionDecrypt ClientEncryptionMode = "decrypt" // Decrypt objects read from the source, to restore a backup
+)
+
+// Built-in transform types.
+const (
+	TransformGzip = "gzip"
+	TransformZstd = "zstd"
+)
+
+// TransformMode is the direction of a compression transform.
+type TransformMode string
+
+const (
+	TransformCompress   TransformMode = "compress"
+	TransformDecompress TransformMode = "decompress"
+)
+
+// DefaultTrashPrefix is the key prefix used by the trash policy when none is configured.
+const DefaultTrashPrefix = ".trash/"
+
+// DefaultConcurrency is the number of objects transferred in parallel for a
+// mapping that does not set its own concurrency.
+const DefaultConcurrency = 4
+
+// Defaults for retrying objects that fail to synchronize across runs.
+const (
+	DefaultMaxObjectAttempts = 5
+	DefaultFailureBaseDelay  = time.Minute
+	DefaultFailureMaxDelay   = 6 * time.Hour
+)
+
+// Defaults and limits for uploading large objects in resumable parts.
+const (
+	DefaultMultipartThreshold = 256 << 20 // Objects of at least this size are uploaded in parts
+	DefaultPartSize           = 64 << 20
+	MinPartSize               = 5 << 20   // Smallest part S3 accepts, except for the last one
+	PartSizeAlignment         = 256 << 10 // GCS resumable uploads need parts in multiples of 256 KiB
+)
+
+// Limits on object tags that S3 and Azure both accept.
+const (
+	MaxObjectTags     = 10
+	MaxTagKeyLength   = 128
+	MaxTagValueLength = 256
+)
+
 // Config represents the application configuration including database path,
 // storage providers, and bucket mappings.
 type Config struct {
-	DatabasePath string           `json:"databasePath"`
-	Providers    []ProviderConfig `json:"providers"`
-	Mappings     []BucketMapping  `json:"mappings"`
+	DatabasePath   string           `json:"databasePath"`
+	MaxConcurrency int              `json:"maxConcurrency,omitempty"` // Global cap on parallel transfers across all mappings (0 = no cap)
+	Failures       *FailureConfig   `json:"failures,omitempty"`       // Backoff and quarantine of objects that keep failing (defaults apply when unset)
+	Multipart      *MultipartConfig `json:"multipart,omitempty"`      // Resumable part uploads of large objects (defaults apply when unset)
+
+	MaxBytesPerSecond int64             `json:"maxBytesPerSecond,omitempty"` // Upload rate shared by all transfers of all mappings (0 = unlimited)
+	BandwidthSchedule []throttle.Window `json:"bandwidthSchedule,omitempty"` // Times of day with a different global rate
+
+	SpoolDir string `json:"spoolDir,omitempty"` // Directory for temporary copies of transformed objects whose size a target needs (default: the system temporary directory)
+
+	Hooks *hooks.Config `json:"hooks,omitempty"` // Hooks of every mapping, run before those of the mapping
+
+	Providers []ProviderConfig `json:"providers"`
+	Mappings  []BucketMapping  `json:"mappings"`
+}
+
+// FailureConfig controls how an object whose synchronization failed is
+// retried by later runs. Zero values fall back to the Default* constants.
+type FailureConfig struct {
+	MaxAttempts int      `json:"maxAttempts,omitempty"` // Consecutive failed runs before the object is quarantined
+	BaseDelay   Duration `json:"baseDelay,omitempty"`   // Wait after the first failure, doubled after each further failure
+	MaxDelay    Duration `json:"maxDelay,omitempty"`    // Upper bound for the wait between two attempts
 }
 
 // ProviderConfig holds configuration for a specific storage provider.
@@ -33,6 +134,75 @@ type ProviderConfig struct {
 	AWS   *AWSConfig   `json:"aws,omitempty"`
 	Azure *AzureConfig `json:"azure,omitempty"`
 	MinIO *MinIOConfig `json:"minio,omitempty"`
+	Retry *RetryConfig `json:"retry,omitempty"` // Retry policy for the provider's operations (defaults apply when unset)
+
+	Encryption *EncryptionConfig `json:"encryption,omitempty"` // Encryption of the objects written to the provider (default: the bucket's)
+}
+
+// EncryptionConfig selects how the provider encrypts the objects written to
+// it. A customer key set on a provider is also used to read its objects.
+type EncryptionConfig struct {
+	Mode        interfaces.EncryptionMode `json:"mode"`                  // managed, kms or customer-key
+	KMSKeyID    string                    `json:"kmsKeyId,omitempty"`    // kms: AWS KMS key ID or ARN, MinIO KMS key name, GCS Cloud KMS key name or Azure encryption scope
+	CustomerKey string                    `json:"customerKey,omitempty"` // customer-key: base64-encoded 256-bit AES key
+}
+
+// Encryption converts the configuration to the settings of the provider
+// clients. It returns nil for a nil configuration.
+func (e *EncryptionConfig) Encryption() (*interfaces.Encryption, error) {
+	if e == nil {
+		return nil, nil
+	}
+	enc := &interfaces.Encryption{Mode: e.Mode, KeyID: e.KMSKeyID}
+	if e.CustomerKey != "" {
+		key, err := base64.StdEncoding.DecodeString(e.CustomerKey)
+		if err != nil {
+			return nil, fmt.Errorf("customerKey is not valid base64: %w", err)
+		}
+		enc.Key = key
+	}
+	return enc, nil
+}
+
+// RetryConfig controls how failed provider operations are retried. Zero
+// values fall back to the defaults of the retry package.
+type RetryConfig struct {
+	MaxAttempts     int      `json:"maxAttempts,omitempty"`     // Attempts per operation including the first; 1 disables retries
+	BaseDelay       Duration `json:"baseDelay,omitempty"`       // Delay before the first retry, doubled on each attempt
+	MaxDelay        Duration `json:"maxDelay,omitempty"`        // Upper bound for a single delay
+	Jitter          *float64 `json:"jitter,omitempty"`          // Fraction (0-1) of each delay that is randomized
+	RetryableErrors []string `json:"retryableErrors,omitempty"` // Extra error message fragments to treat as transient
+}
+
+// Duration is a time.Duration that is read from and written to JSON as a
+// string such as "500ms" or "1m30s".
+type Duration time.Duration
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"1s\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MultipartConfig controls when objects are uploaded in parts that a later
+// run can resume after a crash or restart. Zero values fall back to the
+// Default* constants.
+type MultipartConfig struct {
+	Threshold int64 `json:"threshold,omitempty"` // Size in bytes from which objects are uploaded in parts
+	PartSize  int64 `json:"partSize,omitempty"`  // Bytes per part; raised for objects that would need more than 10000 parts
 }
 
 // GCSConfig contains settings for Google Cloud Storage provider.
@@ -65,12 +235,107 @@ type MinIOConfig struct {
 	Region    string `json:"region,omitempty"`
 }
 
+// MappingTarget is one destination of a fan-out mapping.
+type MappingTarget struct {
+	ProviderID string `json:"providerId"`
+	Bucket     string `json:"bucket"`
+	Prefix     string `json:"prefix,omitempty"` // Same as BucketMapping.TargetPrefix, for this target
+}
+
+// TransformConfig is one step of a mapping's transform pipeline.
+type TransformConfig struct {
+	Type    string            `json:"type"`              // gzip, zstd, or a type registered with the synchronizer
+	Mode    TransformMode     `json:"mode,omitempty"`    // gzip and zstd: compress (default) or decompress
+	Level   int               `json:"level,omitempty"`   // gzip (1-9) and zstd (1-22) compression level (0 = the codec's default)
+	Options map[string]string `json:"options,omitempty"` // Settings of registered transform types
+}
+
+// ClientEncryptionConfig encrypts object content before it leaves the
+// process, with a data key per object wrapped by a key-encryption key read
+// from KeyFile or derived from the passphrase in PassphraseEnv.
+type ClientEncryptionConfig struct {
+	Mode          ClientEncryptionMode `json:"mode"`                    // encrypt or decrypt
+	KeyFile       string               `json:"keyFile,omitempty"`       // File holding a 256-bit key, raw or base64-encoded
+	PassphraseEnv string               `json:"passphraseEnv,omitempty"` // Environment variable holding a passphrase
+}
+
+// Keyring returns the envelope keyring of the configuration.
+func (c *ClientEncryptionConfig) Keyring() (*envelope.Keyring, error) {
+	if c.KeyFile != "" {
+		return envelope.NewKeyFile(c.KeyFile)
+	}
+	passphrase := os.Getenv(c.PassphraseEnv)
+	if passphrase == "" {
+		return nil, fmt.Errorf("environment variable %s holds no passphrase", c.PassphraseEnv)
+	}
+	return envelope.NewPassphrase(passphrase)
+}
+
 // BucketMapping defines a source-to-target bucket mapping for synchronization.
+// A mapping either names a single target with TargetProviderID/TargetBucket or
+// lists several in Targets, in which case each changed object is read once
+// and written to all of them.
 type BucketMapping struct {
 	SourceProviderID string `json:"sourceProviderId"`
 	SourceBucket     string `json:"sourceBucket"`
 	TargetProviderID string `json:"targetProviderId"`
 	TargetBucket     string `json:"targetBucket"`
+	SourcePrefix     string `json:"sourcePrefix,omitempty"` // Only objects under this prefix are synchronized
+	TargetPrefix     string `json:"targetPrefix,omitempty"` // Replaces sourcePrefix in target keys
+	KeyTemplate      string `json:"keyTemplate,omitempty"`  // Go template for the target key after targetPrefix, e.g. {{.Year}}/{{.Month}}/{{.Key}}
+	Concurrency      int    `json:"concurrency,omitempty"`  // Parallel object transfers for this mapping (0 = DefaultConcurrency)
+
+	Targets []MappingTarget `json:"targets,omitempty"` // Fan-out targets; replaces targetProviderId/targetBucket/targetPrefix
+
+	DeletePolicy DeletePolicy `json:"deletePolicy,omitempty"` // mirror (default), none or trash
+	TrashBucket  string       `json:"trashBucket,omitempty"`  // Bucket on the target provider receiving trashed objects (default: targetBucket)
+	TrashPrefix  string       `json:"trashPrefix,omitempty"`  // Key prefix for trashed objects (default: DefaultTrashPrefix)
+
+	AdoptExisting    bool    `json:"adoptExisting,omitempty"`    // Also delete target objects this mapping did not write, e.g. copies made before adopting the tool
+	MaxDeletes       int     `json:"maxDeletes,omitempty"`       // Abort the deletion phase when more objects would be removed (0 = no limit)
+	MaxDeletePercent float64 `json:"maxDeletePercent,omitempty"` // Abort the deletion phase when a larger share of target objects would be removed (0 = only when the source is empty, 100 = no limit)
+
+	Transforms []TransformConfig `json:"transforms,omitempty"` // Applied in order to the content of every object copied
+
+	Encryption       *EncryptionConfig       `json:"encryption,omitempty"`       // Encryption of the objects written to the targets; replaces that of the target providers
+	ClientEncryption *ClientEncryptionConfig `json:"clientEncryption,omitempty"` // Encrypt content before uploading it, or decrypt it when restoring
+
+	DriftPolicy       DriftPolicy `json:"driftPolicy,omitempty"`       // report (default), repair or ignore
+	ReplicateVersions bool        `json:"replicateVersions,omitempty"` // Also copy the older versions and delete markers of each object, oldest first; the source must support versioning
+
+	ReplicateTags bool              `json:"replicateTags,omitempty"` // Copy the tags of each object to the target
+	Tags          map[string]string `json:"tags,omitempty"`          // Tags added to every object written; they replace source tags with the same key
+	StorageClass  string            `json:"storageClass,omitempty"`  // Class or access tier of the objects written, named after any provider's, or "preserve" to keep the source's (default: bucket default)
+
+	Include []filter.Rule `json:"include,omitempty"` // Only objects matching one of these rules are synchronized (default: all)
+	Exclude []filter.Rule `json:"exclude,omitempty"` // Objects matching any of these rules are never copied or deleted
+
+	MaxBytesPerSecond int64             `json:"maxBytesPerSecond,omitempty"` // Upload rate shared by the transfers of this mapping (0 = unlimited)
+	BandwidthSchedule []throttle.Window `json:"bandwidthSchedule,omitempty"` // Times of day with a different rate for this mapping
+
+	Hooks *hooks.Config `json:"hooks,omitempty"` // Commands and webhooks run before and after each run, after each upload and on failure
+
+	Schedule string `json:"schedule,omitempty"` // 5-field cron expression or "@every <duration>" the service runs the mapping on (default: every --interval)
+}
+
+// SingleTargets returns one copy of the mapping per target, each with
+// TargetProviderID, TargetBucket and TargetPrefix set and Targets cleared. A
+// mapping without Targets is returned as is.
+func (m BucketMapping) SingleTargets() []BucketMapping {
+	if len(m.Targets) == 0 {
+		return []BucketMapping{m}
+	}
+
+	mappings := make([]BucketMapping, 0, len(m.Targets))
+	for _, target := range m.Targets {
+		single := m
+		single.Targets = nil
+		single.TargetProviderID = target.ProviderID
+		single.TargetBucket = target.Bucket
+		single.TargetPrefix = target.Prefix
+		mappings = append(mappings, single)
+	}
+	return mappings
 }
 
 // LoadConfig reads a JSON configuration file from the provided path,
@@ -114,11 +379,13 @@ func validateConfig(config *Config) error {
 	}
 
 	idMap := make(map[string]bool)
+	types := make(map[string]ProviderType)
 	for _, provider := range config.Providers {
 		if idMap[provider.ID] {
 			return fmt.Errorf("duplicate provider ID: %s", provider.ID)
 		}
 		idMap[provider.ID] = true
+		types[provider.ID] = provider.Type
 
 		switch provider.Type {
 		case GCS:
@@ -140,6 +407,32 @@ func validateConfig(config *Config) error {
 		default:
 			return fmt.Errorf("unknown provider type: %s", provider.Type)
 		}
+
+		if err := validateRetry(provider.Retry); err != nil {
+			return fmt.Errorf("provider %s has invalid retry settings: %w", provider.ID, err)
+		}
+		if err := validateEncryption(provider.Encryption, provider.Type); err != nil {
+			return fmt.Errorf("provider %s has invalid encryption settings: %w", provider.ID, err)
+		}
+	}
+
+	if config.MaxConcurrency < 0 {
+		return fmt.Errorf("maxConcurrency must not be negative: %d", config.MaxConcurrency)
+	}
+
+	if err := validateFailures(config.Failures); err != nil {
+		return fmt.Errorf("invalid failures settings: %w", err)
+	}
+
+	if err := validateMultipart(config.Multipart); err != nil {
+		return fmt.Errorf("invalid multipart settings: %w", err)
+	}
+
+	if _, err := throttle.New(config.MaxBytesPerSecond, config.BandwidthSchedule); err != nil {
+		return fmt.Errorf("invalid bandwidth settings: %w", err)
+	}
+	if _, err := hooks.New(config.Hooks); err != nil {
+		return fmt.Errorf("invalid hooks: %w", err)
 	}
 
 	if len(config.Mappings) == 0 {
@@ -150,11 +443,230 @@ func validateConfig(config *Config) error {
 		if !idMap[mapping.SourceProviderID] {
 			return fmt.Errorf("mapping %d uses non-existent source provider: %s", i, mapping.SourceProviderID)
 		}
-		if !idMap[mapping.TargetProviderID] {
-			return fmt.Errorf("mapping %d uses non-existent target provider: %s", i, mapping.TargetProviderID)
+		if len(mapping.Targets) > 0 && (mapping.TargetProviderID != "" || mapping.TargetBucket != "" || mapping.TargetPrefix != "") {
+			return fmt.Errorf("mapping %d sets both targets and targetProviderId/targetBucket/targetPrefix", i)
+		}
+		seenTargets := make(map[string]bool)
+		for _, target := range mapping.SingleTargets() {
+			if !idMap[target.TargetProviderID] {
+				return fmt.Errorf("mapping %d uses non-existent target provider: %s", i, target.TargetProviderID)
+			}
+			key := target.TargetProviderID + ":" + target.TargetBucket + "/" + target.TargetPrefix
+			if seenTargets[key] {
+				return fmt.Errorf("mapping %d lists target %s more than once", i, key)
+			}
+			seenTargets[key] = true
+		}
+		if mapping.Concurrency < 0 {
+			return fmt.Errorf("mapping %d has negative concurrency: %d", i, mapping.Concurrency)
+		}
+		switch mapping.DeletePolicy {
+		case "", DeletePolicyMirror, DeletePolicyNone, DeletePolicyTrash:
+		default:
+			return fmt.Errorf("mapping %d has unknown delete policy: %s", i, mapping.DeletePolicy)
+		}
+		switch mapping.DriftPolicy {
+		case "", DriftPolicyReport, DriftPolicyRepair, DriftPolicyIgnore:
+		default:
+			return fmt.Errorf("mapping %d has unknown drift policy: %s", i, mapping.DriftPolicy)
+		}
+		if mapping.MaxDeletes < 0 {
+			return fmt.Errorf("mapping %d has negative maxDeletes: %d", i, mapping.MaxDeletes)
+		}
+		if mapping.MaxDeletePercent < 0 || mapping.MaxDeletePercent > 100 {
+			return fmt.Errorf("mapping %d has maxDeletePercent outside 0-100: %g", i, mapping.MaxDeletePercent)
+		}
+		if _, err := keymap.New(mapping.SourcePrefix, mapping.TargetPrefix, mapping.KeyTemplate); err != nil {
+			return fmt.Errorf("mapping %d has invalid key mapping: %w", i, err)
+		}
+		if _, err := filter.New(mapping.Include, mapping.Exclude); err != nil {
+			return fmt.Errorf("mapping %d has invalid filter: %w", i, err)
+		}
+		if _, err := throttle.New(mapping.MaxBytesPerSecond, mapping.BandwidthSchedule); err != nil {
+			return fmt.Errorf("mapping %d has invalid bandwidth settings: %w", i, err)
+		}
+		if _, err := hooks.New(mapping.Hooks); err != nil {
+			return fmt.Errorf("mapping %d has invalid hooks: %w", i, err)
+		}
+		if mapping.Schedule != "" {
+			if _, err := schedule.Parse(mapping.Schedule); err != nil {
+				return fmt.Errorf("mapping %d has invalid schedule: %w", i, err)
+			}
+		}
+		if err := validateTags(mapping.Tags); err != nil {
+			return fmt.Errorf("mapping %d has invalid tags: %w", i, err)
+		}
+		if mapping.Encryption != nil {
+			if mapping.Encryption.Mode == interfaces.EncryptionCustomerKey {
+				return fmt.Errorf("mapping %d sets a customer key, which must be set on the target provider so that its objects can be read", i)
+			}
+			var targetTypes []ProviderType
+			for _, target := range mapping.SingleTargets() {
+				targetTypes = append(targetTypes, types[target.TargetProviderID])
+			}
+			if err := validateEncryption(mapping.Encryption, targetTypes...); err != nil {
+				return fmt.Errorf("mapping %d has invalid encryption settings: %w", i, err)
+			}
+		}
+		for j, transform := range mapping.Transforms {
+			if err := validateTransform(transform); err != nil {
+				return fmt.Errorf("mapping %d has invalid transform %d: %w", i, j, err)
+			}
+		}
+		if err := validateClientEncryption(mapping.ClientEncryption); err != nil {
+			return fmt.Errorf("mapping %d has invalid client-side encryption settings: %w", i, err)
+		}
+		if mapping.StorageClass != "" && mapping.StorageClass != storageclass.Preserve && !storageclass.Valid(mapping.StorageClass) {
+			return fmt.Errorf("mapping %d has unknown storage class: %s", i, mapping.StorageClass)
+		}
+		if mapping.DeletePolicy != DeletePolicyTrash && (mapping.TrashBucket != "" || mapping.TrashPrefix != "") {
+			return fmt.Errorf("mapping %d sets trashBucket/trashPrefix without deletePolicy %q", i, DeletePolicyTrash)
+		}
+	}
+
+	return nil
+}
+
+func validateTags(tags map[string]string) error {
+	if len(tags) > MaxObjectTags {
+		return fmt.Errorf("%d tags exceed the limit of %d", len(tags), MaxObjectTags)
+	}
+	for k, v := range tags {
+		if k == "" || len(k) > MaxTagKeyLength {
+			return fmt.Errorf("tag key %q must be 1 to %d characters long", k, MaxTagKeyLength)
+		}
+		if len(v) > MaxTagValueLength {
+			return fmt.Errorf("value of tag %q exceeds %d characters", k, MaxTagValueLength)
+		}
+	}
+	return nil
+}
+
+// validateEncryption checks encryption settings for providers of the given
+// types.
+func validateEncryption(enc *EncryptionConfig, types ...ProviderType) error {
+	if enc == nil {
+		return nil
+	}
+
+	switch enc.Mode {
+	case interfaces.EncryptionManaged:
+		if enc.KMSKeyID != "" || enc.CustomerKey != "" {
+			return fmt.Errorf("mode %s takes no kmsKeyId or customerKey", enc.Mode)
+		}
+	case interfaces.EncryptionKMS:
+		if enc.CustomerKey != "" {
+			return fmt.Errorf("mode %s takes no customerKey", enc.Mode)
+		}
+		for _, t := range types {
+			if enc.KMSKeyID == "" && (t == GCS || t == AZURE) {
+				return fmt.Errorf("mode %s needs a kmsKeyId on %s providers", enc.Mode, t)
+			}
+		}
+	case interfaces.EncryptionCustomerKey:
+		if enc.KMSKeyID != "" {
+			return fmt.Errorf("mode %s takes no kmsKeyId", enc.Mode)
+		}
+		parsed, err := enc.Encryption()
+		if err != nil {
+			return err
 		}
+		if len(parsed.Key) != interfaces.CustomerKeySize {
+			return fmt.Errorf("customerKey must be %d bytes, got %d", interfaces.CustomerKeySize, len(parsed.Key))
+		}
+	default:
+		return fmt.Errorf("unknown mode: %q", enc.Mode)
+	}
+	return nil
+}
+
+// validateTransform checks a transform step. Registered types are only known
+// to the synchronizer, which rejects unknown ones when a mapping runs.
+func validateTransform(t TransformConfig) error {
+	maxLevel := 0
+	switch t.Type {
+	case "":
+		return errors.New("type must be set")
+	case TransformGzip:
+		maxLevel = 9
+	case TransformZstd:
+		maxLevel = 22
+	default:
+		return nil
+	}
+	switch t.Mode {
+	case "", TransformCompress, TransformDecompress:
+	default:
+		return fmt.Errorf("unknown %s mode: %q", t.Type, t.Mode)
+	}
+	if t.Level < 0 || t.Level > maxLevel {
+		return fmt.Errorf("%s level must be between 1 and %d: %d", t.Type, maxLevel, t.Level)
+	}
+	return nil
+}
+
+// validateClientEncryption checks the shape of client-side encryption
+// settings. The key itself is read when the mapping runs.
+func validateClientEncryption(enc *ClientEncryptionConfig) error {
+	if enc == nil {
+		return nil
 	}
+	switch enc.Mode {
+	case ClientEncryptionEncrypt, ClientEncryptionDecrypt:
+	default:
+		return fmt.Errorf("unknown mode: %q", enc.Mode)
+	}
+	if (enc.KeyFile == "") == (enc.PassphraseEnv == "") {
+		return errors.New("exactly one of keyFile and passphraseEnv must be set")
+	}
+	return nil
+}
+
+func validateRetry(retry *RetryConfig) error {
+	if retry == nil {
+		return nil
+	}
+	if retry.MaxAttempts < 0 {
+		return fmt.Errorf("maxAttempts must not be negative: %d", retry.MaxAttempts)
+	}
+	if retry.BaseDelay < 0 || retry.MaxDelay < 0 {
+		return fmt.Errorf("delays must not be negative")
+	}
+	if retry.MaxDelay > 0 && retry.BaseDelay > retry.MaxDelay {
+		return fmt.Errorf("baseDelay %s exceeds maxDelay %s", time.Duration(retry.BaseDelay), time.Duration(retry.MaxDelay))
+	}
+	if retry.Jitter != nil && (*retry.Jitter < 0 || *retry.Jitter > 1) {
+		return fmt.Errorf("jitter must be between 0 and 1: %g", *retry.Jitter)
+	}
+	return nil
+}
 
+func validateFailures(failures *FailureConfig) error {
+	if failures == nil {
+		return nil
+	}
+	if failures.MaxAttempts < 0 {
+		return fmt.Errorf("maxAttempts must not be negative: %d", failures.MaxAttempts)
+	}
+	if failures.BaseDelay < 0 || failures.MaxDelay < 0 {
+		return fmt.Errorf("delays must not be negative")
+	}
+	if failures.MaxDelay > 0 && failures.BaseDelay > failures.MaxDelay {
+		return fmt.Errorf("baseDelay %s exceeds maxDelay %s", time.Duration(failures.BaseDelay), time.Duration(failures.MaxDelay))
+	}
+	return nil
+}
+
+func validateMultipart(multipart *MultipartConfig) error {
+	if multipart == nil {
+		return nil
+	}
+	if multipart.Threshold < 0 {
+		return fmt.Errorf("threshold must not be negative: %d", multipart.Threshold)
+	}
+	if multipart.PartSize != 0 && (multipart.PartSize < MinPartSize || multipart.PartSize%PartSizeAlignment != 0) {
+		return fmt.Errorf("partSize must be a multiple of %d bytes of at least %d bytes: %d", PartSizeAlignment, MinPartSize, multipart.PartSize)
+	}
 	return nil
 }
 
@@ -1,9 +1,18 @@
 package config
 
 import (
+	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/filter"
+	"github.com/DjonatanS/cloud-data-sync/internal/hooks"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
+	"github.com/DjonatanS/cloud-data-sync/internal/throttle"
 )
 
 func TestValidateConfig_Success(t *testing.T) {
@@ -73,6 +82,113 @@ func TestValidateConfig_InvalidMappingProvider(t *testing.T) {
 	}
 }
 
+func TestValidateConfig_NegativeConcurrency(t *testing.T) {
+	cfg := &Config{
+		Providers: []ProviderConfig{{ID: "p1", Type: GCS, GCS: &GCSConfig{ProjectID: "proj"}}},
+		Mappings:  []BucketMapping{{SourceProviderID: "p1", SourceBucket: "sb", TargetProviderID: "p1", TargetBucket: "tb", Concurrency: -1}},
+	}
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected error for negative concurrency, got nil")
+	}
+}
+
+func TestValidateConfig_MappingOptions(t *testing.T) {
+	base := BucketMapping{SourceProviderID: "p1", SourceBucket: "sb", TargetProviderID: "p1", TargetBucket: "tb"}
+	tests := []struct {
+		name    string
+		mutate  func(m *BucketMapping)
+		wantErr bool
+	}{
+		{"default", func(m *BucketMapping) {}, false},
+		{"none", func(m *BucketMapping) { m.DeletePolicy = DeletePolicyNone }, false},
+		{"trash with prefix", func(m *BucketMapping) { m.DeletePolicy = DeletePolicyTrash; m.TrashPrefix = "old/" }, false},
+		{"unknown", func(m *BucketMapping) { m.DeletePolicy = "purge" }, true},
+		{"trash prefix without trash policy", func(m *BucketMapping) { m.TrashPrefix = "old/" }, true},
+		{"repair drift", func(m *BucketMapping) { m.DriftPolicy = DriftPolicyRepair }, false},
+		{"unknown drift policy", func(m *BucketMapping) { m.DriftPolicy = "overwrite" }, true},
+		{"static tags", func(m *BucketMapping) { m.ReplicateTags = true; m.Tags = map[string]string{"replica": "true"} }, false},
+		{"empty tag key", func(m *BucketMapping) { m.Tags = map[string]string{"": "x"} }, true},
+		{"too many tags", func(m *BucketMapping) {
+			m.Tags = make(map[string]string)
+			for i := 0; i <= MaxObjectTags; i++ {
+				m.Tags[strconv.Itoa(i)] = "x"
+			}
+		}, true},
+		{"storage class of another provider", func(m *BucketMapping) { m.StorageClass = "COLDLINE" }, false},
+		{"preserve storage class", func(m *BucketMapping) { m.StorageClass = storageclass.Preserve }, false},
+		{"unknown storage class", func(m *BucketMapping) { m.StorageClass = "FROZEN" }, true},
+		{"kms encryption", func(m *BucketMapping) {
+			m.Encryption = &EncryptionConfig{Mode: interfaces.EncryptionKMS, KMSKeyID: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}
+		}, false},
+		{"kms encryption without key on gcs", func(m *BucketMapping) { m.Encryption = &EncryptionConfig{Mode: interfaces.EncryptionKMS} }, true},
+		{"customer key on mapping", func(m *BucketMapping) {
+			m.Encryption = &EncryptionConfig{Mode: interfaces.EncryptionCustomerKey, CustomerKey: base64.StdEncoding.EncodeToString(make([]byte, 32))}
+		}, true},
+		{"transforms", func(m *BucketMapping) {
+			m.Transforms = []TransformConfig{{Type: TransformZstd, Level: 19}, {Type: "redact", Options: map[string]string{"field": "email"}}}
+		}, false},
+		{"transform without type", func(m *BucketMapping) { m.Transforms = []TransformConfig{{Mode: TransformCompress}} }, true},
+		{"gzip level out of range", func(m *BucketMapping) { m.Transforms = []TransformConfig{{Type: TransformGzip, Level: 12}} }, true},
+		{"unknown gzip mode", func(m *BucketMapping) { m.Transforms = []TransformConfig{{Type: TransformGzip, Mode: "inflate"}} }, true},
+		{"client-side encryption", func(m *BucketMapping) {
+			m.ClientEncryption = &ClientEncryptionConfig{Mode: ClientEncryptionEncrypt, KeyFile: "/etc/cds/backup.key"}
+		}, false},
+		{"client-side encryption with two keys", func(m *BucketMapping) {
+			m.ClientEncryption = &ClientEncryptionConfig{Mode: ClientEncryptionDecrypt, KeyFile: "backup.key", PassphraseEnv: "CDS_PASSPHRASE"}
+		}, true},
+		{"unknown client-side encryption mode", func(m *BucketMapping) {
+			m.ClientEncryption = &ClientEncryptionConfig{Mode: "seal", PassphraseEnv: "CDS_PASSPHRASE"}
+		}, true},
+		{"hooks", func(m *BucketMapping) {
+			m.Hooks = &hooks.Config{BeforeRun: []hooks.Hook{{Command: []string{"/opt/validate.sh"}, Timeout: "2m"}}}
+		}, false},
+		{"hook without command or url", func(m *BucketMapping) { m.Hooks = &hooks.Config{AfterRun: []hooks.Hook{{}}} }, true},
+		{"cron schedule", func(m *BucketMapping) { m.Schedule = "0 2 * * *" }, false},
+		{"interval schedule", func(m *BucketMapping) { m.Schedule = "@every 1m" }, false},
+		{"invalid schedule", func(m *BucketMapping) { m.Schedule = "every night" }, true},
+		{"delete thresholds", func(m *BucketMapping) { m.MaxDeletes = 100; m.MaxDeletePercent = 10 }, false},
+		{"percent above 100", func(m *BucketMapping) { m.MaxDeletePercent = 150 }, true},
+		{"valid filters", func(m *BucketMapping) {
+			m.Include = []filter.Rule{{Type: filter.Glob, Pattern: "exports/**/*.parquet"}}
+			m.Exclude = []filter.Rule{{Type: filter.Prefix, Pattern: "tmp/"}}
+		}, false},
+		{"fan-out targets", func(m *BucketMapping) {
+			m.TargetProviderID, m.TargetBucket = "", ""
+			m.Targets = []MappingTarget{{ProviderID: "p1", Bucket: "a"}, {ProviderID: "p1", Bucket: "b"}}
+		}, false},
+		{"targets and target provider", func(m *BucketMapping) { m.Targets = []MappingTarget{{ProviderID: "p1", Bucket: "a"}} }, true},
+		{"duplicate targets", func(m *BucketMapping) {
+			m.TargetProviderID, m.TargetBucket = "", ""
+			m.Targets = []MappingTarget{{ProviderID: "p1", Bucket: "a"}, {ProviderID: "p1", Bucket: "a"}}
+		}, true},
+		{"unknown fan-out provider", func(m *BucketMapping) {
+			m.TargetProviderID, m.TargetBucket = "", ""
+			m.Targets = []MappingTarget{{ProviderID: "p2", Bucket: "a"}}
+		}, true},
+		{"invalid regex filter", func(m *BucketMapping) { m.Exclude = []filter.Rule{{Type: filter.Regex, Pattern: "("}} }, true},
+		{"bandwidth schedule", func(m *BucketMapping) {
+			m.BandwidthSchedule = []throttle.Window{{Start: "08:00", End: "18:00", MaxBytesPerSecond: 10 << 20}}
+		}, false},
+		{"negative bandwidth", func(m *BucketMapping) { m.MaxBytesPerSecond = -1 }, true},
+		{"invalid bandwidth window", func(m *BucketMapping) {
+			m.BandwidthSchedule = []throttle.Window{{Start: "8", End: "18:00", MaxBytesPerSecond: 1}}
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapping := base
+			tt.mutate(&mapping)
+			cfg := &Config{
+				Providers: []ProviderConfig{{ID: "p1", Type: GCS, GCS: &GCSConfig{ProjectID: "proj"}}},
+				Mappings:  []BucketMapping{mapping},
+			}
+			if err := validateConfig(cfg); (err != nil) != tt.wantErr {
+				t.Fatalf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestLoadConfig_DefaultDBPath(t *testing.T) {
 	// Create temporary config file without databasePath
 	tmpDir := t.TempDir()
@@ -96,3 +212,111 @@ func TestLoadConfig_DefaultDBPath(t *testing.T) {
 		t.Fatalf("expected default DatabasePath 'data.db', got %s", cfg.DatabasePath)
 	}
 }
+
+func TestLoadConfig_RetrySettings(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfgPath := filepath.Join(tmpDir, "testconfig.json")
+	json := `{
+		"providers": [
+			{"id": "p1", "type": "gcs", "gcs": {"projectId": "proj"},
+			 "retry": {"maxAttempts": 5, "baseDelay": "250ms", "maxDelay": "30s", "jitter": 0.2}}
+		],
+		"mappings": [
+			{"sourceProviderId": "p1", "sourceBucket": "sb", "targetProviderId": "p1", "targetBucket": "tb"}
+		]
+	}`
+	if err := os.WriteFile(cfgPath, []byte(json), 0644); err != nil {
+		t.Fatalf("failed to write temp config: %v", err)
+	}
+	cfg, err := LoadConfig(cfgPath)
+	if err != nil {
+		t.Fatalf("unexpected error loading config: %v", err)
+	}
+	retry := cfg.Providers[0].Retry
+	if retry == nil || retry.MaxAttempts != 5 || time.Duration(retry.BaseDelay) != 250*time.Millisecond ||
+		time.Duration(retry.MaxDelay) != 30*time.Second || retry.Jitter == nil || *retry.Jitter != 0.2 {
+		t.Fatalf("unexpected retry settings: %+v", retry)
+	}
+
+	cfg.Providers[0].Retry.BaseDelay = Duration(time.Minute)
+	if err := validateConfig(cfg); err == nil {
+		t.Fatal("expected error for baseDelay above maxDelay, got nil")
+	}
+}
+
+func TestValidateConfig_Failures(t *testing.T) {
+	base := func(failures *FailureConfig) *Config {
+		return &Config{
+			Failures:  failures,
+			Providers: []ProviderConfig{{ID: "p1", Type: GCS, GCS: &GCSConfig{ProjectID: "proj"}}},
+			Mappings:  []BucketMapping{{SourceProviderID: "p1", SourceBucket: "sb", TargetProviderID: "p1", TargetBucket: "tb"}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		failures *FailureConfig
+		wantErr  bool
+	}{
+		{"unset", nil, false},
+		{"valid", &FailureConfig{MaxAttempts: 3, BaseDelay: Duration(time.Minute), MaxDelay: Duration(time.Hour)}, false},
+		{"negative attempts", &FailureConfig{MaxAttempts: -1}, true},
+		{"base above max", &FailureConfig{BaseDelay: Duration(time.Hour), MaxDelay: Duration(time.Minute)}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateConfig(base(tt.failures)); (err != nil) != tt.wantErr {
+				t.Errorf("validateConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateConfig_Multipart(t *testing.T) {
+	tests := []struct {
+		name      string
+		multipart *MultipartConfig
+		wantErr   bool
+	}{
+		{"unset", nil, false},
+		{"valid", &MultipartConfig{Threshold: 1 << 30, PartSize: 16 << 20}, false},
+		{"negative threshold", &MultipartConfig{Threshold: -1}, true},
+		{"part too small", &MultipartConfig{PartSize: 1 << 20}, true},
+		{"part not aligned", &MultipartConfig{PartSize: MinPartSize + 1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateMultipart(tt.multipart); (err != nil) != tt.wantErr {
+				t.Errorf("validateMultipart() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateEncryption(t *testing.T) {
+	key := base64.StdEncoding.EncodeToString(make([]byte, interfaces.CustomerKeySize))
+	tests := []struct {
+		name     string
+		enc      *EncryptionConfig
+		provider ProviderType
+		wantErr  bool
+	}{
+		{"unset", nil, AWS, false},
+		{"managed", &EncryptionConfig{Mode: interfaces.EncryptionManaged}, AWS, false},
+		{"kms with default key", &EncryptionConfig{Mode: interfaces.EncryptionKMS}, AWS, false},
+		{"kms without scope", &EncryptionConfig{Mode: interfaces.EncryptionKMS}, AZURE, true},
+		{"kms with scope", &EncryptionConfig{Mode: interfaces.EncryptionKMS, KMSKeyID: "replicas"}, AZURE, false},
+		{"customer key", &EncryptionConfig{Mode: interfaces.EncryptionCustomerKey, CustomerKey: key}, MINIO, false},
+		{"customer key too short", &EncryptionConfig{Mode: interfaces.EncryptionCustomerKey, CustomerKey: "c2hvcnQ="}, MINIO, true},
+		{"customer key not base64", &EncryptionConfig{Mode: interfaces.EncryptionCustomerKey, CustomerKey: "not base64!"}, GCS, true},
+		{"managed with key", &EncryptionConfig{Mode: interfaces.EncryptionManaged, KMSKeyID: "k"}, AWS, true},
+		{"unknown mode", &EncryptionConfig{Mode: "aes"}, AWS, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateEncryption(tt.enc, tt.provider); (err != nil) != tt.wantErr {
+				t.Errorf("validateEncryption() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,13 +2,26 @@ package database
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-const currentSchemaVersion = 2
+const currentSchemaVersion = 13
+
+// ErrAmbiguousTargetKey is returned when several objects of a mapping are
+// recorded under the same target key.
+var ErrAmbiguousTargetKey = errors.New("target key recorded for several objects")
+
+// Sync statuses that the database queries by. Objects are quarantined after
+// failing too many times in a row and are skipped until requeued.
+const (
+	StatusQuarantined = "quarantined"
+	StatusRequeued    = "requeued" // Released from quarantine; retried on the next run
+)
 
 type FileMetadata struct {
 	ID           int64
@@ -20,6 +33,84 @@ type FileMetadata struct {
 	ContentType  string
 	LastSynced   time.Time
 	SyncStatus   string
+	TargetKey    string    // Key the object was written to on the target
+	Checksum     string    // Content checksum computed while streaming, e.g. "md5:<hex>"
+	AttemptCount int       // Consecutive failed sync attempts; reset on success
+	LastError    string    // Error of the last failed attempt
+	NextRetryAt  time.Time // Earliest time a failed object is retried; zero when not scheduled
+	Owned        bool      // The mapping wrote the object at TargetKey, so it may delete it
+	TargetETag   string    // ETag the target reported for the copy at TargetKey; empty if unknown
+	TargetSize   int64     // Size the target reported for the copy at TargetKey
+	MetadataHash string    // Hash of the headers and user metadata of the synced object; empty before they were tracked
+	Encryption   string    // Encryption mode of the copy at TargetKey as the target reported it, e.g. "kms"; empty if unknown
+	TagsHash     string    // Hash of the tags the object was written with; empty when it had none
+}
+
+// MultipartUpload is a multipart upload in progress, stored so that it can
+// be resumed by a later run.
+type MultipartUpload struct {
+	MappingID   string
+	ObjectName  string
+	TargetKey   string
+	UploadID    string
+	SourceETag  string // ETag of the source object being uploaded; a changed object starts a new upload
+	Size        int64
+	PartSize    int64
+	ContentType string
+	CreatedAt   time.Time
+	Parts       []MultipartPart // Completed parts, ordered by number
+}
+
+// MultipartPart is a part of a multipart upload the target has stored.
+type MultipartPart struct {
+	Number     int
+	ETag       string
+	Size       int64
+	ContentMD5 string
+}
+
+// Statuses of a synchronization run of a mapping.
+const (
+	RunStatusRunning = "running" // Started and not finished; also left behind by a crashed process
+	RunStatusSuccess = "success"
+	RunStatusPartial = "partial" // Completed, but some objects failed
+	RunStatusFailed  = "failed"  // Aborted by an error
+)
+
+// SyncRun records one synchronization run of a mapping and what it did.
+type SyncRun struct {
+	ID               int64
+	MappingID        string
+	StartedAt        time.Time
+	FinishedAt       time.Time // Zero while the run is in progress
+	Synced           int64     // Objects uploaded, counted once per target
+	Skipped          int64
+	Failed           int64 // Objects that failed to upload or to be removed
+	Deleted          int64 // Target objects removed or trashed
+	BytesTransferred int64
+	Status           string
+	Error            string // Error that aborted the run
+}
+
+// Statuses of a source object version recorded in object_versions.
+const (
+	VersionStatusReplicated = "replicated" // Copied to the target; for a delete marker, deleted from it
+	VersionStatusSkipped    = "skipped"    // Delete marker left unapplied because of the delete policy
+)
+
+// ObjectVersion records a non-current version of a source object that was
+// handled for a target, so that later runs do not copy it again.
+type ObjectVersion struct {
+	MappingID    string
+	ObjectName   string
+	VersionID    string
+	DeleteMarker bool
+	Size         int64
+	ETag         string
+	LastModified time.Time
+	TargetKey    string
+	Status       string
+	SyncedAt     time.Time
 }
 
 type DB struct {
@@ -32,6 +123,10 @@ func NewDB(dbPath string) (*DB, error) {
 		return nil, fmt.Errorf("error opening database: %v", err)
 	}
 
+	// SQLite allows a single writer; serialize access so concurrent sync
+	// workers queue up instead of failing with "database is locked".
+	db.SetMaxOpenConns(1)
+
 	dbInstance := &DB{db: db}
 
 	if err := dbInstance.initializeSchema(); err != nil {
@@ -303,6 +398,110 @@ func (db *DB) applyMigration(tx *sql.Tx, version int) error {
 
 			return err
 		}
+
+	case 3:
+		_, err = tx.Exec(`
+			ALTER TABLE file_metadata ADD COLUMN target_key TEXT NOT NULL DEFAULT '';
+			UPDATE file_metadata SET target_key = object_name;
+			CREATE INDEX idx_file_metadata_mapping_target
+			ON file_metadata(mapping_id, target_key);
+		`)
+
+	case 4:
+		_, err = tx.Exec(`
+			ALTER TABLE file_metadata ADD COLUMN checksum TEXT NOT NULL DEFAULT '';
+		`)
+
+	case 5:
+		_, err = tx.Exec(`
+			ALTER TABLE file_metadata ADD COLUMN attempt_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE file_metadata ADD COLUMN last_error TEXT NOT NULL DEFAULT '';
+			ALTER TABLE file_metadata ADD COLUMN next_retry_at TIMESTAMP;
+		`)
+
+	case 6:
+		_, err = tx.Exec(`
+			CREATE TABLE multipart_uploads (
+				mapping_id TEXT NOT NULL,
+				object_name TEXT NOT NULL,
+				target_key TEXT NOT NULL,
+				upload_id TEXT NOT NULL,
+				source_etag TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				part_size INTEGER NOT NULL,
+				content_type TEXT NOT NULL DEFAULT '',
+				created_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (mapping_id, object_name)
+			);
+			CREATE TABLE multipart_parts (
+				mapping_id TEXT NOT NULL,
+				object_name TEXT NOT NULL,
+				part_number INTEGER NOT NULL,
+				etag TEXT NOT NULL,
+				size INTEGER NOT NULL,
+				content_md5 TEXT NOT NULL DEFAULT '',
+				PRIMARY KEY (mapping_id, object_name, part_number)
+			);
+		`)
+
+	case 7:
+		_, err = tx.Exec(`
+			CREATE TABLE sync_runs (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				mapping_id TEXT NOT NULL,
+				started_at TIMESTAMP NOT NULL,
+				finished_at TIMESTAMP,
+				synced INTEGER NOT NULL DEFAULT 0,
+				skipped INTEGER NOT NULL DEFAULT 0,
+				failed INTEGER NOT NULL DEFAULT 0,
+				deleted INTEGER NOT NULL DEFAULT 0,
+				bytes_transferred INTEGER NOT NULL DEFAULT 0,
+				status TEXT NOT NULL,
+				error TEXT NOT NULL DEFAULT ''
+			);
+			CREATE INDEX idx_sync_runs_mapping_started
+			ON sync_runs(mapping_id, started_at);
+		`)
+
+	case 8:
+		// Objects recorded before ownership was tracked count as written by
+		// the mapping if their last sync wrote them.
+		_, err = tx.Exec(`
+			ALTER TABLE file_metadata ADD COLUMN owned INTEGER NOT NULL DEFAULT 0;
+			UPDATE file_metadata SET owned = 1 WHERE sync_status IN ('success', 'failed_verify', 'retained');
+		`)
+
+	case 9:
+		_, err = tx.Exec(`
+			ALTER TABLE file_metadata ADD COLUMN target_etag TEXT NOT NULL DEFAULT '';
+			ALTER TABLE file_metadata ADD COLUMN target_size INTEGER NOT NULL DEFAULT 0;
+		`)
+
+	case 10:
+		_, err = tx.Exec(`
+			CREATE TABLE object_versions (
+				mapping_id TEXT NOT NULL,
+				object_name TEXT NOT NULL,
+				version_id TEXT NOT NULL,
+				delete_marker INTEGER NOT NULL DEFAULT 0,
+				size INTEGER NOT NULL DEFAULT 0,
+				etag TEXT NOT NULL DEFAULT '',
+				last_modified TIMESTAMP NOT NULL,
+				target_key TEXT NOT NULL,
+				status TEXT NOT NULL,
+				synced_at TIMESTAMP NOT NULL,
+				PRIMARY KEY (mapping_id, object_name, version_id)
+			);
+		`)
+
+	case 11:
+		_, err = tx.Exec(`ALTER TABLE file_metadata ADD COLUMN metadata_hash TEXT NOT NULL DEFAULT ''`)
+
+	case 12:
+		_, err = tx.Exec(`ALTER TABLE file_metadata ADD COLUMN encryption TEXT NOT NULL DEFAULT ''`)
+
+	case 13:
+		_, err = tx.Exec(`ALTER TABLE file_metadata ADD COLUMN tags_hash TEXT NOT NULL DEFAULT ''`)
 	}
 
 	if err != nil {
@@ -316,13 +515,17 @@ func (db *DB) Close() error {
 	return db.db.Close()
 }
 
-func (db *DB) GetFileMetadata(mappingID, objectName string) (*FileMetadata, error) {
+const fileMetadataColumns = `id, mapping_id, object_name, size, last_modified, etag, content_type, last_synced, sync_status, target_key, checksum, attempt_count, last_error, next_retry_at, owned, target_etag, target_size, metadata_hash, encryption, tags_hash`
+
+// scanner is implemented by *sql.Row and *sql.Rows.
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanFileMetadata(row scanner) (*FileMetadata, error) {
 	var metadata FileMetadata
-	err := db.db.QueryRow(`
-		SELECT id, mapping_id, object_name, size, last_modified, etag, content_type, last_synced, sync_status
-		FROM file_metadata
-		WHERE mapping_id = ? AND object_name = ?
-	`, mappingID, objectName).Scan(
+	var nextRetryAt sql.NullTime
+	err := row.Scan(
 		&metadata.ID,
 		&metadata.MappingID,
 		&metadata.ObjectName,
@@ -332,7 +535,31 @@ func (db *DB) GetFileMetadata(mappingID, objectName string) (*FileMetadata, erro
 		&metadata.ContentType,
 		&metadata.LastSynced,
 		&metadata.SyncStatus,
+		&metadata.TargetKey,
+		&metadata.Checksum,
+		&metadata.AttemptCount,
+		&metadata.LastError,
+		&nextRetryAt,
+		&metadata.Owned,
+		&metadata.TargetETag,
+		&metadata.TargetSize,
+		&metadata.MetadataHash,
+		&metadata.Encryption,
+		&metadata.TagsHash,
 	)
+	if err != nil {
+		return nil, err
+	}
+	metadata.NextRetryAt = nextRetryAt.Time
+	return &metadata, nil
+}
+
+func (db *DB) GetFileMetadata(mappingID, objectName string) (*FileMetadata, error) {
+	metadata, err := scanFileMetadata(db.db.QueryRow(`
+		SELECT `+fileMetadataColumns+`
+		FROM file_metadata
+		WHERE mapping_id = ? AND object_name = ?
+	`, mappingID, objectName))
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -340,21 +567,76 @@ func (db *DB) GetFileMetadata(mappingID, objectName string) (*FileMetadata, erro
 	if err != nil {
 		return nil, fmt.Errorf("error querying metadata: %v", err)
 	}
-	return &metadata, nil
+	return metadata, nil
+}
+
+// GetFileMetadataByTargetKey returns the metadata of the object a mapping
+// wrote to targetKey, or nil if there is none. Target keys are not unique,
+// since two source objects may have been synchronized to the same key, so it
+// returns ErrAmbiguousTargetKey when more than one object claims targetKey.
+func (db *DB) GetFileMetadataByTargetKey(mappingID, targetKey string) (*FileMetadata, error) {
+	rows, err := db.db.Query(`
+		SELECT `+fileMetadataColumns+`
+		FROM file_metadata
+		WHERE mapping_id = ? AND target_key = ?
+		ORDER BY object_name
+	`, mappingID, targetKey)
+	if err != nil {
+		return nil, fmt.Errorf("error querying metadata by target key: %v", err)
+	}
+	defer rows.Close()
+
+	var matches []*FileMetadata
+	for rows.Next() {
+		meta, err := scanFileMetadata(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning metadata: %v", err)
+		}
+		matches = append(matches, meta)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating results: %v", err)
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matches[0], nil
+	}
+	names := make([]string, len(matches))
+	for i, meta := range matches {
+		names[i] = meta.ObjectName
+	}
+	return nil, fmt.Errorf("%w: %s is recorded for objects %s", ErrAmbiguousTargetKey, targetKey, strings.Join(names, ", "))
 }
 
 func (db *DB) UpsertFileMetadata(metadata *FileMetadata) error {
+	targetKey := metadata.TargetKey
+	if targetKey == "" {
+		targetKey = metadata.ObjectName
+	}
+
+	var nextRetryAt sql.NullTime
+	if !metadata.NextRetryAt.IsZero() {
+		nextRetryAt = sql.NullTime{Time: metadata.NextRetryAt, Valid: true}
+	}
+
 	_, err := db.db.Exec(`
 		INSERT INTO file_metadata 
-		(mapping_id, object_name, size, last_modified, etag, content_type, last_synced, sync_status) 
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		(mapping_id, object_name, size, last_modified, etag, content_type, last_synced, sync_status, target_key, checksum,
+		 attempt_count, last_error, next_retry_at, owned, target_etag, target_size, metadata_hash, encryption, tags_hash) 
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		ON CONFLICT(mapping_id, object_name) DO UPDATE SET
-		size = ?, last_modified = ?, etag = ?, content_type = ?, last_synced = ?, sync_status = ?
+		size = ?, last_modified = ?, etag = ?, content_type = ?, last_synced = ?, sync_status = ?, target_key = ?, checksum = ?,
+		attempt_count = ?, last_error = ?, next_retry_at = ?, owned = ?, target_etag = ?, target_size = ?, metadata_hash = ?, encryption = ?, tags_hash = ?
 	`,
 		metadata.MappingID, metadata.ObjectName, metadata.Size, metadata.LastModified,
-		metadata.ETag, metadata.ContentType, metadata.LastSynced, metadata.SyncStatus,
+		metadata.ETag, metadata.ContentType, metadata.LastSynced, metadata.SyncStatus, targetKey, metadata.Checksum,
+		metadata.AttemptCount, metadata.LastError, nextRetryAt, metadata.Owned, metadata.TargetETag, metadata.TargetSize, metadata.MetadataHash, metadata.Encryption, metadata.TagsHash,
 		metadata.Size, metadata.LastModified, metadata.ETag, metadata.ContentType,
-		metadata.LastSynced, metadata.SyncStatus,
+		metadata.LastSynced, metadata.SyncStatus, targetKey, metadata.Checksum,
+		metadata.AttemptCount, metadata.LastError, nextRetryAt, metadata.Owned, metadata.TargetETag, metadata.TargetSize, metadata.MetadataHash, metadata.Encryption, metadata.TagsHash,
 	)
 
 	if err != nil {
@@ -365,7 +647,7 @@ func (db *DB) UpsertFileMetadata(metadata *FileMetadata) error {
 
 func (db *DB) ListFileMetadataByMapping(mappingID string) ([]*FileMetadata, error) {
 	rows, err := db.db.Query(`
-		SELECT id, mapping_id, object_name, size, last_modified, etag, content_type, last_synced, sync_status
+		SELECT `+fileMetadataColumns+`
 		FROM file_metadata
 		WHERE mapping_id = ?
 	`, mappingID)
@@ -377,22 +659,11 @@ func (db *DB) ListFileMetadataByMapping(mappingID string) ([]*FileMetadata, erro
 
 	var files []*FileMetadata
 	for rows.Next() {
-		var meta FileMetadata
-		err := rows.Scan(
-			&meta.ID,
-			&meta.MappingID,
-			&meta.ObjectName,
-			&meta.Size,
-			&meta.LastModified,
-			&meta.ETag,
-			&meta.ContentType,
-			&meta.LastSynced,
-			&meta.SyncStatus,
-		)
+		meta, err := scanFileMetadata(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning metadata: %v", err)
 		}
-		files = append(files, &meta)
+		files = append(files, meta)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -413,3 +684,305 @@ func (db *DB) DeleteFileMetadata(mappingID, objectName string) error {
 	}
 	return nil
 }
+
+// SetSyncStatus updates the sync status of an existing metadata row. It is a
+// no-op when the object has no stored metadata.
+func (db *DB) SetSyncStatus(mappingID, objectName, status string, lastSynced time.Time) error {
+	_, err := db.db.Exec(`
+		UPDATE file_metadata
+		SET sync_status = ?, last_synced = ?
+		WHERE mapping_id = ? AND object_name = ?
+	`, status, lastSynced, mappingID, objectName)
+
+	if err != nil {
+		return fmt.Errorf("error updating sync status: %v", err)
+	}
+	return nil
+}
+
+// ListQuarantined returns the quarantined objects of a mapping, or of every
+// mapping when mappingID is empty, ordered by mapping and object name.
+func (db *DB) ListQuarantined(mappingID string) ([]*FileMetadata, error) {
+	rows, err := db.db.Query(`
+		SELECT `+fileMetadataColumns+`
+		FROM file_metadata
+		WHERE sync_status = ? AND (? = '' OR mapping_id = ?)
+		ORDER BY mapping_id, object_name
+	`, StatusQuarantined, mappingID, mappingID)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing quarantined objects: %v", err)
+	}
+	defer rows.Close()
+
+	var files []*FileMetadata
+	for rows.Next() {
+		meta, err := scanFileMetadata(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning metadata: %v", err)
+		}
+		files = append(files, meta)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating results: %v", err)
+	}
+
+	return files, nil
+}
+
+// RequeueQuarantined releases quarantined objects so the next run retries
+// them, resetting their attempt count. An empty mappingID or objectName
+// matches every mapping or object. It returns the number of objects requeued.
+func (db *DB) RequeueQuarantined(mappingID, objectName string) (int64, error) {
+	result, err := db.db.Exec(`
+		UPDATE file_metadata
+		SET sync_status = ?, attempt_count = 0, next_retry_at = NULL
+		WHERE sync_status = ? AND (? = '' OR mapping_id = ?) AND (? = '' OR object_name = ?)
+	`, StatusRequeued, StatusQuarantined, mappingID, mappingID, objectName, objectName)
+
+	if err != nil {
+		return 0, fmt.Errorf("error requeuing quarantined objects: %v", err)
+	}
+	return result.RowsAffected()
+}
+
+// GetMultipartUpload returns the upload in progress for an object of a
+// mapping with its completed parts, or nil if there is none.
+func (db *DB) GetMultipartUpload(mappingID, objectName string) (*MultipartUpload, error) {
+	upload := MultipartUpload{MappingID: mappingID, ObjectName: objectName}
+	err := db.db.QueryRow(`
+		SELECT target_key, upload_id, source_etag, size, part_size, content_type, created_at
+		FROM multipart_uploads
+		WHERE mapping_id = ? AND object_name = ?
+	`, mappingID, objectName).Scan(
+		&upload.TargetKey, &upload.UploadID, &upload.SourceETag, &upload.Size,
+		&upload.PartSize, &upload.ContentType, &upload.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error querying multipart upload: %v", err)
+	}
+
+	rows, err := db.db.Query(`
+		SELECT part_number, etag, size, content_md5
+		FROM multipart_parts
+		WHERE mapping_id = ? AND object_name = ?
+		ORDER BY part_number
+	`, mappingID, objectName)
+	if err != nil {
+		return nil, fmt.Errorf("error listing multipart parts: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var part MultipartPart
+		if err := rows.Scan(&part.Number, &part.ETag, &part.Size, &part.ContentMD5); err != nil {
+			return nil, fmt.Errorf("error scanning multipart part: %v", err)
+		}
+		upload.Parts = append(upload.Parts, part)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating results: %v", err)
+	}
+
+	return &upload, nil
+}
+
+// CreateMultipartUpload records a new upload for an object, replacing any
+// previous one and its parts.
+func (db *DB) CreateMultipartUpload(upload *MultipartUpload) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteMultipartUpload(tx, upload.MappingID, upload.ObjectName); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO multipart_uploads
+		(mapping_id, object_name, target_key, upload_id, source_etag, size, part_size, content_type, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, upload.MappingID, upload.ObjectName, upload.TargetKey, upload.UploadID, upload.SourceETag,
+		upload.Size, upload.PartSize, upload.ContentType, upload.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error inserting multipart upload: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing multipart upload: %v", err)
+	}
+	return nil
+}
+
+// AddMultipartPart records a part of an upload as completed.
+func (db *DB) AddMultipartPart(mappingID, objectName string, part MultipartPart) error {
+	_, err := db.db.Exec(`
+		INSERT OR REPLACE INTO multipart_parts (mapping_id, object_name, part_number, etag, size, content_md5)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, mappingID, objectName, part.Number, part.ETag, part.Size, part.ContentMD5)
+
+	if err != nil {
+		return fmt.Errorf("error inserting multipart part: %v", err)
+	}
+	return nil
+}
+
+// DeleteMultipartUpload forgets the upload of an object and its parts.
+func (db *DB) DeleteMultipartUpload(mappingID, objectName string) error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := deleteMultipartUpload(tx, mappingID, objectName); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing multipart upload deletion: %v", err)
+	}
+	return nil
+}
+
+func deleteMultipartUpload(tx *sql.Tx, mappingID, objectName string) error {
+	_, err := tx.Exec(`
+		DELETE FROM multipart_parts WHERE mapping_id = ? AND object_name = ?
+	`, mappingID, objectName)
+	if err != nil {
+		return fmt.Errorf("error deleting multipart parts: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		DELETE FROM multipart_uploads WHERE mapping_id = ? AND object_name = ?
+	`, mappingID, objectName)
+	if err != nil {
+		return fmt.Errorf("error deleting multipart upload: %v", err)
+	}
+	return nil
+}
+
+// StartSyncRun records the start of a run and sets its ID.
+func (db *DB) StartSyncRun(run *SyncRun) error {
+	result, err := db.db.Exec(`
+		INSERT INTO sync_runs (mapping_id, started_at, status)
+		VALUES (?, ?, ?)
+	`, run.MappingID, run.StartedAt, run.Status)
+
+	if err != nil {
+		return fmt.Errorf("error inserting sync run: %v", err)
+	}
+	run.ID, err = result.LastInsertId()
+	return err
+}
+
+// FinishSyncRun records the outcome and statistics of a run.
+func (db *DB) FinishSyncRun(run *SyncRun) error {
+	_, err := db.db.Exec(`
+		UPDATE sync_runs
+		SET finished_at = ?, synced = ?, skipped = ?, failed = ?, deleted = ?,
+			bytes_transferred = ?, status = ?, error = ?
+		WHERE id = ?
+	`, run.FinishedAt, run.Synced, run.Skipped, run.Failed, run.Deleted,
+		run.BytesTransferred, run.Status, run.Error, run.ID)
+
+	if err != nil {
+		return fmt.Errorf("error updating sync run: %v", err)
+	}
+	return nil
+}
+
+// ListSyncRuns returns the most recent runs, newest first. An empty mappingID
+// or status matches every mapping or status; limit 0 returns every run.
+func (db *DB) ListSyncRuns(mappingID, status string, limit int) ([]*SyncRun, error) {
+	if limit <= 0 {
+		limit = -1 // No limit in SQLite
+	}
+	rows, err := db.db.Query(`
+		SELECT id, mapping_id, started_at, finished_at, synced, skipped, failed, deleted,
+			bytes_transferred, status, error
+		FROM sync_runs
+		WHERE (? = '' OR mapping_id = ?) AND (? = '' OR status = ?)
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, mappingID, mappingID, status, status, limit)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing sync runs: %v", err)
+	}
+	defer rows.Close()
+
+	var runs []*SyncRun
+	for rows.Next() {
+		var run SyncRun
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.MappingID, &run.StartedAt, &finishedAt, &run.Synced, &run.Skipped,
+			&run.Failed, &run.Deleted, &run.BytesTransferred, &run.Status, &run.Error); err != nil {
+			return nil, fmt.Errorf("error scanning sync run: %v", err)
+		}
+		run.FinishedAt = finishedAt.Time
+		runs = append(runs, &run)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating results: %v", err)
+	}
+
+	return runs, nil
+}
+
+// RecordObjectVersion records that a version of an object was handled for a
+// mapping, replacing any previous record of the same version.
+func (db *DB) RecordObjectVersion(version *ObjectVersion) error {
+	_, err := db.db.Exec(`
+		INSERT OR REPLACE INTO object_versions
+		(mapping_id, object_name, version_id, delete_marker, size, etag, last_modified, target_key, status, synced_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, version.MappingID, version.ObjectName, version.VersionID, version.DeleteMarker, version.Size, version.ETag,
+		version.LastModified, version.TargetKey, version.Status, version.SyncedAt)
+
+	if err != nil {
+		return fmt.Errorf("error recording object version: %v", err)
+	}
+	return nil
+}
+
+// ListObjectVersions returns the versions recorded for a mapping, oldest
+// first for each object. An empty objectName returns the versions of every
+// object.
+func (db *DB) ListObjectVersions(mappingID, objectName string) ([]*ObjectVersion, error) {
+	rows, err := db.db.Query(`
+		SELECT mapping_id, object_name, version_id, delete_marker, size, etag, last_modified, target_key, status, synced_at
+		FROM object_versions
+		WHERE mapping_id = ? AND (? = '' OR object_name = ?)
+		ORDER BY object_name, last_modified, synced_at
+	`, mappingID, objectName, objectName)
+
+	if err != nil {
+		return nil, fmt.Errorf("error listing object versions: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []*ObjectVersion
+	for rows.Next() {
+		var v ObjectVersion
+		if err := rows.Scan(&v.MappingID, &v.ObjectName, &v.VersionID, &v.DeleteMarker, &v.Size, &v.ETag,
+			&v.LastModified, &v.TargetKey, &v.Status, &v.SyncedAt); err != nil {
+			return nil, fmt.Errorf("error scanning object version: %v", err)
+		}
+		versions = append(versions, &v)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating results: %v", err)
+	}
+
+	return versions, nil
+}
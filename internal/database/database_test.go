@@ -1,6 +1,7 @@
 package database
 
 import (
+	"errors"
 	"path/filepath"
 	"testing"
 	"time"
@@ -27,6 +28,13 @@ func TestDB_UpsertGetDeleteFileMetadata(t *testing.T) {
 		ContentType:  "text/plain",
 		LastSynced:   time.Now().UTC().Truncate(time.Second),
 		SyncStatus:   "success",
+		Checksum:     "md5:0123456789abcdef0123456789abcdef",
+		Owned:        true,
+		TargetETag:   `"etag1"`,
+		TargetSize:   123,
+		MetadataHash: "abc123",
+		Encryption:   "kms",
+		TagsHash:     "def456",
 	}
 
 	// Upsert metadata
@@ -42,7 +50,9 @@ func TestDB_UpsertGetDeleteFileMetadata(t *testing.T) {
 	if got == nil {
 		t.Fatal("expected metadata, got nil")
 	}
-	if got.MappingID != fm.MappingID || got.ObjectName != fm.ObjectName || got.Size != fm.Size || got.ETag != fm.ETag || got.SyncStatus != fm.SyncStatus {
+	if got.MappingID != fm.MappingID || got.ObjectName != fm.ObjectName || got.Size != fm.Size || got.ETag != fm.ETag || got.SyncStatus != fm.SyncStatus || got.Checksum != fm.Checksum || got.Owned != fm.Owned ||
+		got.TargetETag != fm.TargetETag || got.TargetSize != fm.TargetSize ||
+		got.MetadataHash != fm.MetadataHash || got.Encryption != fm.Encryption || got.TagsHash != fm.TagsHash {
 		t.Errorf("got metadata %+v, want %+v", got, fm)
 	}
 
@@ -97,3 +107,241 @@ func TestDB_ListFileMetadataByMapping(t *testing.T) {
 		t.Errorf("expected %d entries, got %d", len(objects), len(list))
 	}
 }
+
+func TestDB_GetFileMetadataByTargetKey(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test3.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	fm := &FileMetadata{MappingID: "m", ObjectName: "events/a.json", TargetKey: "landing/2024/a.json", LastModified: now, LastSynced: now, SyncStatus: "success"}
+	if err := db.UpsertFileMetadata(fm); err != nil {
+		t.Fatalf("UpsertFileMetadata failed: %v", err)
+	}
+
+	got, err := db.GetFileMetadataByTargetKey("m", "landing/2024/a.json")
+	if err != nil {
+		t.Fatalf("GetFileMetadataByTargetKey failed: %v", err)
+	}
+	if got == nil || got.ObjectName != "events/a.json" {
+		t.Fatalf("expected events/a.json, got %+v", got)
+	}
+
+	// Without an explicit target key the object is written under its own name.
+	fm = &FileMetadata{MappingID: "m", ObjectName: "plain.txt", LastModified: now, LastSynced: now, SyncStatus: "success"}
+	if err := db.UpsertFileMetadata(fm); err != nil {
+		t.Fatalf("UpsertFileMetadata failed: %v", err)
+	}
+	got, err = db.GetFileMetadata("m", "plain.txt")
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if got == nil || got.TargetKey != "plain.txt" {
+		t.Fatalf("expected target key plain.txt, got %+v", got)
+	}
+
+	// Two objects recorded under one target key cannot be told apart.
+	fm = &FileMetadata{MappingID: "m", ObjectName: "events/b.json", TargetKey: "landing/2024/a.json", LastModified: now, LastSynced: now, SyncStatus: "success"}
+	if err := db.UpsertFileMetadata(fm); err != nil {
+		t.Fatalf("UpsertFileMetadata failed: %v", err)
+	}
+	got, err = db.GetFileMetadataByTargetKey("m", "landing/2024/a.json")
+	if !errors.Is(err, ErrAmbiguousTargetKey) {
+		t.Fatalf("expected ErrAmbiguousTargetKey, got %+v, %v", got, err)
+	}
+}
+
+func TestDB_QuarantineAndRequeue(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test4.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	rows := []*FileMetadata{
+		{MappingID: "m1", ObjectName: "bad.bin", LastModified: now, LastSynced: now, SyncStatus: StatusQuarantined, AttemptCount: 5, LastError: "AccessDenied"},
+		{MappingID: "m1", ObjectName: "flaky.bin", LastModified: now, LastSynced: now, SyncStatus: "failed_upload", AttemptCount: 2, LastError: "timeout", NextRetryAt: now.Add(time.Minute)},
+		{MappingID: "m2", ObjectName: "bad.bin", LastModified: now, LastSynced: now, SyncStatus: StatusQuarantined, AttemptCount: 5},
+	}
+	for _, fm := range rows {
+		if err := db.UpsertFileMetadata(fm); err != nil {
+			t.Fatalf("UpsertFileMetadata failed: %v", err)
+		}
+	}
+
+	got, err := db.GetFileMetadata("m1", "flaky.bin")
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if got.AttemptCount != 2 || got.LastError != "timeout" || !got.NextRetryAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("unexpected failure tracking fields: %+v", got)
+	}
+
+	quarantined, err := db.ListQuarantined("")
+	if err != nil {
+		t.Fatalf("ListQuarantined failed: %v", err)
+	}
+	if len(quarantined) != 2 || quarantined[0].MappingID != "m1" || quarantined[0].LastError != "AccessDenied" {
+		t.Fatalf("expected the two quarantined objects, got %+v", quarantined)
+	}
+
+	n, err := db.RequeueQuarantined("m1", "")
+	if err != nil {
+		t.Fatalf("RequeueQuarantined failed: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("expected 1 object requeued, got %d", n)
+	}
+	got, err = db.GetFileMetadata("m1", "bad.bin")
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if got.SyncStatus != StatusRequeued || got.AttemptCount != 0 || !got.NextRetryAt.IsZero() {
+		t.Errorf("expected a requeued object with reset attempts, got %+v", got)
+	}
+
+	quarantined, err = db.ListQuarantined("m2")
+	if err != nil {
+		t.Fatalf("ListQuarantined failed: %v", err)
+	}
+	if len(quarantined) != 1 {
+		t.Errorf("expected m2 to keep its quarantined object, got %d", len(quarantined))
+	}
+}
+
+func TestDB_MultipartUploads(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test5.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	if upload, err := db.GetMultipartUpload("m", "big.bin"); err != nil || upload != nil {
+		t.Fatalf("expected no upload, got %+v, %v", upload, err)
+	}
+
+	upload := &MultipartUpload{
+		MappingID: "m", ObjectName: "big.bin", TargetKey: "raw/big.bin", UploadID: "u1", SourceETag: "e1",
+		Size: 25, PartSize: 10, ContentType: "application/octet-stream", CreatedAt: time.Now().UTC().Truncate(time.Second),
+	}
+	if err := db.CreateMultipartUpload(upload); err != nil {
+		t.Fatalf("CreateMultipartUpload failed: %v", err)
+	}
+	for _, part := range []MultipartPart{{Number: 2, ETag: "p2", Size: 10}, {Number: 1, ETag: "p1", Size: 10, ContentMD5: "abc"}} {
+		if err := db.AddMultipartPart("m", "big.bin", part); err != nil {
+			t.Fatalf("AddMultipartPart failed: %v", err)
+		}
+	}
+
+	got, err := db.GetMultipartUpload("m", "big.bin")
+	if err != nil {
+		t.Fatalf("GetMultipartUpload failed: %v", err)
+	}
+	if got.UploadID != "u1" || got.TargetKey != "raw/big.bin" || got.PartSize != 10 || len(got.Parts) != 2 ||
+		got.Parts[0].Number != 1 || got.Parts[0].ContentMD5 != "abc" {
+		t.Fatalf("unexpected upload: %+v", got)
+	}
+
+	// A new upload for the same object replaces the old one and its parts.
+	upload.UploadID = "u2"
+	if err := db.CreateMultipartUpload(upload); err != nil {
+		t.Fatalf("CreateMultipartUpload failed: %v", err)
+	}
+	got, err = db.GetMultipartUpload("m", "big.bin")
+	if err != nil {
+		t.Fatalf("GetMultipartUpload failed: %v", err)
+	}
+	if got.UploadID != "u2" || len(got.Parts) != 0 {
+		t.Fatalf("expected a fresh upload, got %+v", got)
+	}
+
+	if err := db.DeleteMultipartUpload("m", "big.bin"); err != nil {
+		t.Fatalf("DeleteMultipartUpload failed: %v", err)
+	}
+	if got, err := db.GetMultipartUpload("m", "big.bin"); err != nil || got != nil {
+		t.Fatalf("expected the upload to be gone, got %+v, %v", got, err)
+	}
+}
+
+func TestDB_SyncRuns(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test6.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	start := time.Now().UTC().Truncate(time.Second)
+	for i, mappingID := range []string{"a", "b", "a"} {
+		run := &SyncRun{MappingID: mappingID, StartedAt: start.Add(time.Duration(i) * time.Minute), Status: RunStatusRunning}
+		if err := db.StartSyncRun(run); err != nil {
+			t.Fatalf("StartSyncRun failed: %v", err)
+		}
+		if i == 2 {
+			continue // Still running
+		}
+		run.FinishedAt = run.StartedAt.Add(30 * time.Second)
+		run.Synced, run.Failed, run.BytesTransferred, run.Status = 3, 1, 2048, RunStatusPartial
+		if err := db.FinishSyncRun(run); err != nil {
+			t.Fatalf("FinishSyncRun failed: %v", err)
+		}
+	}
+
+	runs, err := db.ListSyncRuns("a", "", 0)
+	if err != nil {
+		t.Fatalf("ListSyncRuns failed: %v", err)
+	}
+	if len(runs) != 2 || runs[0].Status != RunStatusRunning || !runs[0].FinishedAt.IsZero() {
+		t.Fatalf("expected the running run first, got %+v", runs)
+	}
+	if got := runs[1]; got.Synced != 3 || got.Failed != 1 || got.BytesTransferred != 2048 || !got.FinishedAt.Equal(start.Add(30*time.Second)) {
+		t.Errorf("unexpected finished run: %+v", got)
+	}
+
+	runs, err = db.ListSyncRuns("", RunStatusPartial, 1)
+	if err != nil {
+		t.Fatalf("ListSyncRuns failed: %v", err)
+	}
+	if len(runs) != 1 || runs[0].MappingID != "b" {
+		t.Errorf("expected the latest partial run, got %+v", runs)
+	}
+}
+
+func TestDB_ObjectVersions(t *testing.T) {
+	db, err := NewDB(filepath.Join(t.TempDir(), "test7.db"))
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	versions := []*ObjectVersion{
+		{MappingID: "m", ObjectName: "b.txt", VersionID: "v1", Size: 3, ETag: "e1", LastModified: now, TargetKey: "b.txt", Status: VersionStatusReplicated, SyncedAt: now},
+		{MappingID: "m", ObjectName: "a.txt", VersionID: "v2", DeleteMarker: true, LastModified: now.Add(time.Minute), TargetKey: "a.txt", Status: VersionStatusSkipped, SyncedAt: now},
+		{MappingID: "m", ObjectName: "a.txt", VersionID: "v1", Size: 5, ETag: "e0", LastModified: now, TargetKey: "a.txt", Status: VersionStatusReplicated, SyncedAt: now},
+		{MappingID: "other", ObjectName: "a.txt", VersionID: "v1", LastModified: now, TargetKey: "a.txt", Status: VersionStatusReplicated, SyncedAt: now},
+	}
+	for _, v := range versions {
+		if err := db.RecordObjectVersion(v); err != nil {
+			t.Fatalf("RecordObjectVersion failed: %v", err)
+		}
+	}
+
+	got, err := db.ListObjectVersions("m", "")
+	if err != nil {
+		t.Fatalf("ListObjectVersions failed: %v", err)
+	}
+	if len(got) != 3 || got[0].VersionID != "v1" || got[0].ObjectName != "a.txt" || !got[1].DeleteMarker || got[1].Status != VersionStatusSkipped || got[2].ObjectName != "b.txt" {
+		t.Fatalf("unexpected versions: %+v", got)
+	}
+
+	got, err = db.ListObjectVersions("m", "b.txt")
+	if err != nil {
+		t.Fatalf("ListObjectVersions failed: %v", err)
+	}
+	if len(got) != 1 || got[0].ETag != "e1" || got[0].Size != 3 {
+		t.Errorf("expected the version of b.txt, got %+v", got)
+	}
+}
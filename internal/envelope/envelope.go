@@ -0,0 +1,393 @@
+// Package envelope encrypts object content on the client before it is
+// uploaded, and decrypts it again on restore.
+//
+// Each object is encrypted with its own random data key using AES-256-GCM
+// over chunks of ChunkSize bytes. The data key is wrapped with a
+// key-encryption key, read from a key file or derived from a passphrase, and
+// stored with the algorithm in the object's user metadata.
+package envelope
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	gosync "sync"
+)
+
+const (
+	// Algorithm encrypts content in chunks with AES-256-GCM.
+	Algorithm = "AES-256-GCM-STREAM"
+	// ChunkSize is the number of plaintext bytes in each encrypted chunk.
+	ChunkSize = 64 << 10
+	// KeySize is the size in bytes of data and key-encryption keys.
+	KeySize = 32
+	// Iterations is the PBKDF2-SHA256 iteration count deriving a
+	// key-encryption key from a passphrase.
+	Iterations = 600000
+	// MaxIterations is the highest iteration count accepted from the
+	// metadata of an object, which bounds the cost of decrypting it.
+	MaxIterations = 10 * Iterations
+
+	overhead  = 16 // GCM tag appended to each chunk
+	nonceSize = 12
+	saltSize  = 16
+)
+
+// Key wraps recorded in MetaKeyWrap.
+const (
+	WrapKeyFile    = "AES-256-GCM"
+	WrapPassphrase = "PBKDF2-SHA256/AES-256-GCM"
+)
+
+// User metadata entries stored with encrypted objects.
+const (
+	MetaAlgorithm  = "cse-algorithm"
+	MetaChunkSize  = "cse-chunk-size"
+	MetaWrappedKey = "cse-wrapped-key" // Base64 nonce and sealed data key
+	MetaKeyWrap    = "cse-key-wrap"
+	MetaSalt       = "cse-kdf-salt"       // Base64, passphrase keys only
+	MetaIterations = "cse-kdf-iterations" // Passphrase keys only
+)
+
+var metaKeys = []string{MetaAlgorithm, MetaChunkSize, MetaWrappedKey, MetaKeyWrap, MetaSalt, MetaIterations}
+
+// ErrNotEncrypted is returned when decrypting an object that carries no
+// envelope metadata.
+var ErrNotEncrypted = errors.New("object is not client-side encrypted")
+
+// Keyring wraps and unwraps the data keys of objects with a key-encryption
+// key. It is safe for concurrent use.
+type Keyring struct {
+	kek        []byte // Key-file KEK, or the passphrase KEK for salt
+	passphrase string
+	salt       []byte
+
+	mu      gosync.Mutex
+	derived map[string][]byte // Passphrase KEKs by salt and iteration count, for unwrapping
+}
+
+// NewKeyFile returns a Keyring using the key in path, stored either as
+// KeySize raw bytes or base64-encoded.
+func NewKeyFile(path string) (*Keyring, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key file: %w", err)
+	}
+	kek := data
+	if len(data) != KeySize {
+		kek, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(kek) != KeySize {
+			return nil, fmt.Errorf("key file %s must hold %d bytes, raw or base64-encoded", path, KeySize)
+		}
+	}
+	return &Keyring{kek: kek}, nil
+}
+
+// NewPassphrase returns a Keyring deriving its key-encryption key from
+// passphrase. Objects it encrypts share a salt picked once, so the costly
+// derivation runs once per Keyring rather than per object.
+func NewPassphrase(passphrase string) (*Keyring, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("error generating salt: %w", err)
+	}
+	k := &Keyring{passphrase: passphrase, salt: salt, derived: make(map[string][]byte)}
+	kek, err := k.deriveKEK(salt, Iterations)
+	if err != nil {
+		return nil, err
+	}
+	k.kek = kek
+	return k, nil
+}
+
+// Encrypted reports whether metadata describes a client-side encrypted
+// object.
+func Encrypted(metadata map[string]string) bool {
+	return lookup(metadata, MetaAlgorithm) != ""
+}
+
+// StripMetadata returns metadata without its envelope entries.
+func StripMetadata(metadata map[string]string) map[string]string {
+	stripped := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		if !isMetaKey(k) {
+			stripped[k] = v
+		}
+	}
+	return stripped
+}
+
+// EncryptedSize returns the size of the encryption of size plaintext bytes.
+func EncryptedSize(size int64) int64 {
+	return size + overhead*chunks(size, ChunkSize)
+}
+
+// PlainSize returns the plaintext size of an encrypted object of size bytes
+// made of chunkSize chunks.
+func PlainSize(size int64, chunkSize int64) (int64, error) {
+	n := (size + chunkSize + overhead - 1) / (chunkSize + overhead)
+	plain := size - overhead*n
+	if n == 0 || plain < 0 || chunks(plain, chunkSize) != n {
+		return 0, fmt.Errorf("invalid encrypted size %d", size)
+	}
+	return plain, nil
+}
+
+// Encrypt returns a reader producing the encryption of the size bytes read
+// from r, the size of the encrypted content and the metadata to store with
+// it.
+func (k *Keyring) Encrypt(r io.Reader, size int64) (io.Reader, int64, map[string]string, error) {
+	dataKey := make([]byte, KeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, 0, nil, fmt.Errorf("error generating data key: %w", err)
+	}
+	wrapped, err := seal(k.kek, dataKey)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	metadata := map[string]string{
+		MetaAlgorithm:  Algorithm,
+		MetaChunkSize:  strconv.Itoa(ChunkSize),
+		MetaWrappedKey: base64.StdEncoding.EncodeToString(wrapped),
+		MetaKeyWrap:    WrapKeyFile,
+	}
+	if k.salt != nil {
+		metadata[MetaKeyWrap] = WrapPassphrase
+		metadata[MetaSalt] = base64.StdEncoding.EncodeToString(k.salt)
+		metadata[MetaIterations] = strconv.Itoa(Iterations)
+	}
+
+	reader := &chunkReader{src: r, aead: aead, remaining: size, chunkSize: ChunkSize, encrypt: true}
+	return reader, EncryptedSize(size), metadata, nil
+}
+
+// Decrypt returns a reader producing the plaintext of the encrypted object of
+// size bytes read from r, described by metadata, and the plaintext size.
+// Content that fails authentication surfaces as a read error.
+func (k *Keyring) Decrypt(r io.Reader, size int64, metadata map[string]string) (io.Reader, int64, error) {
+	if !Encrypted(metadata) {
+		return nil, 0, ErrNotEncrypted
+	}
+	if algorithm := lookup(metadata, MetaAlgorithm); algorithm != Algorithm {
+		return nil, 0, fmt.Errorf("unsupported encryption algorithm: %s", algorithm)
+	}
+	// Objects are only ever written with ChunkSize chunks, and the chunk
+	// buffer is sized from this entry.
+	chunkSize, err := strconv.ParseInt(lookup(metadata, MetaChunkSize), 10, 64)
+	if err != nil || chunkSize != ChunkSize {
+		return nil, 0, fmt.Errorf("invalid %s: %q", MetaChunkSize, lookup(metadata, MetaChunkSize))
+	}
+	plainSize, err := PlainSize(size, chunkSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	kek, err := k.unwrapKEK(metadata)
+	if err != nil {
+		return nil, 0, err
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(lookup(metadata, MetaWrappedKey))
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid %s: %w", MetaWrappedKey, err)
+	}
+	dataKey, err := open(kek, wrapped)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error unwrapping data key (wrong key or passphrase?): %w", err)
+	}
+	aead, err := newGCM(dataKey)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	reader := &chunkReader{src: r, aead: aead, remaining: size, chunkSize: chunkSize + overhead}
+	return reader, plainSize, nil
+}
+
+// unwrapKEK returns the key-encryption key that wrapped the data key of the
+// object described by metadata.
+func (k *Keyring) unwrapKEK(metadata map[string]string) ([]byte, error) {
+	switch wrap := lookup(metadata, MetaKeyWrap); wrap {
+	case WrapKeyFile:
+		if k.passphrase != "" {
+			return nil, errors.New("object was encrypted with a key file, not a passphrase")
+		}
+		return k.kek, nil
+	case WrapPassphrase:
+		if k.passphrase == "" {
+			return nil, errors.New("object was encrypted with a passphrase, not a key file")
+		}
+		salt, err := base64.StdEncoding.DecodeString(lookup(metadata, MetaSalt))
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("invalid %s", MetaSalt)
+		}
+		iterations, err := strconv.Atoi(lookup(metadata, MetaIterations))
+		if err != nil || iterations <= 0 || iterations > MaxIterations {
+			return nil, fmt.Errorf("invalid %s: %q", MetaIterations, lookup(metadata, MetaIterations))
+		}
+		return k.deriveKEK(salt, iterations)
+	default:
+		return nil, fmt.Errorf("unsupported key wrap: %s", wrap)
+	}
+}
+
+// deriveKEK derives the passphrase key-encryption key for salt, remembering
+// it for later objects sharing the salt.
+func (k *Keyring) deriveKEK(salt []byte, iterations int) ([]byte, error) {
+	id := string(salt) + "/" + strconv.Itoa(iterations)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if kek, ok := k.derived[id]; ok {
+		return kek, nil
+	}
+	kek, err := pbkdf2.Key(sha256.New, k.passphrase, salt, iterations, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("error deriving key from passphrase: %w", err)
+	}
+	k.derived[id] = kek
+	return kek, nil
+}
+
+// chunkReader seals or opens a stream of chunks. The nonce of each chunk is
+// its index followed by a flag marking the last one, so reordered, dropped or
+// truncated chunks fail authentication.
+type chunkReader struct {
+	src       io.Reader
+	aead      cipher.AEAD
+	remaining int64 // Input bytes left to read
+	chunkSize int64 // Input bytes per chunk
+	encrypt   bool
+	counter   uint32
+	started   bool
+	buf       []byte
+	out       []byte // Output not yet returned
+	err       error
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		if c.started && c.remaining == 0 {
+			c.err = io.EOF
+			return 0, c.err
+		}
+		if err := c.next(); err != nil {
+			c.err = err
+			return 0, err
+		}
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// next reads and processes the following chunk.
+func (c *chunkReader) next() error {
+	c.started = true
+	n := min(c.remaining, c.chunkSize)
+	if c.buf == nil {
+		c.buf = make([]byte, c.chunkSize+overhead)
+	}
+	in := c.buf[:n]
+	if _, err := io.ReadFull(c.src, in); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("error reading chunk %d: %w", c.counter, err)
+	}
+	c.remaining -= n
+
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint32(nonce[nonceSize-5:], c.counter)
+	if c.remaining == 0 {
+		nonce[nonceSize-1] = 1
+	}
+
+	var err error
+	if c.encrypt {
+		c.out = c.aead.Seal(in[:0], nonce, in, nil)
+	} else if c.out, err = c.aead.Open(in[:0], nonce, in, nil); err != nil {
+		return fmt.Errorf("chunk %d failed authentication: %w", c.counter, err)
+	}
+	c.counter++
+	return nil
+}
+
+func chunks(size, chunkSize int64) int64 {
+	if size == 0 {
+		return 1 // An empty object is one empty, authenticated chunk
+	}
+	return (size + chunkSize - 1) / chunkSize
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with key under a random nonce, which it prepends.
+func seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("error generating nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// open reverses seal.
+func open(key, sealed []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < nonceSize {
+		return nil, errors.New("sealed key too short")
+	}
+	return aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+}
+
+// lookup returns the metadata entry for key. Azure stores metadata names with
+// dashes replaced by underscores, so that spelling is accepted too.
+func lookup(metadata map[string]string, key string) string {
+	if v, ok := metadata[key]; ok {
+		return v
+	}
+	return metadata[strings.ReplaceAll(key, "-", "_")]
+}
+
+func isMetaKey(key string) bool {
+	key = strings.ReplaceAll(strings.ToLower(key), "_", "-")
+	for _, k := range metaKeys {
+		if key == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,251 @@
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, encoded bool) string {
+	t.Helper()
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatalf("rand.Read: %v", err)
+	}
+	data := key
+	if encoded {
+		data = []byte(base64.StdEncoding.EncodeToString(key) + "\n")
+	}
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func roundTrip(t *testing.T, encrypter, decrypter *Keyring, plain []byte) []byte {
+	t.Helper()
+	r, size, metadata, err := encrypter.Encrypt(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	ciphertext, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading encrypted content: %v", err)
+	}
+	if int64(len(ciphertext)) != size {
+		t.Fatalf("encrypted %d bytes, Encrypt reported size %d", len(ciphertext), size)
+	}
+	if len(plain) > 0 && bytes.Contains(ciphertext, plain) {
+		t.Fatal("encrypted content contains the plaintext")
+	}
+
+	r, plainSize, err := decrypter.Decrypt(bytes.NewReader(ciphertext), size, metadata)
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading decrypted content: %v", err)
+	}
+	if plainSize != int64(len(plain)) {
+		t.Errorf("Decrypt reported size %d, want %d", plainSize, len(plain))
+	}
+	return got
+}
+
+func TestKeyring_RoundTrip(t *testing.T) {
+	raw, err := NewKeyFile(writeKeyFile(t, false))
+	if err != nil {
+		t.Fatalf("NewKeyFile returned error: %v", err)
+	}
+	encoded, err := NewKeyFile(writeKeyFile(t, true))
+	if err != nil {
+		t.Fatalf("NewKeyFile (base64) returned error: %v", err)
+	}
+
+	sizes := []int{0, 1, ChunkSize - 1, ChunkSize, 3*ChunkSize + 17}
+	for _, k := range []*Keyring{raw, encoded} {
+		for _, size := range sizes {
+			plain := make([]byte, size)
+			rand.Read(plain)
+			if got := roundTrip(t, k, k, plain); !bytes.Equal(got, plain) {
+				t.Errorf("size %d: decrypted content differs from the original", size)
+			}
+		}
+	}
+}
+
+func TestKeyring_Passphrase(t *testing.T) {
+	encrypter, err := NewPassphrase("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewPassphrase returned error: %v", err)
+	}
+	// A separate Keyring, as when restoring in another process, derives the
+	// key from the salt stored with the object.
+	decrypter, err := NewPassphrase("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewPassphrase returned error: %v", err)
+	}
+
+	plain := []byte("backup contents")
+	if got := roundTrip(t, encrypter, decrypter, plain); !bytes.Equal(got, plain) {
+		t.Errorf("decrypted %q, want %q", got, plain)
+	}
+
+	_, size, metadata, err := encrypter.Encrypt(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	wrong, err := NewPassphrase("wrong")
+	if err != nil {
+		t.Fatalf("NewPassphrase returned error: %v", err)
+	}
+	if _, _, err := wrong.Decrypt(bytes.NewReader(nil), size, metadata); err == nil {
+		t.Error("Decrypt with the wrong passphrase succeeded")
+	}
+}
+
+func TestKeyring_DetectsTampering(t *testing.T) {
+	k, err := NewKeyFile(writeKeyFile(t, false))
+	if err != nil {
+		t.Fatalf("NewKeyFile returned error: %v", err)
+	}
+	plain := make([]byte, 2*ChunkSize+10)
+	r, size, metadata, err := k.Encrypt(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	ciphertext, _ := io.ReadAll(r)
+
+	tests := []struct {
+		name       string
+		ciphertext []byte
+	}{
+		{"flipped bit", func() []byte {
+			c := bytes.Clone(ciphertext)
+			c[ChunkSize+overhead+5] ^= 1
+			return c
+		}()},
+		{"swapped chunks", func() []byte {
+			c := bytes.Clone(ciphertext)
+			n := ChunkSize + overhead
+			copy(c[:n], ciphertext[n:2*n])
+			copy(c[n:2*n], ciphertext[:n])
+			return c
+		}()},
+		{"truncated after a full chunk", ciphertext[:2*(ChunkSize+overhead)]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _, err := k.Decrypt(bytes.NewReader(tt.ciphertext), int64(len(tt.ciphertext)), metadata)
+			if err == nil {
+				_, err = io.ReadAll(r)
+			}
+			if err == nil {
+				t.Error("tampered content decrypted without error")
+			}
+		})
+	}
+
+	// Content shorter than the size it is decrypted with
+	r, _, err = k.Decrypt(bytes.NewReader(ciphertext[:100]), size, metadata)
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if _, err := io.ReadAll(r); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("reading short content returned %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestDecrypt_Metadata(t *testing.T) {
+	k, err := NewKeyFile(writeKeyFile(t, false))
+	if err != nil {
+		t.Fatalf("NewKeyFile returned error: %v", err)
+	}
+	if _, _, err := k.Decrypt(bytes.NewReader(nil), 0, map[string]string{"owner": "a"}); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Decrypt of plain object returned %v, want %v", err, ErrNotEncrypted)
+	}
+
+	plain := []byte("stored on Azure")
+	r, size, metadata, err := k.Encrypt(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	ciphertext, _ := io.ReadAll(r)
+
+	// Azure turns the dashes of metadata names into underscores
+	azure := map[string]string{"owner": "a"}
+	for name, v := range metadata {
+		azure[string(bytes.ReplaceAll([]byte(name), []byte("-"), []byte("_")))] = v
+	}
+	if !Encrypted(azure) {
+		t.Fatal("Encrypted() = false for Azure metadata names")
+	}
+	if stripped := StripMetadata(azure); len(stripped) != 1 || stripped["owner"] != "a" {
+		t.Errorf("StripMetadata() = %v, want only owner", stripped)
+	}
+	r, _, err = k.Decrypt(bytes.NewReader(ciphertext), size, azure)
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if got, _ := io.ReadAll(r); !bytes.Equal(got, plain) {
+		t.Errorf("decrypted %q, want %q", got, plain)
+	}
+}
+
+func TestDecrypt_RejectsUnboundedMetadata(t *testing.T) {
+	k, err := NewPassphrase("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("NewPassphrase returned error: %v", err)
+	}
+	plain := []byte("backup contents")
+	r, size, metadata, err := k.Encrypt(bytes.NewReader(plain), int64(len(plain)))
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	ciphertext, _ := io.ReadAll(r)
+
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{MetaChunkSize, "0"},
+		{MetaChunkSize, "1024"},
+		{MetaChunkSize, "1073741824"},
+		{MetaChunkSize, "9223372036854775807"},
+		{MetaIterations, "0"},
+		{MetaIterations, "6000001"},
+		{MetaIterations, "9223372036854775807"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key+"="+tt.value, func(t *testing.T) {
+			tampered := maps.Clone(metadata)
+			tampered[tt.key] = tt.value
+			if _, _, err := k.Decrypt(bytes.NewReader(ciphertext), size, tampered); err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("Decrypt returned %v, want an invalid %s error", err, tt.key)
+			}
+		})
+	}
+}
+
+func TestPlainSize(t *testing.T) {
+	for _, size := range []int64{0, 1, ChunkSize, ChunkSize + 1, 10 * ChunkSize} {
+		got, err := PlainSize(EncryptedSize(size), ChunkSize)
+		if err != nil || got != size {
+			t.Errorf("PlainSize(EncryptedSize(%d)) = %d, %v", size, got, err)
+		}
+	}
+	for _, size := range []int64{0, 10, ChunkSize + overhead + 5} {
+		if _, err := PlainSize(size, ChunkSize); err == nil {
+			t.Errorf("PlainSize(%d) succeeded for an impossible size", size)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+// Package filter selects object keys using prefix, glob and regular expression rules.
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RuleType identifies how a rule's pattern is interpreted.
+type RuleType string
+
+const (
+	Prefix RuleType = "prefix" // Key starts with the pattern
+	Glob   RuleType = "glob"   // Shell-style pattern; "*" stops at "/", "**" crosses directories
+	Regex  RuleType = "regex"  // Go regular expression matched anywhere in the key
+)
+
+// Rule is a single include or exclude pattern.
+type Rule struct {
+	Type    RuleType `json:"type"`
+	Pattern string   `json:"pattern"`
+}
+
+// Matcher decides whether an object key is selected by a set of rules. A nil
+// Matcher selects every key.
+type Matcher struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// New compiles include and exclude rules into a Matcher. A key is selected
+// when it matches at least one include rule (or there are none) and no
+// exclude rule. It returns nil when there are no rules at all.
+func New(include, exclude []Rule) (*Matcher, error) {
+	if len(include) == 0 && len(exclude) == 0 {
+		return nil, nil
+	}
+
+	m := &Matcher{}
+	for i, rule := range include {
+		re, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("include rule %d: %w", i, err)
+		}
+		m.include = append(m.include, re)
+	}
+	for i, rule := range exclude {
+		re, err := compile(rule)
+		if err != nil {
+			return nil, fmt.Errorf("exclude rule %d: %w", i, err)
+		}
+		m.exclude = append(m.exclude, re)
+	}
+	return m, nil
+}
+
+// Match reports whether key is selected.
+func (m *Matcher) Match(key string) bool {
+	if m == nil {
+		return true
+	}
+
+	if len(m.include) > 0 && !matchAny(m.include, key) {
+		return false
+	}
+	return !matchAny(m.exclude, key)
+}
+
+func matchAny(patterns []*regexp.Regexp, key string) bool {
+	for _, re := range patterns {
+		if re.MatchString(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func compile(rule Rule) (*regexp.Regexp, error) {
+	if rule.Pattern == "" {
+		return nil, fmt.Errorf("empty %s pattern", rule.Type)
+	}
+
+	switch rule.Type {
+	case Prefix:
+		return regexp.MustCompile("^" + regexp.QuoteMeta(rule.Pattern)), nil
+	case Glob:
+		expr, err := globToRegexp(rule.Pattern)
+		if err != nil {
+			return nil, err
+		}
+		return regexp.Compile(expr)
+	case Regex:
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", rule.Pattern, err)
+		}
+		return re, nil
+	default:
+		return nil, fmt.Errorf("unknown rule type: %q", rule.Type)
+	}
+}
+
+// globToRegexp translates a glob into an anchored regular expression. "*"
+// and "?" never match "/", "**" matches across directories and "**/"
+// also matches zero directories.
+func globToRegexp(glob string) (string, error) {
+	var b strings.Builder
+	b.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				i++
+				if i+1 < len(glob) && glob[i+1] == '/' {
+					i++
+					b.WriteString("(?:.*/)?")
+				} else {
+					b.WriteString(".*")
+				}
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				return "", fmt.Errorf("invalid glob %q: unterminated character class", glob)
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+
+	b.WriteString("$")
+	return b.String(), nil
+}
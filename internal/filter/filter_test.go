@@ -0,0 +1,56 @@
+package filter
+
+import "testing"
+
+func TestMatcher_Match(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []Rule
+		exclude []Rule
+		key     string
+		want    bool
+	}{
+		{"no rules", nil, nil, "any/key", true},
+		{"prefix include", []Rule{{Prefix, "exports/"}}, nil, "exports/a.csv", true},
+		{"prefix include miss", []Rule{{Prefix, "exports/"}}, nil, "imports/a.csv", false},
+		{"glob double star", []Rule{{Glob, "exports/**/*.parquet"}}, nil, "exports/2024/01/part-0.parquet", true},
+		{"glob double star zero dirs", []Rule{{Glob, "exports/**/*.parquet"}}, nil, "exports/part-0.parquet", true},
+		{"glob wrong extension", []Rule{{Glob, "exports/**/*.parquet"}}, nil, "exports/2024/part-0.csv", false},
+		{"glob star stops at slash", []Rule{{Glob, "*.parquet"}}, nil, "exports/part-0.parquet", false},
+		{"glob class", []Rule{{Glob, "log-[0-9].txt"}}, nil, "log-7.txt", true},
+		{"glob negated class", []Rule{{Glob, "log-[!0-9].txt"}}, nil, "log-7.txt", false},
+		{"exclude prefix", nil, []Rule{{Prefix, "tmp/"}}, "tmp/scratch", false},
+		{"exclude wins over include", []Rule{{Glob, "**"}}, []Rule{{Regex, `\.tmp$`}}, "data/file.tmp", false},
+		{"regex include", []Rule{{Regex, `^[a-z]+/\d+$`}}, nil, "events/42", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("New returned error: %v", err)
+			}
+			if got := m.Match(tt.key); got != tt.want {
+				t.Errorf("Match(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNew_InvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"bad regex", Rule{Regex, "("}},
+		{"unterminated class", Rule{Glob, "file[0-9"}},
+		{"unknown type", Rule{"suffix", ".txt"}},
+		{"empty pattern", Rule{Prefix, ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New([]Rule{tt.rule}, nil); err == nil {
+				t.Fatal("expected error, got nil")
+			}
+		})
+	}
+}
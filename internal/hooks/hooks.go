@@ -0,0 +1,253 @@
+// Package hooks runs local commands and calls webhooks when a mapping's
+// synchronization starts, finishes, uploads an object or fails.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// DefaultTimeout bounds a hook that sets no timeout.
+const DefaultTimeout = 30 * time.Second
+
+// maxOutput is how much of a failing hook's output or response body is kept
+// for its error message.
+const maxOutput = 4 << 10
+
+// Event is when a hook runs.
+type Event string
+
+const (
+	BeforeRun   Event = "beforeRun"   // Before the mapping is listed; a failing hook vetoes the run
+	AfterRun    Event = "afterRun"    // After every run that was not vetoed, whatever its outcome
+	AfterUpload Event = "afterUpload" // After each object is uploaded to a target
+	OnFailure   Event = "onFailure"   // After a run that was vetoed, failed, or failed to copy some objects
+)
+
+// Hook runs a command or calls a URL with the event payload as JSON.
+type Hook struct {
+	Command []string          `json:"command,omitempty"` // Program and arguments; the payload is written to its stdin
+	URL     string            `json:"url,omitempty"`     // HTTP(S) endpoint the payload is POSTed to
+	Headers map[string]string `json:"headers,omitempty"` // Extra request headers, e.g. Authorization
+	Timeout string            `json:"timeout,omitempty"` // Go duration, e.g. "10s" (default 30s)
+}
+
+// Config lists the hooks of each event.
+type Config struct {
+	BeforeRun   []Hook `json:"beforeRun,omitempty"`
+	AfterRun    []Hook `json:"afterRun,omitempty"`
+	AfterUpload []Hook `json:"afterUpload,omitempty"`
+	OnFailure   []Hook `json:"onFailure,omitempty"`
+}
+
+// Payload describes an event to the hooks.
+type Payload struct {
+	Event   Event     `json:"event"`
+	Mapping string    `json:"mapping"`
+	Time    time.Time `json:"time"`
+	Run     *Run      `json:"run,omitempty"`
+	Object  *Object   `json:"object,omitempty"` // afterUpload only
+	Error   string    `json:"error,omitempty"`  // Why the run was vetoed or failed
+}
+
+// Run is the state of a synchronization run.
+type Run struct {
+	ID               int64      `json:"id,omitempty"` // sync_runs row, when recorded
+	Status           string     `json:"status"`
+	StartedAt        time.Time  `json:"startedAt"`
+	FinishedAt       *time.Time `json:"finishedAt,omitempty"`
+	Synced           int64      `json:"synced"`
+	Skipped          int64      `json:"skipped"`
+	Failed           int64      `json:"failed"`
+	Deleted          int64      `json:"deleted"`
+	BytesTransferred int64      `json:"bytesTransferred"`
+}
+
+// Object is an object uploaded to a target.
+type Object struct {
+	Key            string `json:"key"` // Source key
+	TargetProvider string `json:"targetProvider"`
+	TargetBucket   string `json:"targetBucket"`
+	TargetKey      string `json:"targetKey"`
+	Size           int64  `json:"size"`
+	ETag           string `json:"etag,omitempty"`
+	Checksum       string `json:"checksum,omitempty"`
+}
+
+// hook is a validated Hook.
+type hook struct {
+	Hook
+	timeout time.Duration
+}
+
+// Runner runs the hooks of a mapping. A nil Runner runs nothing.
+type Runner struct {
+	hooks  map[Event][]hook
+	client *http.Client
+}
+
+// New returns a Runner for the hooks of every configuration, in order, so
+// global hooks can run before those of a mapping. It returns nil when there
+// are no hooks.
+func New(configs ...*Config) (*Runner, error) {
+	r := &Runner{hooks: make(map[Event][]hook), client: &http.Client{}}
+	for _, cfg := range configs {
+		if cfg == nil {
+			continue
+		}
+		for event, hooks := range map[Event][]Hook{
+			BeforeRun:   cfg.BeforeRun,
+			AfterRun:    cfg.AfterRun,
+			AfterUpload: cfg.AfterUpload,
+			OnFailure:   cfg.OnFailure,
+		} {
+			for i, h := range hooks {
+				parsed, err := parse(h)
+				if err != nil {
+					return nil, fmt.Errorf("%s hook %d: %w", event, i, err)
+				}
+				r.hooks[event] = append(r.hooks[event], parsed)
+			}
+		}
+	}
+	if len(r.hooks) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+func parse(h Hook) (hook, error) {
+	parsed := hook{Hook: h, timeout: DefaultTimeout}
+	switch {
+	case len(h.Command) > 0 && h.URL != "":
+		return parsed, errors.New("command and url are mutually exclusive")
+	case len(h.Command) > 0:
+		if h.Command[0] == "" {
+			return parsed, errors.New("command must name a program")
+		}
+		if len(h.Headers) > 0 {
+			return parsed, errors.New("headers only apply to url hooks")
+		}
+	case h.URL != "":
+		u, err := url.Parse(h.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return parsed, fmt.Errorf("url must be an http or https URL: %q", h.URL)
+		}
+	default:
+		return parsed, errors.New("either command or url must be set")
+	}
+
+	if h.Timeout != "" {
+		timeout, err := time.ParseDuration(h.Timeout)
+		if err != nil || timeout <= 0 {
+			return parsed, fmt.Errorf("timeout must be a positive duration: %q", h.Timeout)
+		}
+		parsed.timeout = timeout
+	}
+	return parsed, nil
+}
+
+// Has reports whether any hook runs on event.
+func (r *Runner) Has(event Event) bool {
+	return r != nil && len(r.hooks[event]) > 0
+}
+
+// Run runs the hooks of p.Event in order, each within its timeout, and
+// stops at the first one that fails.
+func (r *Runner) Run(ctx context.Context, p Payload) error {
+	if !r.Has(p.Event) {
+		return nil
+	}
+	if p.Time.IsZero() {
+		p.Time = time.Now().UTC()
+	}
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("error encoding %s payload: %w", p.Event, err)
+	}
+
+	for i, h := range r.hooks[p.Event] {
+		if err := r.run(ctx, h, p.Event, body); err != nil {
+			return fmt.Errorf("%s hook %d: %w", p.Event, i, err)
+		}
+	}
+	return nil
+}
+
+func (r *Runner) run(ctx context.Context, h hook, event Event, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	var err error
+	if h.URL != "" {
+		err = r.post(ctx, h, body)
+	} else {
+		err = execute(ctx, h, event, body)
+	}
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %s: %w", h.timeout, err)
+	}
+	return err
+}
+
+// execute runs a command hook with the payload on stdin. The event name is
+// also set in CLOUD_DATA_SYNC_EVENT.
+func execute(ctx context.Context, h hook, event Event, body []byte) error {
+	cmd := exec.CommandContext(ctx, h.Command[0], h.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(), "CLOUD_DATA_SYNC_EVENT="+string(event))
+	output := &limitedBuffer{}
+	cmd.Stdout, cmd.Stderr = output, output
+	cmd.WaitDelay = time.Second // Do not wait on children holding the output open after a kill
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command %s failed: %w: %s", h.Command[0], err, bytes.TrimSpace(output.Bytes()))
+	}
+	return nil
+}
+
+// post sends the payload to a URL hook. Any status other than 2xx fails.
+func (r *Runner) post(ctx context.Context, h hook, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range h.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %w", req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		text, _ := io.ReadAll(io.LimitReader(resp.Body, maxOutput))
+		return fmt.Errorf("%s returned %s: %s", req.URL.Redacted(), resp.Status, bytes.TrimSpace(text))
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxOutput)) // Let the connection be reused
+	return nil
+}
+
+// limitedBuffer keeps the first maxOutput bytes written to it and discards
+// the rest.
+type limitedBuffer struct {
+	bytes.Buffer
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := maxOutput - b.Len(); room > 0 {
+		b.Buffer.Write(p[:min(len(p), room)])
+	}
+	return len(p), nil
+}
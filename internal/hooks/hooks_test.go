@@ -0,0 +1,128 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        *Config
+		wantRunner bool
+		wantErr    bool
+	}{
+		{"no hooks", nil, false, false},
+		{"empty config", &Config{}, false, false},
+		{"command", &Config{BeforeRun: []Hook{{Command: []string{"./validate.sh"}, Timeout: "1m"}}}, true, false},
+		{"url", &Config{AfterRun: []Hook{{URL: "https://airflow.example.com/api/v1/dags/x/dagRuns", Headers: map[string]string{"Authorization": "Basic x"}}}}, true, false},
+		{"command and url", &Config{OnFailure: []Hook{{Command: []string{"true"}, URL: "http://localhost"}}}, false, true},
+		{"neither", &Config{AfterUpload: []Hook{{Timeout: "1s"}}}, false, true},
+		{"unsupported scheme", &Config{AfterRun: []Hook{{URL: "ftp://example.com"}}}, false, true},
+		{"headers on command", &Config{AfterRun: []Hook{{Command: []string{"true"}, Headers: map[string]string{"a": "b"}}}}, false, true},
+		{"invalid timeout", &Config{AfterRun: []Hook{{Command: []string{"true"}, Timeout: "soon"}}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (r != nil) != tt.wantRunner {
+				t.Errorf("New() runner = %v, want runner %v", r, tt.wantRunner)
+			}
+		})
+	}
+}
+
+func TestRunner_Command(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "payload.json")
+	r, err := New(&Config{
+		BeforeRun: []Hook{{Command: []string{"sh", "-c", `cat > "$0" && test "$CLOUD_DATA_SYNC_EVENT" = beforeRun`, out}}},
+		OnFailure: []Hook{{Command: []string{"sh", "-c", "echo database is locked >&2; exit 3"}}},
+		AfterRun:  []Hook{{Command: []string{"sleep", "5"}, Timeout: "50ms"}},
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	started := time.Date(2025, 5, 1, 2, 0, 0, 0, time.UTC)
+	if err := r.Run(context.Background(), Payload{Event: BeforeRun, Mapping: "m", Run: &Run{Status: "running", StartedAt: started}}); err != nil {
+		t.Fatalf("Run(beforeRun) returned error: %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("reading payload written by the hook: %v", err)
+	}
+	var got Payload
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("hook received invalid JSON %q: %v", data, err)
+	}
+	if got.Event != BeforeRun || got.Mapping != "m" || got.Run == nil || !got.Run.StartedAt.Equal(started) || got.Time.IsZero() {
+		t.Errorf("hook received %+v", got)
+	}
+
+	err = r.Run(context.Background(), Payload{Event: OnFailure})
+	if err == nil || !strings.Contains(err.Error(), "database is locked") {
+		t.Errorf("Run(onFailure) error = %v, want the command's exit status and output", err)
+	}
+
+	start := time.Now()
+	err = r.Run(context.Background(), Payload{Event: AfterRun})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Run(afterRun) error = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timed out hook returned after %s", elapsed)
+	}
+
+	if err := r.Run(context.Background(), Payload{Event: AfterUpload}); err != nil {
+		t.Errorf("Run for an event without hooks returned error: %v", err)
+	}
+}
+
+func TestRunner_URL(t *testing.T) {
+	var received []Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost || req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Bearer token" {
+			t.Errorf("request %s with headers %v, want an authorized JSON POST", req.Method, req.Header)
+		}
+		var p Payload
+		body, _ := io.ReadAll(req.Body)
+		if err := json.Unmarshal(body, &p); err != nil {
+			t.Errorf("invalid payload %q: %v", body, err)
+		}
+		received = append(received, p)
+		if p.Event == BeforeRun {
+			http.Error(w, "source not ready", http.StatusConflict)
+		}
+	}))
+	defer server.Close()
+
+	hook := Hook{URL: server.URL + "/hook", Headers: map[string]string{"Authorization": "Bearer token"}}
+	r, err := New(&Config{AfterUpload: []Hook{hook}}, &Config{BeforeRun: []Hook{hook}})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	object := &Object{Key: "a.csv", TargetProvider: "s3", TargetBucket: "b", TargetKey: "a.csv", Size: 10}
+	if err := r.Run(context.Background(), Payload{Event: AfterUpload, Mapping: "m", Object: object}); err != nil {
+		t.Fatalf("Run(afterUpload) returned error: %v", err)
+	}
+	err = r.Run(context.Background(), Payload{Event: BeforeRun, Mapping: "m"})
+	if err == nil || !strings.Contains(err.Error(), "409") || !strings.Contains(err.Error(), "source not ready") {
+		t.Errorf("Run(beforeRun) error = %v, want the status and body of the response", err)
+	}
+
+	if len(received) != 2 || received[0].Object == nil || *received[0].Object != *object {
+		t.Errorf("server received %+v", received)
+	}
+}
@@ -0,0 +1,31 @@
+package interfaces
+
+// EncryptionMode is how a provider encrypts the objects it stores.
+type EncryptionMode string
+
+const (
+	// EncryptionDefault leaves encryption to the bucket's configuration.
+	EncryptionDefault EncryptionMode = ""
+	// EncryptionManaged encrypts with keys the provider manages: SSE-S3 on
+	// S3 and MinIO. GCS and Azure always encrypt this way by default.
+	EncryptionManaged EncryptionMode = "managed"
+	// EncryptionKMS encrypts with a key of the provider's key service:
+	// SSE-KMS on S3 and MinIO, a Cloud KMS key (CMEK) on GCS and an
+	// encryption scope on Azure.
+	EncryptionKMS EncryptionMode = "kms"
+	// EncryptionCustomerKey encrypts with a key sent along with every
+	// request, which is then needed to read the object: SSE-C on S3 and
+	// MinIO, customer-supplied keys on GCS and customer-provided keys (CPK)
+	// on Azure.
+	EncryptionCustomerKey EncryptionMode = "customer-key"
+)
+
+// CustomerKeySize is the size in bytes of a customer key (AES-256).
+const CustomerKeySize = 32
+
+// Encryption selects how an object is encrypted at rest.
+type Encryption struct {
+	Mode  EncryptionMode
+	KeyID string // KMS key for EncryptionKMS: AWS KMS key ID or ARN, MinIO KMS key name, GCS Cloud KMS key name, Azure encryption scope
+	Key   []byte // CustomerKeySize bytes for EncryptionCustomerKey
+}
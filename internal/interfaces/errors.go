@@ -0,0 +1,68 @@
+package interfaces
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+// Sentinel errors that providers map their SDK errors into, so callers can
+// make decisions with errors.Is regardless of the provider.
+var (
+	ErrNotFound           = errors.New("object not found")
+	ErrBucketNotFound     = errors.New("bucket not found")
+	ErrPermissionDenied   = errors.New("permission denied")
+	ErrThrottled          = errors.New("request throttled")
+	ErrTransient          = errors.New("transient failure")
+	ErrPreconditionFailed = errors.New("precondition failed")
+)
+
+// classifiedError marks an error with a sentinel without changing its message.
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string   { return e.err.Error() }
+func (e *classifiedError) Unwrap() []error { return []error{e.kind, e.err} }
+
+// Classify returns err marked so that errors.Is(err, kind) reports true while
+// the original error remains reachable. It returns err unchanged when kind or
+// err is nil.
+func Classify(kind, err error) error {
+	if kind == nil || err == nil {
+		return err
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+// ClassifyStatus marks err with the sentinel matching an HTTP status code.
+// Network failures (timeouts, connection resets, truncated responses) are
+// marked ErrTransient. err is returned unchanged when neither applies.
+func ClassifyStatus(status int, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch {
+	case status == http.StatusNotFound:
+		return Classify(ErrNotFound, err)
+	case status == http.StatusUnauthorized, status == http.StatusForbidden:
+		return Classify(ErrPermissionDenied, err)
+	case status == http.StatusPreconditionFailed, status == http.StatusNotModified:
+		return Classify(ErrPreconditionFailed, err)
+	case status == http.StatusTooManyRequests, status == http.StatusServiceUnavailable:
+		return Classify(ErrThrottled, err)
+	case status == http.StatusRequestTimeout, status >= 500:
+		return Classify(ErrTransient, err)
+	}
+
+	var netErr net.Error
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) ||
+		(errors.As(err, &netErr) && netErr.Timeout()) {
+		return Classify(ErrTransient, err)
+	}
+	return err
+}
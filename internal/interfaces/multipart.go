@@ -0,0 +1,59 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+)
+
+// RangeReader is implemented by providers that can read part of an object,
+// which lets an interrupted multipart upload resume from its first missing
+// part instead of reading the object from the start. StatObject returns the
+// headers and metadata of the whole object without reading its content.
+type RangeReader interface {
+	StatObject(ctx context.Context, bucketName, objectName string) (*ObjectInfo, error)
+	GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error)
+}
+
+// MultipartUpload identifies an upload made of parts. Its fields are stored
+// between runs, so a provider must be able to continue the upload from them
+// alone, possibly in a new process.
+type MultipartUpload struct {
+	Bucket   string
+	Key      string
+	UploadID string        // Provider identifier of the upload: S3 upload ID, GCS session URI, Azure block ID prefix
+	Size     int64         // Size of the whole object
+	PartSize int64         // Size of every part but the last
+	Options  UploadOptions // Not stored: the caller sets them again when it continues an upload
+}
+
+// Offset returns the position in the object of part number, starting at 1.
+func (u *MultipartUpload) Offset(number int) int64 {
+	return int64(number-1) * u.PartSize
+}
+
+// Parts returns the number of parts the object is split into.
+func (u *MultipartUpload) Parts() int {
+	if u.PartSize <= 0 {
+		return 0
+	}
+	return int((u.Size + u.PartSize - 1) / u.PartSize)
+}
+
+// CompletedPart is a part stored by the provider.
+type CompletedPart struct {
+	Number     int    // Starting at 1
+	ETag       string // Provider identifier of the part, passed back on completion
+	Size       int64
+	ContentMD5 string // Hex MD5 of the part as reported by the provider, empty if none
+}
+
+// MultipartUploader is implemented by providers that can upload an object in
+// parts which survive a restart of the process. Parts are uploaded in order,
+// each one except the last being exactly PartSize bytes.
+type MultipartUploader interface {
+	// CreateMultipartUpload starts an upload and sets upload.UploadID.
+	CreateMultipartUpload(ctx context.Context, upload *MultipartUpload) error
+	UploadPart(ctx context.Context, upload *MultipartUpload, number int, reader io.Reader, size int64) (*CompletedPart, error)
+	CompleteMultipartUpload(ctx context.Context, upload *MultipartUpload, parts []CompletedPart) (*UploadInfo, error)
+	AbortMultipartUpload(ctx context.Context, upload *MultipartUpload) error
+}
@@ -3,17 +3,63 @@ package interfaces
 import (
 	"context"
 	"io"
+	"strings"
 	"time"
 )
 
 type ObjectInfo struct {
-	Name         string
-	Bucket       string
-	Size         int64
-	ContentType  string
-	LastModified time.Time
-	ETag         string
-	Metadata     map[string]string
+	Name               string
+	Bucket             string
+	Size               int64
+	ContentType        string
+	CacheControl       string
+	ContentEncoding    string
+	ContentDisposition string
+	StorageClass       string // Provider name of the class or access tier the object is stored in; empty if unknown
+	LastModified       time.Time
+	ETag               string
+	Metadata           map[string]string // User metadata, keys in lower case; see NormalizeMetadata
+	VersionID          string            // Set when the info describes a version other than the current one; see VersionLister
+}
+
+// UploadOptions returns the options that store an object with the same
+// headers and user metadata as the object info describes.
+func (o *ObjectInfo) UploadOptions() UploadOptions {
+	return UploadOptions{
+		ContentType:        o.ContentType,
+		CacheControl:       o.CacheControl,
+		ContentEncoding:    o.ContentEncoding,
+		ContentDisposition: o.ContentDisposition,
+		Metadata:           o.Metadata,
+	}
+}
+
+// UploadOptions holds the standard HTTP headers and user metadata an object
+// is stored with. Empty headers are not set.
+type UploadOptions struct {
+	ContentType        string
+	CacheControl       string
+	ContentEncoding    string
+	ContentDisposition string
+	Metadata           map[string]string // User metadata, keys in lower case
+	Tags               map[string]string // Object tags; see TagReader
+	StorageClass       string            // Class or access tier named after any provider's, converted with package storageclass; empty for the bucket default
+	Encryption         *Encryption       // Encryption at rest; nil for the one the provider client is configured with
+}
+
+// NormalizeMetadata returns the metadata with lower case keys, the form in
+// which every provider reports it, or nil when there is none. Providers
+// differ in the casing they keep: S3 and MinIO canonicalize keys as HTTP
+// headers while GCS and Azure keep them as written.
+func NormalizeMetadata(metadata map[string]string) map[string]string {
+	if len(metadata) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(metadata))
+	for k, v := range metadata {
+		normalized[strings.ToLower(k)] = v
+	}
+	return normalized
 }
 
 type UploadInfo struct {
@@ -21,12 +67,30 @@ type UploadInfo struct {
 	Key    string
 	ETag   string
 	Size   int64
+	// Checksums of the stored content as reported by the provider, hex
+	// encoded. Empty when the provider did not report one.
+	ContentMD5 string
+	CRC32C     string // Castagnoli polynomial
+
+	Encryption EncryptionMode // Encryption the object was written with
+}
+
+// UnknownSize is passed to UploadObject when the size of the content is not
+// known before it has been read, e.g. after compression.
+const UnknownSize = -1
+
+// SizeRequirer is implemented by providers that may need to know the size of
+// the content passed to UploadObject in advance. Other providers accept
+// UnknownSize.
+type SizeRequirer interface {
+	// RequiresSize reports whether UploadObject needs the exact size.
+	RequiresSize() bool
 }
 
 type StorageProvider interface {
-	ListObjects(ctx context.Context, bucketName string) (map[string]*ObjectInfo, error)
+	ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*ObjectInfo, error)
 	GetObject(ctx context.Context, bucketName, objectName string) (*ObjectInfo, io.ReadCloser, error)
-	UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*UploadInfo, error)
+	UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts UploadOptions) (*UploadInfo, error)
 	DeleteObject(ctx context.Context, bucketName, objectName string) error
 	BucketExists(ctx context.Context, bucketName string) (bool, error)
 	EnsureBucketExists(ctx context.Context, bucketName string) error
@@ -0,0 +1,16 @@
+package interfaces
+
+import "context"
+
+// TagReader is implemented by providers that can read the tags of an object:
+// S3 and MinIO object tags, Azure blob index tags, or GCS custom metadata
+// entries named TagMetadataPrefix plus the tag key. Tags are written with
+// UploadOptions.Tags.
+type TagReader interface {
+	// GetObjectTags returns the tags of an object, or nil if it has none.
+	GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error)
+}
+
+// TagMetadataPrefix marks the custom metadata entries that hold tags on
+// providers without native object tags.
+const TagMetadataPrefix = "tag-"
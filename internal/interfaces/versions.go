@@ -0,0 +1,32 @@
+package interfaces
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// ObjectVersion is one stored version of an object in a versioned bucket.
+type ObjectVersion struct {
+	Name         string
+	VersionID    string // S3/MinIO version ID, GCS generation, Azure version ID or snapshot
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+	ETag         string
+	IsLatest     bool // The version is what ListObjects and GetObject return
+	DeleteMarker bool // The version records that the object was deleted and has no content
+}
+
+// VersionLister is implemented by providers that can list and read every
+// stored version of the objects in a versioned bucket. Providers whose
+// buckets keep no delete markers report a deleted object with a synthetic
+// delete marker after its last version.
+type VersionLister interface {
+	// ListObjectVersions returns the versions of every object whose name
+	// starts with prefix by object name, oldest first.
+	ListObjectVersions(ctx context.Context, bucketName, prefix string) (map[string][]*ObjectVersion, error)
+	// GetObjectVersion reads a version that is not a delete marker, like
+	// GetObject reads the current one.
+	GetObjectVersion(ctx context.Context, bucketName, objectName, versionID string) (*ObjectInfo, io.ReadCloser, error)
+}
@@ -0,0 +1,96 @@
+// Package keymap translates object keys between a mapping's source and target.
+package keymap
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// TemplateData is the data available to a key template.
+type TemplateData struct {
+	Key   string // Source key relative to the source prefix
+	Name  string // Full source key
+	Dir   string // Directory part of Key ("." when there is none)
+	Base  string // Last element of Key
+	Ext   string // File extension of Key, including the dot
+	Year  string // Year of the source object's last modification (UTC)
+	Month string // Two-digit month of the last modification
+	Day   string // Two-digit day of the last modification
+	Hour  string // Two-digit hour of the last modification
+}
+
+// Mapper rewrites source keys into target keys.
+type Mapper struct {
+	sourcePrefix string
+	targetPrefix string
+	tmpl         *template.Template
+}
+
+// New returns a Mapper that replaces sourcePrefix with targetPrefix and, when
+// keyTemplate is set, renders the part after the prefix through it.
+func New(sourcePrefix, targetPrefix, keyTemplate string) (*Mapper, error) {
+	m := &Mapper{sourcePrefix: sourcePrefix, targetPrefix: targetPrefix}
+	if keyTemplate == "" {
+		return m, nil
+	}
+
+	tmpl, err := template.New("key").Option("missingkey=error").Parse(keyTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key template: %w", err)
+	}
+	m.tmpl = tmpl
+
+	// Render a sample key so unknown fields are reported up front.
+	if _, err := m.TargetKey(sourcePrefix+"dir/sample.txt", time.Now()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Templated reports whether target keys are produced by a key template, in
+// which case they cannot be mapped back to source keys.
+func (m *Mapper) Templated() bool {
+	return m.tmpl != nil
+}
+
+// TargetKey returns the target key for a source key.
+func (m *Mapper) TargetKey(sourceKey string, lastModified time.Time) (string, error) {
+	rel := strings.TrimPrefix(sourceKey, m.sourcePrefix)
+	if m.tmpl == nil {
+		return m.targetPrefix + rel, nil
+	}
+
+	modified := lastModified.UTC()
+	data := TemplateData{
+		Key:   rel,
+		Name:  sourceKey,
+		Dir:   path.Dir(rel),
+		Base:  path.Base(rel),
+		Ext:   path.Ext(rel),
+		Year:  modified.Format("2006"),
+		Month: modified.Format("01"),
+		Day:   modified.Format("02"),
+		Hour:  modified.Format("15"),
+	}
+
+	var b strings.Builder
+	if err := m.tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("error rendering key template for %s: %w", sourceKey, err)
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("key template rendered an empty key for %s", sourceKey)
+	}
+	return m.targetPrefix + b.String(), nil
+}
+
+// SourceKey maps a target key back to its source key. It returns false when
+// the key is outside the target prefix or keys are templated.
+func (m *Mapper) SourceKey(targetKey string) (string, bool) {
+	if m.tmpl != nil || !strings.HasPrefix(targetKey, m.targetPrefix) {
+		return "", false
+	}
+	return m.sourcePrefix + strings.TrimPrefix(targetKey, m.targetPrefix), true
+}
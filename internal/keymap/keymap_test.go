@@ -0,0 +1,56 @@
+package keymap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMapper_PrefixRemap(t *testing.T) {
+	m, err := New("events/", "landing/events/", "")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	got, err := m.TargetKey("events/2024/a.json", time.Time{})
+	if err != nil {
+		t.Fatalf("TargetKey returned error: %v", err)
+	}
+	if got != "landing/events/2024/a.json" {
+		t.Errorf("TargetKey = %q, want landing/events/2024/a.json", got)
+	}
+
+	src, ok := m.SourceKey("landing/events/2024/a.json")
+	if !ok || src != "events/2024/a.json" {
+		t.Errorf("SourceKey = %q, %v, want events/2024/a.json, true", src, ok)
+	}
+	if _, ok := m.SourceKey("other/a.json"); ok {
+		t.Error("expected SourceKey to reject keys outside the target prefix")
+	}
+}
+
+func TestMapper_Template(t *testing.T) {
+	m, err := New("events/", "landing/", "{{.Year}}/{{.Month}}/{{.Key}}")
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	modified := time.Date(2024, 3, 9, 15, 0, 0, 0, time.UTC)
+	got, err := m.TargetKey("events/click/a.json", modified)
+	if err != nil {
+		t.Fatalf("TargetKey returned error: %v", err)
+	}
+	if got != "landing/2024/03/click/a.json" {
+		t.Errorf("TargetKey = %q, want landing/2024/03/click/a.json", got)
+	}
+	if _, ok := m.SourceKey(got); ok {
+		t.Error("expected templated keys not to map back")
+	}
+}
+
+func TestNew_InvalidTemplate(t *testing.T) {
+	for _, tmpl := range []string{"{{.Year", "{{.Unknown}}/{{.Key}}"} {
+		if _, err := New("", "", tmpl); err == nil {
+			t.Errorf("expected error for template %q, got nil", tmpl)
+		}
+	}
+}
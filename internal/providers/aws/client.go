@@ -1,13 +1,18 @@
-// Package aws fornece a implementação da interface de armazenamento para Amazon S3
+// Package aws provides the storage interface implementation for Amazon S3
 package aws
 
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -15,71 +20,79 @@ import (
 	"github.com/aws/aws-sdk-go/service/s3"
 )
 
-// Client implementa a interface StorageProvider para AWS S3
+// Client implements the StorageProvider interface for AWS S3
 type Client struct {
-	s3Client *s3.S3
+	s3Client   *s3.S3
+	encryption *interfaces.Encryption
 }
 
-// Config contém a configuração necessária para o cliente AWS S3
+// Config holds the settings required by the AWS S3 client
 type Config struct {
 	Region          string
 	AccessKeyID     string
 	SecretAccessKey string
-	Endpoint        string // Opcional, para uso com serviços S3-compatible
-	DisableSSL      bool   // Opcional, para uso com serviços S3-compatible
+	Endpoint        string // Optional, for S3-compatible services
+	DisableSSL      bool   // Optional, for S3-compatible services
+	// Optional encryption of uploaded objects; a customer key is also used
+	// to read objects
+	Encryption *interfaces.Encryption
 }
 
-// NewClient cria um novo cliente AWS S3
+// NewClient creates a new AWS S3 client
 func NewClient(config Config) (*Client, error) {
-	// Configurações do AWS SDK
+	// AWS SDK settings
 	awsConfig := &aws.Config{
 		Region:      aws.String(config.Region),
 		Credentials: credentials.NewStaticCredentials(config.AccessKeyID, config.SecretAccessKey, ""),
 	}
 
-	// Configurar endpoint personalizado, se fornecido (para S3-compatible services)
+	// Use a custom endpoint when provided (for S3-compatible services)
 	if config.Endpoint != "" {
 		awsConfig.Endpoint = aws.String(config.Endpoint)
 		awsConfig.DisableSSL = aws.Bool(config.DisableSSL)
-		awsConfig.S3ForcePathStyle = aws.Bool(true) // Necessário para serviços compatíveis com S3
+		awsConfig.S3ForcePathStyle = aws.Bool(true) // Required by S3-compatible services
 	}
 
-	// Cria uma nova sessão AWS
+	// Create a new AWS session
 	sess, err := session.NewSession(awsConfig)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao criar sessão AWS: %v", err)
+		return nil, fmt.Errorf("error creating AWS session: %w", err)
 	}
 
-	// Cria cliente S3
+	// Create the S3 client
 	s3Client := s3.New(sess)
 
-	return &Client{s3Client: s3Client}, nil
+	return &Client{s3Client: s3Client, encryption: config.Encryption}, nil
 }
 
-// ListObjects lista todos os objetos em um bucket específico
-func (c *Client) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
-	// Verifica se o bucket existe
+// ListObjects lists every object in a bucket whose name starts with prefix
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
+	// Check that the bucket exists
 	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
+		return nil, err
 	}
 	if !exists {
-		return make(map[string]*interfaces.ObjectInfo), nil
+		return nil, fmt.Errorf("error listing objects in bucket %s: %w", bucketName, interfaces.ErrBucketNotFound)
 	}
 
-	// Lista os objetos no bucket
+	// List the objects in the bucket
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucketName),
 	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
 
 	objects := make(map[string]*interfaces.ObjectInfo)
 
-	err = c.s3Client.ListObjectsV2Pages(input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+	err = c.s3Client.ListObjectsV2PagesWithContext(ctx, input, func(page *s3.ListObjectsV2Output, lastPage bool) bool {
 		for _, obj := range page.Contents {
 			objects[*obj.Key] = &interfaces.ObjectInfo{
 				Name:         *obj.Key,
 				Bucket:       bucketName,
 				Size:         *obj.Size,
+				StorageClass: aws.StringValue(obj.StorageClass),
 				LastModified: *obj.LastModified,
 				ETag:         aws.StringValue(obj.ETag),
 			}
@@ -88,156 +101,211 @@ func (c *Client) ListObjects(ctx context.Context, bucketName string) (map[string
 	})
 
 	if err != nil {
-		return nil, fmt.Errorf("erro ao listar objetos do bucket %s: %v", bucketName, err)
+		return nil, fmt.Errorf("error listing objects in bucket %s: %w", bucketName, classify(err))
 	}
 
-	// Para cada objeto, obtenha os metadados adicionais
+	// Fetch the additional metadata of each object
+	readKey := c.readKey()
 	for key, object := range objects {
 		headInput := &s3.HeadObjectInput{
-			Bucket: aws.String(bucketName),
-			Key:    aws.String(key),
+			Bucket:               aws.String(bucketName),
+			Key:                  aws.String(key),
+			SSECustomerAlgorithm: readKey.customerAlgorithm,
+			SSECustomerKey:       readKey.customerKey,
 		}
 
-		headOutput, err := c.s3Client.HeadObject(headInput)
+		headOutput, err := c.s3Client.HeadObjectWithContext(ctx, headInput)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao obter metadados do objeto %s: %v", key, err)
+			return nil, fmt.Errorf("error getting metadata of object %s: %w", key, classify(err))
 		}
 
 		object.ContentType = aws.StringValue(headOutput.ContentType)
-
-		// Converter os metadados
-		if headOutput.Metadata != nil {
-			metadata := make(map[string]string)
-			for k, v := range headOutput.Metadata {
-				metadata[k] = aws.StringValue(v)
-			}
-			object.Metadata = metadata
-		}
+		object.CacheControl = aws.StringValue(headOutput.CacheControl)
+		object.ContentEncoding = aws.StringValue(headOutput.ContentEncoding)
+		object.ContentDisposition = aws.StringValue(headOutput.ContentDisposition)
+		object.Metadata = interfaces.NormalizeMetadata(aws.StringValueMap(headOutput.Metadata))
 	}
 
 	return objects, nil
 }
 
-// GetObject obtém um objeto armazenado no S3
-func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
-	// Primeiro, obtém os metadados para construir o ObjectInfo
+// StatObject returns the metadata of an object without reading its content
+func (c *Client) StatObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, error) {
+	readKey := c.readKey()
 	headInput := &s3.HeadObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectName),
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String(objectName),
+		SSECustomerAlgorithm: readKey.customerAlgorithm,
+		SSECustomerKey:       readKey.customerKey,
 	}
 
-	headOutput, err := c.s3Client.HeadObject(headInput)
+	headOutput, err := c.s3Client.HeadObjectWithContext(ctx, headInput)
 	if err != nil {
-		return nil, nil, fmt.Errorf("erro ao obter metadados do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error getting metadata of object %s: %w", objectName, classify(err))
 	}
 
-	// Cria o objeto de informações
-	info := &interfaces.ObjectInfo{
-		Name:         objectName,
-		Bucket:       bucketName,
-		Size:         *headOutput.ContentLength,
-		ContentType:  aws.StringValue(headOutput.ContentType),
-		LastModified: *headOutput.LastModified,
-		ETag:         aws.StringValue(headOutput.ETag),
-	}
+	return &interfaces.ObjectInfo{
+		Name:               objectName,
+		Bucket:             bucketName,
+		Size:               *headOutput.ContentLength,
+		ContentType:        aws.StringValue(headOutput.ContentType),
+		CacheControl:       aws.StringValue(headOutput.CacheControl),
+		ContentEncoding:    aws.StringValue(headOutput.ContentEncoding),
+		ContentDisposition: aws.StringValue(headOutput.ContentDisposition),
+		StorageClass:       headStorageClass(headOutput.StorageClass),
+		LastModified:       *headOutput.LastModified,
+		ETag:               aws.StringValue(headOutput.ETag),
+		Metadata:           interfaces.NormalizeMetadata(aws.StringValueMap(headOutput.Metadata)),
+	}, nil
+}
 
-	// Converter os metadados
-	if headOutput.Metadata != nil {
-		metadata := make(map[string]string)
-		for k, v := range headOutput.Metadata {
-			metadata[k] = aws.StringValue(v)
-		}
-		info.Metadata = metadata
+// GetObject retrieves an object stored in S3
+func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	// First fetch the metadata to build the ObjectInfo
+	info, err := c.StatObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Agora, obtém o conteúdo do objeto
+	// Now fetch the object content
+	readKey := c.readKey()
 	input := &s3.GetObjectInput{
-		Bucket: aws.String(bucketName),
-		Key:    aws.String(objectName),
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String(objectName),
+		SSECustomerAlgorithm: readKey.customerAlgorithm,
+		SSECustomerKey:       readKey.customerKey,
 	}
 
-	output, err := c.s3Client.GetObject(input)
+	output, err := c.s3Client.GetObjectWithContext(ctx, input)
 	if err != nil {
-		return info, nil, fmt.Errorf("erro ao obter objeto %s: %v", objectName, err)
+		return info, nil, fmt.Errorf("error getting object %s: %w", objectName, classify(err))
 	}
 
 	return info, output.Body, nil
 }
 
-// UploadObject faz upload de um objeto para o S3
-func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
-	// Garante que o bucket existe
+// UploadObject uploads an object to S3
+func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	// Make sure the bucket exists
 	if err := c.EnsureBucketExists(ctx, bucketName); err != nil {
 		return nil, err
 	}
 
-	// Lê todo o conteúdo para um buffer
-	// Note: isso pode ser melhorado para lidar com arquivos grandes sem carregar tudo na memória
+	// Read the whole content into a buffer
+	// Note: this could be improved to handle large files without loading them into memory
 	content, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao ler conteúdo para upload: %v", err)
+		return nil, fmt.Errorf("error reading content for upload: %w", interfaces.ClassifyStatus(0, err))
 	}
 
-	// Prepara o objeto para upload
+	// Send the content MD5 so S3 rejects corrupted uploads
+	sum := md5.Sum(content)
+	enc := c.uploadEncryption(opts)
+	sse := serverSideEncryption(enc)
+
+	// Prepare the object for upload
 	input := &s3.PutObjectInput{
-		Bucket:      aws.String(bucketName),
-		Key:         aws.String(objectName),
-		Body:        bytes.NewReader(content),
-		ContentType: aws.String(contentType),
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String(objectName),
+		Body:                 bytes.NewReader(content),
+		ContentType:          optionalString(opts.ContentType),
+		CacheControl:         optionalString(opts.CacheControl),
+		ContentEncoding:      optionalString(opts.ContentEncoding),
+		ContentDisposition:   optionalString(opts.ContentDisposition),
+		ContentMD5:           aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		Metadata:             aws.StringMap(opts.Metadata),
+		Tagging:              tagging(opts.Tags),
+		StorageClass:         optionalString(storageclass.Convert(opts.StorageClass, storageclass.S3)),
+		ServerSideEncryption: sse.algorithm,
+		SSEKMSKeyId:          sse.kmsKeyID,
+		SSECustomerAlgorithm: sse.customerAlgorithm,
+		SSECustomerKey:       sse.customerKey,
 	}
 
-	// Faz o upload do objeto
-	result, err := c.s3Client.PutObject(input)
+	// Upload the object
+	result, err := c.s3Client.PutObjectWithContext(ctx, input)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer upload do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error uploading object %s: %w", objectName, classify(err))
 	}
 
+	mode := uploadedEncryption(enc, result.ServerSideEncryption, result.SSECustomerAlgorithm)
 	return &interfaces.UploadInfo{
-		Bucket: bucketName,
-		Key:    objectName,
-		ETag:   aws.StringValue(result.ETag),
-		Size:   size,
+		Bucket:     bucketName,
+		Key:        objectName,
+		ETag:       aws.StringValue(result.ETag),
+		Size:       int64(len(content)),
+		ContentMD5: etagMD5(aws.StringValue(result.ETag), mode),
+		Encryption: mode,
 	}, nil
 }
 
-// DeleteObject remove um objeto do S3
+// headStorageClass returns the storage class reported by a HEAD or GET
+// request, which omits it for STANDARD objects
+func headStorageClass(class *string) string {
+	if class == nil {
+		return s3.StorageClassStandard
+	}
+	return *class
+}
+
+// optionalString returns nil for an empty string, so that S3 does not store
+// an empty header
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+// md5FromETag returns the hex MD5 carried by an ETag, or an empty string when
+// the ETag is not an MD5 of the content (multipart or KMS-encrypted uploads)
+func md5FromETag(etag string) string {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if len(etag) != 2*md5.Size {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// DeleteObject removes an object from S3
 func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
 	input := &s3.DeleteObjectInput{
 		Bucket: aws.String(bucketName),
 		Key:    aws.String(objectName),
 	}
 
-	_, err := c.s3Client.DeleteObject(input)
+	_, err := c.s3Client.DeleteObjectWithContext(ctx, input)
 	if err != nil {
-		return fmt.Errorf("erro ao remover objeto %s: %v", objectName, err)
+		return fmt.Errorf("error deleting object %s: %w", objectName, classify(err))
 	}
 
 	return nil
 }
 
-// BucketExists verifica se um bucket existe no S3
+// BucketExists checks whether a bucket exists in S3
 func (c *Client) BucketExists(ctx context.Context, bucketName string) (bool, error) {
 	input := &s3.HeadBucketInput{
 		Bucket: aws.String(bucketName),
 	}
 
-	_, err := c.s3Client.HeadBucket(input)
+	_, err := c.s3Client.HeadBucketWithContext(ctx, input)
 	if err != nil {
 		if aerr, ok := err.(awserr.Error); ok {
 			switch aerr.Code() {
 			case "NotFound", "NoSuchBucket":
 				return false, nil
-			default:
-				return false, fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
 			}
 		}
-		return false, fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
+		return false, fmt.Errorf("error checking whether bucket %s exists: %w", bucketName, classify(err))
 	}
 
 	return true, nil
 }
 
-// EnsureBucketExists garante que um bucket existe, criando-o se necessário
+// EnsureBucketExists makes sure a bucket exists, creating it if needed
 func (c *Client) EnsureBucketExists(ctx context.Context, bucketName string) error {
 	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
@@ -249,16 +317,16 @@ func (c *Client) EnsureBucketExists(ctx context.Context, bucketName string) erro
 			Bucket: aws.String(bucketName),
 		}
 
-		_, err := c.s3Client.CreateBucket(createInput)
+		_, err := c.s3Client.CreateBucketWithContext(ctx, createInput)
 		if err != nil {
-			return fmt.Errorf("erro ao criar bucket %s: %v", bucketName, err)
+			return fmt.Errorf("error creating bucket %s: %w", bucketName, classify(err))
 		}
 	}
 
 	return nil
 }
 
-// Close fecha o cliente (no caso do S3, não há nada a fechar)
+// Close closes the client (there is nothing to close for S3)
 func (c *Client) Close() error {
 	return nil
 }
@@ -1,11 +1,83 @@
 package aws
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 )
 
 func TestClient_ImplementsStorageProvider(t *testing.T) {
 	var _ interfaces.StorageProvider = (*Client)(nil)
+	var _ interfaces.RangeReader = (*Client)(nil)
+	var _ interfaces.MultipartUploader = (*Client)(nil)
+	var _ interfaces.VersionLister = (*Client)(nil)
+	var _ interfaces.TagReader = (*Client)(nil)
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{awserr.NewRequestFailure(awserr.New("NoSuchKey", "missing", nil), 404, "r1"), interfaces.ErrNotFound},
+		{awserr.NewRequestFailure(awserr.New("NoSuchBucket", "missing", nil), 404, "r2"), interfaces.ErrBucketNotFound},
+		{awserr.NewRequestFailure(awserr.New("AccessDenied", "denied", nil), 403, "r3"), interfaces.ErrPermissionDenied},
+		{awserr.NewRequestFailure(awserr.New("SlowDown", "slow down", nil), 503, "r4"), interfaces.ErrThrottled},
+		{awserr.NewRequestFailure(awserr.New("SomethingNew", "boom", nil), 502, "r5"), interfaces.ErrTransient},
+		{awserr.NewRequestFailure(awserr.New("PreconditionFailed", "etag", nil), 412, "r6"), interfaces.ErrPreconditionFailed},
+	}
+	for _, tt := range tests {
+		err := fmt.Errorf("error getting object k: %w", classify(tt.err))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("classify(%v) is not %v", tt.err, tt.want)
+		}
+		var aerr awserr.Error
+		if !errors.As(err, &aerr) {
+			t.Errorf("classify(%v) hides the SDK error", tt.err)
+		}
+	}
+}
+
+func TestTagging(t *testing.T) {
+	if got := tagging(nil); got != nil {
+		t.Errorf("tagging(nil) = %q, want nil", *got)
+	}
+	got := tagging(map[string]string{"tier": "cold", "owner": "data team"})
+	if got == nil || *got != "owner=data+team&tier=cold" {
+		t.Errorf("tagging() = %v, want owner=data+team&tier=cold", got)
+	}
+}
+
+func TestUploadedEncryption(t *testing.T) {
+	const etag = `"9e107d9d372bb6826bd81d3542a419d6"`
+	kms := &interfaces.Encryption{Mode: interfaces.EncryptionKMS}
+	tests := []struct {
+		name                         string
+		requested                    *interfaces.Encryption
+		algorithm, customerAlgorithm *string
+		want                         interfaces.EncryptionMode
+		wantMD5                      bool
+	}{
+		{"bucket default", nil, nil, nil, interfaces.EncryptionDefault, true},
+		{"sse-s3", nil, aws.String("AES256"), nil, interfaces.EncryptionManaged, true},
+		{"sse-kms", kms, aws.String("aws:kms"), nil, interfaces.EncryptionKMS, false},
+		{"dsse-kms", nil, aws.String("aws:kms:dsse"), nil, interfaces.EncryptionKMS, false},
+		{"sse-c", nil, nil, aws.String("AES256"), interfaces.EncryptionCustomerKey, false},
+		{"not reported", kms, nil, nil, interfaces.EncryptionKMS, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode := uploadedEncryption(tt.requested, tt.algorithm, tt.customerAlgorithm)
+			if mode != tt.want {
+				t.Errorf("uploadedEncryption() = %q, want %q", mode, tt.want)
+			}
+			if got := etagMD5(etag, mode) != ""; got != tt.wantMD5 {
+				t.Errorf("etagMD5() reported an MD5 = %v, want %v", got, tt.wantMD5)
+			}
+		})
+	}
 }
@@ -0,0 +1,84 @@
+package aws
+
+import (
+	"strings"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// sse holds the request headers selecting server-side encryption
+type sse struct {
+	algorithm         *string // SSE-S3 or SSE-KMS
+	kmsKeyID          *string
+	customerAlgorithm *string // SSE-C
+	customerKey       *string
+}
+
+// uploadEncryption returns the encryption to write an object with: the one in
+// the upload options, or else the client's
+func (c *Client) uploadEncryption(opts interfaces.UploadOptions) *interfaces.Encryption {
+	if opts.Encryption != nil {
+		return opts.Encryption
+	}
+	return c.encryption
+}
+
+// serverSideEncryption returns the headers that write an object with enc
+func serverSideEncryption(enc *interfaces.Encryption) sse {
+	if enc == nil {
+		return sse{}
+	}
+	switch enc.Mode {
+	case interfaces.EncryptionManaged:
+		return sse{algorithm: aws.String(s3.ServerSideEncryptionAes256)}
+	case interfaces.EncryptionKMS:
+		return sse{algorithm: aws.String(s3.ServerSideEncryptionAwsKms), kmsKeyID: optionalString(enc.KeyID)}
+	case interfaces.EncryptionCustomerKey:
+		return customerKey(enc)
+	}
+	return sse{}
+}
+
+// customerKey returns the SSE-C headers of enc, which every request reading
+// or writing an object encrypted with a customer key must carry. The SDK
+// encodes the key and adds its MD5.
+func customerKey(enc *interfaces.Encryption) sse {
+	if enc == nil || enc.Mode != interfaces.EncryptionCustomerKey {
+		return sse{}
+	}
+	return sse{customerAlgorithm: aws.String(s3.ServerSideEncryptionAes256), customerKey: aws.String(string(enc.Key))}
+}
+
+// readKey returns the SSE-C headers for reading objects, set when the client
+// is configured with a customer key
+func (c *Client) readKey() sse {
+	return customerKey(c.encryption)
+}
+
+// uploadedEncryption returns the encryption mode of an upload as S3 reports
+// it, or the requested one when the response does not say
+func uploadedEncryption(requested *interfaces.Encryption, algorithm, customerAlgorithm *string) interfaces.EncryptionMode {
+	switch {
+	case aws.StringValue(customerAlgorithm) != "":
+		return interfaces.EncryptionCustomerKey
+	case strings.HasPrefix(aws.StringValue(algorithm), s3.ServerSideEncryptionAwsKms): // Also aws:kms:dsse
+		return interfaces.EncryptionKMS
+	case aws.StringValue(algorithm) != "":
+		return interfaces.EncryptionManaged
+	case requested != nil:
+		return requested.Mode
+	}
+	return interfaces.EncryptionDefault
+}
+
+// etagMD5 returns the hex MD5 carried by the ETag of an upload encrypted with
+// mode. Objects encrypted with a KMS or customer key have ETags that are not
+// the MD5 of their content.
+func etagMD5(etag string, mode interfaces.EncryptionMode) string {
+	if mode == interfaces.EncryptionKMS || mode == interfaces.EncryptionCustomerKey {
+		return ""
+	}
+	return md5FromETag(etag)
+}
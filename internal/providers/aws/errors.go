@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// errorKinds maps S3 error codes to the provider-independent sentinels.
+var errorKinds = map[string]error{
+	s3.ErrCodeNoSuchKey:            interfaces.ErrNotFound,
+	s3.ErrCodeNoSuchUpload:         interfaces.ErrNotFound,
+	"NotFound":                     interfaces.ErrNotFound,
+	s3.ErrCodeNoSuchBucket:         interfaces.ErrBucketNotFound,
+	"AccessDenied":                 interfaces.ErrPermissionDenied,
+	"Forbidden":                    interfaces.ErrPermissionDenied,
+	"InvalidAccessKeyId":           interfaces.ErrPermissionDenied,
+	"SignatureDoesNotMatch":        interfaces.ErrPermissionDenied,
+	"SlowDown":                     interfaces.ErrThrottled,
+	"Throttling":                   interfaces.ErrThrottled,
+	"ThrottlingException":          interfaces.ErrThrottled,
+	"RequestLimitExceeded":         interfaces.ErrThrottled,
+	"TooManyRequests":              interfaces.ErrThrottled,
+	"InternalError":                interfaces.ErrTransient,
+	"ServiceUnavailable":           interfaces.ErrTransient,
+	"RequestTimeout":               interfaces.ErrTransient,
+	request.ErrCodeRequestError:    interfaces.ErrTransient,
+	request.ErrCodeResponseTimeout: interfaces.ErrTransient,
+	"PreconditionFailed":           interfaces.ErrPreconditionFailed,
+}
+
+// classify marks an SDK error with the matching sentinel from the interfaces package.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if aerr, ok := err.(awserr.Error); ok {
+		if kind, ok := errorKinds[aerr.Code()]; ok {
+			return interfaces.Classify(kind, err)
+		}
+	}
+
+	status := 0
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		status = reqErr.StatusCode()
+	}
+	return interfaces.ClassifyStatus(status, err)
+}
@@ -0,0 +1,141 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"fmt"
+	"io"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// GetObjectRange reads length bytes of an object starting at offset
+func (c *Client) GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	readKey := c.readKey()
+	input := &s3.GetObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String(objectName),
+		Range:                aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+		SSECustomerAlgorithm: readKey.customerAlgorithm,
+		SSECustomerKey:       readKey.customerKey,
+	}
+
+	output, err := c.s3Client.GetObjectWithContext(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("error getting range of object %s: %w", objectName, classify(err))
+	}
+	return output.Body, nil
+}
+
+// CreateMultipartUpload starts an S3 multipart upload
+func (c *Client) CreateMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	if err := c.EnsureBucketExists(ctx, upload.Bucket); err != nil {
+		return err
+	}
+
+	sse := serverSideEncryption(c.uploadEncryption(upload.Options))
+	output, err := c.s3Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:               aws.String(upload.Bucket),
+		Key:                  aws.String(upload.Key),
+		ContentType:          optionalString(upload.Options.ContentType),
+		CacheControl:         optionalString(upload.Options.CacheControl),
+		ContentEncoding:      optionalString(upload.Options.ContentEncoding),
+		ContentDisposition:   optionalString(upload.Options.ContentDisposition),
+		Metadata:             aws.StringMap(upload.Options.Metadata),
+		Tagging:              tagging(upload.Options.Tags),
+		StorageClass:         optionalString(storageclass.Convert(upload.Options.StorageClass, storageclass.S3)),
+		ServerSideEncryption: sse.algorithm,
+		SSEKMSKeyId:          sse.kmsKeyID,
+		SSECustomerAlgorithm: sse.customerAlgorithm,
+		SSECustomerKey:       sse.customerKey,
+	})
+	if err != nil {
+		return fmt.Errorf("error starting multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+
+	upload.UploadID = aws.StringValue(output.UploadId)
+	return nil
+}
+
+// UploadPart uploads one part of a multipart upload
+func (c *Client) UploadPart(ctx context.Context, upload *interfaces.MultipartUpload, number int, reader io.Reader, size int64) (*interfaces.CompletedPart, error) {
+	// The SDK needs a seekable body, so the part is buffered in memory
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading content of part %d: %w", number, interfaces.ClassifyStatus(0, err))
+	}
+	sum := md5.Sum(content)
+	enc := c.uploadEncryption(upload.Options)
+	key := customerKey(enc)
+
+	output, err := c.s3Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:               aws.String(upload.Bucket),
+		Key:                  aws.String(upload.Key),
+		UploadId:             aws.String(upload.UploadID),
+		PartNumber:           aws.Int64(int64(number)),
+		Body:                 bytes.NewReader(content),
+		ContentLength:        aws.Int64(int64(len(content))),
+		ContentMD5:           aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+		SSECustomerAlgorithm: key.customerAlgorithm,
+		SSECustomerKey:       key.customerKey,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading part %d of object %s: %w", number, upload.Key, classify(err))
+	}
+
+	etag := aws.StringValue(output.ETag)
+	return &interfaces.CompletedPart{
+		Number:     number,
+		ETag:       etag,
+		Size:       int64(len(content)),
+		ContentMD5: etagMD5(etag, uploadedEncryption(enc, output.ServerSideEncryption, output.SSECustomerAlgorithm)),
+	}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the object
+func (c *Client) CompleteMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload, parts []interfaces.CompletedPart) (*interfaces.UploadInfo, error) {
+	completed := make([]*s3.CompletedPart, len(parts))
+	for i, part := range parts {
+		completed[i] = &s3.CompletedPart{
+			ETag:       aws.String(part.ETag),
+			PartNumber: aws.Int64(int64(part.Number)),
+		}
+	}
+
+	output, err := c.s3Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(upload.Bucket),
+		Key:             aws.String(upload.Key),
+		UploadId:        aws.String(upload.UploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error completing multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+
+	// The ETag of a multipart object is not the MD5 of its content
+	return &interfaces.UploadInfo{
+		Bucket:     upload.Bucket,
+		Key:        upload.Key,
+		ETag:       aws.StringValue(output.ETag),
+		Size:       upload.Size,
+		Encryption: uploadedEncryption(c.uploadEncryption(upload.Options), output.ServerSideEncryption, nil),
+	}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (c *Client) AbortMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	_, err := c.s3Client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(upload.Bucket),
+		Key:      aws.String(upload.Key),
+		UploadId: aws.String(upload.UploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("error aborting multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+	return nil
+}
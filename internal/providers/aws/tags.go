@@ -0,0 +1,43 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// GetObjectTags returns the tags of an object stored in S3
+func (c *Client) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	output, err := c.s3Client.GetObjectTaggingWithContext(ctx, &s3.GetObjectTaggingInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(objectName),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting tags of object %s: %w", objectName, classify(err))
+	}
+
+	if len(output.TagSet) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(output.TagSet))
+	for _, tag := range output.TagSet {
+		tags[aws.StringValue(tag.Key)] = aws.StringValue(tag.Value)
+	}
+	return tags, nil
+}
+
+// tagging encodes tags as the query string S3 expects in the x-amz-tagging
+// header, or returns nil when there are none
+func tagging(tags map[string]string) *string {
+	if len(tags) == 0 {
+		return nil
+	}
+	values := url.Values{}
+	for k, v := range tags {
+		values.Set(k, v)
+	}
+	return aws.String(values.Encode())
+}
@@ -0,0 +1,97 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// ListObjectVersions lists every version and delete marker of the objects in
+// a bucket whose name starts with prefix, oldest first
+func (c *Client) ListObjectVersions(ctx context.Context, bucketName, prefix string) (map[string][]*interfaces.ObjectVersion, error) {
+	input := &s3.ListObjectVersionsInput{
+		Bucket: aws.String(bucketName),
+	}
+	if prefix != "" {
+		input.Prefix = aws.String(prefix)
+	}
+
+	// S3 lists the versions and the delete markers of a key separately, each
+	// newest first
+	versions := make(map[string][]*interfaces.ObjectVersion)
+	err := c.s3Client.ListObjectVersionsPagesWithContext(ctx, input, func(page *s3.ListObjectVersionsOutput, lastPage bool) bool {
+		for _, v := range page.Versions {
+			key := aws.StringValue(v.Key)
+			versions[key] = append(versions[key], &interfaces.ObjectVersion{
+				Name:         key,
+				VersionID:    aws.StringValue(v.VersionId),
+				Size:         aws.Int64Value(v.Size),
+				LastModified: aws.TimeValue(v.LastModified),
+				ETag:         aws.StringValue(v.ETag),
+				IsLatest:     aws.BoolValue(v.IsLatest),
+			})
+		}
+		for _, m := range page.DeleteMarkers {
+			key := aws.StringValue(m.Key)
+			versions[key] = append(versions[key], &interfaces.ObjectVersion{
+				Name:         key,
+				VersionID:    aws.StringValue(m.VersionId),
+				LastModified: aws.TimeValue(m.LastModified),
+				IsLatest:     aws.BoolValue(m.IsLatest),
+				DeleteMarker: true,
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing object versions in bucket %s: %w", bucketName, classify(err))
+	}
+
+	for _, list := range versions {
+		slices.Reverse(list)
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].IsLatest != list[j].IsLatest {
+				return list[j].IsLatest
+			}
+			return list[i].LastModified.Before(list[j].LastModified)
+		})
+	}
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a specific version of an object stored in S3
+func (c *Client) GetObjectVersion(ctx context.Context, bucketName, objectName, versionID string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	readKey := c.readKey()
+	output, err := c.s3Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket:               aws.String(bucketName),
+		Key:                  aws.String(objectName),
+		VersionId:            aws.String(versionID),
+		SSECustomerAlgorithm: readKey.customerAlgorithm,
+		SSECustomerKey:       readKey.customerKey,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting version %s of object %s: %w", versionID, objectName, classify(err))
+	}
+
+	info := &interfaces.ObjectInfo{
+		Name:               objectName,
+		Bucket:             bucketName,
+		Size:               aws.Int64Value(output.ContentLength),
+		ContentType:        aws.StringValue(output.ContentType),
+		CacheControl:       aws.StringValue(output.CacheControl),
+		ContentEncoding:    aws.StringValue(output.ContentEncoding),
+		ContentDisposition: aws.StringValue(output.ContentDisposition),
+		StorageClass:       headStorageClass(output.StorageClass),
+		LastModified:       aws.TimeValue(output.LastModified),
+		ETag:               aws.StringValue(output.ETag),
+		Metadata:           interfaces.NormalizeMetadata(aws.StringValueMap(output.Metadata)),
+		VersionID:          versionID,
+	}
+	return info, output.Body, nil
+}
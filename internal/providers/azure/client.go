@@ -2,30 +2,36 @@ package azure
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/url"
 
 	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
 )
 
 type Client struct {
 	containerURL azblob.ContainerURL
 	serviceURL   azblob.ServiceURL
 	credential   azblob.SharedKeyCredential
+	encryption   *interfaces.Encryption
 }
 
 type Config struct {
 	AccountName string
 	AccountKey  string
 	EndpointURL string
+	// Optional encryption of uploaded blobs; a customer-provided key is also
+	// used to read blobs
+	Encryption *interfaces.Encryption
 }
 
 func NewClient(config Config) (*Client, error) {
 	credential, err := azblob.NewSharedKeyCredential(config.AccountName, config.AccountKey)
 	if err != nil {
-		return nil, fmt.Errorf("error creating Azure credentials: %v", err)
+		return nil, fmt.Errorf("error creating Azure credentials: %w", err)
 	}
 
 	endpointURL := config.EndpointURL
@@ -37,7 +43,7 @@ func NewClient(config Config) (*Client, error) {
 
 	serviceURL, err := url.Parse(endpointURL)
 	if err != nil {
-		return nil, fmt.Errorf("error parsing endpoint URL: %v", err)
+		return nil, fmt.Errorf("error parsing endpoint URL: %w", err)
 	}
 
 	azServiceURL := azblob.NewServiceURL(*serviceURL, pipeline)
@@ -45,6 +51,7 @@ func NewClient(config Config) (*Client, error) {
 	return &Client{
 		serviceURL: azServiceURL,
 		credential: *credential,
+		encryption: config.Encryption,
 	}, nil
 }
 
@@ -57,13 +64,13 @@ func (c *Client) getBlobURL(containerName, blobName string) azblob.BlockBlobURL
 	return containerURL.NewBlockBlobURL(blobName)
 }
 
-func (c *Client) ListObjects(ctx context.Context, containerName string) (map[string]*interfaces.ObjectInfo, error) {
+func (c *Client) ListObjects(ctx context.Context, containerName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
 	exists, err := c.BucketExists(ctx, containerName)
 	if err != nil {
-		return nil, fmt.Errorf("error checking if container %s exists: %v", containerName, err)
+		return nil, err
 	}
 	if !exists {
-		return make(map[string]*interfaces.ObjectInfo), nil
+		return nil, fmt.Errorf("error listing blobs from container %s: %w", containerName, interfaces.ErrBucketNotFound)
 	}
 
 	containerURL := c.getContainerURL(containerName)
@@ -73,29 +80,30 @@ func (c *Client) ListObjects(ctx context.Context, containerName string) (map[str
 		Details: azblob.BlobListingDetails{
 			Metadata: true,
 		},
+		Prefix: prefix,
 	}
 
 	for marker := (azblob.Marker{}); marker.NotDone(); {
 		response, err := containerURL.ListBlobsFlatSegment(ctx, marker, options)
 		if err != nil {
-			return nil, fmt.Errorf("error listing blobs from container %s: %v", containerName, err)
+			return nil, fmt.Errorf("error listing blobs from container %s: %w", containerName, classify(err))
 		}
 
 		marker = response.NextMarker
 
 		for _, blob := range response.Segment.BlobItems {
-			contentType := ""
-			if blob.Properties.ContentType != nil {
-				contentType = *blob.Properties.ContentType
-			}
 			objects[blob.Name] = &interfaces.ObjectInfo{
-				Name:         blob.Name,
-				Bucket:       containerName,
-				Size:         *blob.Properties.ContentLength,
-				ContentType:  contentType,
-				LastModified: blob.Properties.LastModified,
-				ETag:         string(blob.Properties.Etag),
-				Metadata:     blob.Metadata,
+				Name:               blob.Name,
+				Bucket:             containerName,
+				Size:               *blob.Properties.ContentLength,
+				ContentType:        stringValue(blob.Properties.ContentType),
+				CacheControl:       stringValue(blob.Properties.CacheControl),
+				ContentEncoding:    stringValue(blob.Properties.ContentEncoding),
+				ContentDisposition: stringValue(blob.Properties.ContentDisposition),
+				StorageClass:       string(blob.Properties.AccessTier),
+				LastModified:       blob.Properties.LastModified,
+				ETag:               string(blob.Properties.Etag),
+				Metadata:           interfaces.NormalizeMetadata(blob.Metadata),
 			}
 		}
 	}
@@ -103,61 +111,132 @@ func (c *Client) ListObjects(ctx context.Context, containerName string) (map[str
 	return objects, nil
 }
 
+// StatObject returns the properties of a blob without reading its content
+func (c *Client) StatObject(ctx context.Context, containerName, blobName string) (*interfaces.ObjectInfo, error) {
+	props, err := c.getBlobURL(containerName, blobName).GetProperties(ctx, azblob.BlobAccessConditions{}, c.readKey())
+	if err != nil {
+		return nil, fmt.Errorf("error getting blob %s properties: %w", blobName, classify(err))
+	}
+	return propertiesInfo(containerName, blobName, props), nil
+}
+
 func (c *Client) GetObject(ctx context.Context, containerName, blobName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
 	blobURL := c.getBlobURL(containerName, blobName)
 
-	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, azblob.ClientProvidedKeyOptions{})
+	info, err := c.StatObject(ctx, containerName, blobName)
 	if err != nil {
-		return nil, nil, fmt.Errorf("error getting blob %s properties: %v", blobName, err)
-	}
-
-	info := &interfaces.ObjectInfo{
-		Name:         blobName,
-		Bucket:       containerName,
-		Size:         props.ContentLength(),
-		ContentType:  props.ContentType(),
-		LastModified: props.LastModified(),
-		ETag:         string(props.ETag()),
-		Metadata:     props.NewMetadata(),
+		return nil, nil, err
 	}
 
-	response, err := blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, azblob.ClientProvidedKeyOptions{})
+	response, err := blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, c.readKey())
 	if err != nil {
-		return info, nil, fmt.Errorf("error downloading blob %s: %v", blobName, err)
+		return info, nil, fmt.Errorf("error downloading blob %s: %w", blobName, classify(err))
 	}
 
 	return info, response.Body(azblob.RetryReaderOptions{}), nil
 }
 
-func (c *Client) UploadObject(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+func (c *Client) UploadObject(ctx context.Context, containerName, blobName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	if err := c.EnsureBucketExists(ctx, containerName); err != nil {
 		return nil, err
 	}
 
 	content, err := io.ReadAll(reader)
 	if err != nil {
-		return nil, fmt.Errorf("error reading content for upload: %v", err)
+		return nil, fmt.Errorf("error reading content for upload: %w", interfaces.ClassifyStatus(0, err))
 	}
 
 	blobURL := c.getBlobURL(containerName, blobName)
 
+	enc := c.uploadEncryption(opts)
 	options := azblob.UploadToBlockBlobOptions{
-		BlobHTTPHeaders: azblob.BlobHTTPHeaders{
-			ContentType: contentType,
-		},
+		BlobHTTPHeaders:          httpHeaders(opts),
+		Metadata:                 blobMetadata(opts.Metadata),
+		BlobTagsMap:              blobTags(opts.Tags),
+		BlobAccessTier:           accessTier(opts.StorageClass),
+		ClientProvidedKeyOptions: keyOptions(enc),
 	}
 
 	response, err := azblob.UploadBufferToBlockBlob(ctx, content, blobURL, options)
 	if err != nil {
-		return nil, fmt.Errorf("error uploading blob %s: %v", blobName, err)
+		return nil, fmt.Errorf("error uploading blob %s: %w", blobName, classify(err))
 	}
 
-	return &interfaces.UploadInfo{
-		Bucket: containerName,
-		Key:    blobName,
-		ETag:   string(response.ETag()),
-		Size:   int64(len(content)),
-	}, nil
+	info := &interfaces.UploadInfo{
+		Bucket:     containerName,
+		Key:        blobName,
+		ETag:       string(response.ETag()),
+		Size:       int64(len(content)),
+		Encryption: uploadedEncryption(response, enc),
+	}
+	// Only single-shot uploads return the MD5 computed by the service; block
+	// list commits return the MD5 of the block list instead.
+	if upload, ok := response.(*azblob.BlockBlobUploadResponse); ok {
+		info.ContentMD5 = hex.EncodeToString(upload.ContentMD5())
+	}
+	return info, nil
+}
+
+// propertiesInfo converts the properties of a blob to an ObjectInfo
+func propertiesInfo(containerName, blobName string, props *azblob.BlobGetPropertiesResponse) *interfaces.ObjectInfo {
+	return &interfaces.ObjectInfo{
+		Name:               blobName,
+		Bucket:             containerName,
+		Size:               props.ContentLength(),
+		ContentType:        props.ContentType(),
+		CacheControl:       props.CacheControl(),
+		ContentEncoding:    props.ContentEncoding(),
+		ContentDisposition: props.ContentDisposition(),
+		StorageClass:       props.AccessTier(),
+		LastModified:       props.LastModified(),
+		ETag:               string(props.ETag()),
+		Metadata:           interfaces.NormalizeMetadata(props.NewMetadata()),
+	}
+}
+
+func httpHeaders(opts interfaces.UploadOptions) azblob.BlobHTTPHeaders {
+	return azblob.BlobHTTPHeaders{
+		ContentType:        opts.ContentType,
+		CacheControl:       opts.CacheControl,
+		ContentEncoding:    opts.ContentEncoding,
+		ContentDisposition: opts.ContentDisposition,
+	}
+}
+
+// blobMetadata converts user metadata to Azure metadata, whose names must be
+// valid C# identifiers: other characters, such as the dashes common in S3
+// metadata, become underscores, and a leading digit is prefixed with one.
+func blobMetadata(metadata map[string]string) azblob.Metadata {
+	converted := azblob.Metadata{}
+	for k, v := range metadata {
+		name := []byte(k)
+		for i, c := range name {
+			if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') {
+				name[i] = '_'
+			}
+		}
+		if len(name) == 0 || name[0] >= '0' && name[0] <= '9' {
+			name = append([]byte{'_'}, name...)
+		}
+		converted[string(name)] = v
+	}
+	return converted
+}
+
+// accessTier returns the access tier equivalent to a storage class, or
+// AccessTierNone for the account's default tier.
+func accessTier(class string) azblob.AccessTierType {
+	if tier := storageclass.Convert(class, storageclass.Azure); tier != "" {
+		return azblob.AccessTierType(tier)
+	}
+	return azblob.AccessTierNone
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
 }
 
 func (c *Client) DeleteObject(ctx context.Context, containerName, blobName string) error {
@@ -168,7 +247,7 @@ func (c *Client) DeleteObject(ctx context.Context, containerName, blobName strin
 		if stgErr, ok := err.(azblob.StorageError); ok && stgErr.Response().StatusCode == 404 {
 			return nil
 		}
-		return fmt.Errorf("error deleting blob %s: %v", blobName, err)
+		return fmt.Errorf("error deleting blob %s: %w", blobName, classify(err))
 	}
 
 	return nil
@@ -182,7 +261,7 @@ func (c *Client) BucketExists(ctx context.Context, containerName string) (bool,
 		if stgErr, ok := err.(azblob.StorageError); ok && stgErr.Response().StatusCode == 404 {
 			return false, nil
 		}
-		return false, fmt.Errorf("error checking if container %s exists: %v", containerName, err)
+		return false, fmt.Errorf("error checking if container %s exists: %w", containerName, classify(err))
 	}
 
 	return true, nil
@@ -202,7 +281,7 @@ func (c *Client) EnsureBucketExists(ctx context.Context, containerName string) e
 			if stgErr, ok := err.(azblob.StorageError); ok && stgErr.ServiceCode() == azblob.ServiceCodeContainerAlreadyExists {
 				return nil
 			}
-			return fmt.Errorf("error creating container %s: %v", containerName, err)
+			return fmt.Errorf("error creating container %s: %w", containerName, classify(err))
 		}
 	}
 
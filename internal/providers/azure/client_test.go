@@ -3,9 +3,54 @@ package azure
 import (
 	"testing"
 
+	"github.com/Azure/azure-storage-blob-go/azblob"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
 )
 
 func TestClient_ImplementsStorageProvider(t *testing.T) {
 	var _ interfaces.StorageProvider = (*Client)(nil)
+	var _ interfaces.RangeReader = (*Client)(nil)
+	var _ interfaces.MultipartUploader = (*Client)(nil)
+	var _ interfaces.VersionLister = (*Client)(nil)
+	var _ interfaces.TagReader = (*Client)(nil)
+}
+
+func TestBlobMetadata(t *testing.T) {
+	got := blobMetadata(map[string]string{"owner": "data", "original-name": "a.txt", "2fa": "on", "x.y": "z"})
+	want := map[string]string{"owner": "data", "original_name": "a.txt", "_2fa": "on", "x_y": "z"}
+	if len(got) != len(want) {
+		t.Fatalf("blobMetadata() = %v, want %v", got, want)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("blobMetadata()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestBlobTags(t *testing.T) {
+	if got := blobTags(nil); got != nil {
+		t.Errorf("blobTags(nil) = %v, want nil", got)
+	}
+	got := blobTags(map[string]string{"tier": "cold", "owner": "ops@example.com", "path": "a/b c"})
+	want := map[string]string{"tier": "cold", "owner": "ops_example.com", "path": "a/b c"}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("blobTags()[%q] = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestKeyOptions(t *testing.T) {
+	if cpk := keyOptions(nil); cpk.EncryptionKey != nil || cpk.EncryptionScope != nil {
+		t.Errorf("keyOptions(nil) = %+v, want none", cpk)
+	}
+	if cpk := keyOptions(&interfaces.Encryption{Mode: interfaces.EncryptionKMS, KeyID: "replicas"}); cpk.EncryptionScope == nil || *cpk.EncryptionScope != "replicas" || cpk.EncryptionKey != nil {
+		t.Errorf("keyOptions(kms) = %+v, want scope replicas", cpk)
+	}
+	cpk := keyOptions(&interfaces.Encryption{Mode: interfaces.EncryptionCustomerKey, Key: make([]byte, interfaces.CustomerKeySize)})
+	if cpk.EncryptionKey == nil || *cpk.EncryptionKey != "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=" || cpk.EncryptionKeySha256 == nil ||
+		cpk.EncryptionAlgorithm != azblob.EncryptionAlgorithmAES256 {
+		t.Errorf("keyOptions(customer-key) = %+v, want the encoded key", cpk)
+	}
 }
@@ -0,0 +1,80 @@
+package azure
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// accountEncryptionScope is the scope the service reports for blobs encrypted
+// with the storage account's own key
+const accountEncryptionScope = "$account-encryption-key"
+
+// uploadEncryption returns the encryption to write a blob with: the one in the
+// upload options, or else the client's
+func (c *Client) uploadEncryption(opts interfaces.UploadOptions) *interfaces.Encryption {
+	if opts.Encryption != nil {
+		return opts.Encryption
+	}
+	return c.encryption
+}
+
+// keyOptions converts an encryption to the options of a blob request: an
+// encryption scope for EncryptionKMS, or a customer-provided key. Blobs are
+// always encrypted with Microsoft-managed keys otherwise.
+func keyOptions(enc *interfaces.Encryption) azblob.ClientProvidedKeyOptions {
+	if enc == nil {
+		return azblob.ClientProvidedKeyOptions{}
+	}
+	switch enc.Mode {
+	case interfaces.EncryptionKMS:
+		return azblob.ClientProvidedKeyOptions{EncryptionScope: &enc.KeyID}
+	case interfaces.EncryptionCustomerKey:
+		key := base64.StdEncoding.EncodeToString(enc.Key)
+		sum := sha256.Sum256(enc.Key)
+		keySHA256 := base64.StdEncoding.EncodeToString(sum[:])
+		return azblob.ClientProvidedKeyOptions{
+			EncryptionKey:       &key,
+			EncryptionKeySha256: &keySHA256,
+			EncryptionAlgorithm: azblob.EncryptionAlgorithmAES256,
+		}
+	}
+	return azblob.ClientProvidedKeyOptions{}
+}
+
+// readKey returns the options to read blobs with, carrying the client's
+// customer-provided key if it has one. Encryption scopes are not needed to
+// read.
+func (c *Client) readKey() azblob.ClientProvidedKeyOptions {
+	if c.encryption == nil || c.encryption.Mode != interfaces.EncryptionCustomerKey {
+		return azblob.ClientProvidedKeyOptions{}
+	}
+	return keyOptions(c.encryption)
+}
+
+// encryptedResponse is implemented by the responses of blob uploads and block
+// list commits
+type encryptedResponse interface {
+	EncryptionKeySha256() string
+	EncryptionScope() string
+}
+
+// uploadedEncryption returns the encryption mode the service reports for an
+// upload, or the requested one when the response does not say
+func uploadedEncryption(response any, requested *interfaces.Encryption) interfaces.EncryptionMode {
+	if r, ok := response.(encryptedResponse); ok {
+		switch {
+		case r.EncryptionKeySha256() != "":
+			return interfaces.EncryptionCustomerKey
+		case r.EncryptionScope() != "" && r.EncryptionScope() != accountEncryptionScope:
+			return interfaces.EncryptionKMS
+		}
+		return interfaces.EncryptionManaged
+	}
+	if requested != nil {
+		return requested.Mode
+	}
+	return interfaces.EncryptionDefault
+}
@@ -0,0 +1,46 @@
+package azure
+
+import (
+	"errors"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// errorKinds maps Azure service codes to the provider-independent sentinels.
+var errorKinds = map[azblob.ServiceCodeType]error{
+	azblob.ServiceCodeBlobNotFound:                   interfaces.ErrNotFound,
+	azblob.ServiceCodeInvalidBlockList:               interfaces.ErrNotFound, // Staged blocks expired or were never uploaded
+	azblob.ServiceCodeContainerNotFound:              interfaces.ErrBucketNotFound,
+	azblob.ServiceCodeContainerBeingDeleted:          interfaces.ErrBucketNotFound,
+	azblob.ServiceCodeAuthenticationFailed:           interfaces.ErrPermissionDenied,
+	"AuthorizationFailure":                           interfaces.ErrPermissionDenied,
+	azblob.ServiceCodeInsufficientAccountPermissions: interfaces.ErrPermissionDenied,
+	azblob.ServiceCodeServerBusy:                     interfaces.ErrThrottled,
+	azblob.ServiceCodeInternalError:                  interfaces.ErrTransient,
+	azblob.ServiceCodeOperationTimedOut:              interfaces.ErrTransient,
+	azblob.ServiceCodeConditionNotMet:                interfaces.ErrPreconditionFailed,
+	azblob.ServiceCodeTargetConditionNotMet:          interfaces.ErrPreconditionFailed,
+	azblob.ServiceCodeSourceConditionNotMet:          interfaces.ErrPreconditionFailed,
+}
+
+// classify marks an SDK error with the matching sentinel from the interfaces package.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var stgErr azblob.StorageError
+	if !errors.As(err, &stgErr) {
+		return interfaces.ClassifyStatus(0, err)
+	}
+	if kind, ok := errorKinds[stgErr.ServiceCode()]; ok {
+		return interfaces.Classify(kind, err)
+	}
+
+	status := 0
+	if resp := stgErr.Response(); resp != nil {
+		status = resp.StatusCode
+	}
+	return interfaces.ClassifyStatus(status, err)
+}
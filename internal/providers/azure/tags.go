@@ -0,0 +1,49 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+)
+
+// GetObjectTags returns the blob index tags of a blob
+func (c *Client) GetObjectTags(ctx context.Context, containerName, blobName string) (map[string]string, error) {
+	response, err := c.getBlobURL(containerName, blobName).GetTags(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tags of blob %s: %w", blobName, classify(err))
+	}
+
+	if len(response.BlobTagSet) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(response.BlobTagSet))
+	for _, tag := range response.BlobTagSet {
+		tags[tag.Key] = tag.Value
+	}
+	return tags, nil
+}
+
+// blobTags converts object tags to blob index tags. Index tags only accept
+// letters, digits, spaces and + - . / : = _ in keys and values, so other
+// characters, such as the @ S3 allows, become underscores.
+func blobTags(tags map[string]string) azblob.BlobTagsMap {
+	if len(tags) == 0 {
+		return nil
+	}
+	converted := azblob.BlobTagsMap{}
+	for k, v := range tags {
+		converted[tagText(k)] = tagText(v)
+	}
+	return converted
+}
+
+func tagText(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune(" +-./:=_", r) {
+			return r
+		}
+		return '_'
+	}, s)
+}
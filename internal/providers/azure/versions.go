@@ -0,0 +1,111 @@
+package azure
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Azure/azure-storage-blob-go/azblob"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// Version IDs of snapshots and of synthetic delete markers. Blob version IDs
+// are timestamps, so neither prefix nor suffix can clash with them.
+const (
+	snapshotPrefix = "snapshot:"
+	deletedSuffix  = "-deleted"
+)
+
+// ListObjectVersions lists every version of the blobs in a container whose
+// name starts with prefix, oldest first. On accounts without blob versioning
+// the snapshots of a blob are listed as its versions. Azure keeps no delete
+// markers, so one is reported after the last version of a deleted blob.
+func (c *Client) ListObjectVersions(ctx context.Context, containerName, prefix string) (map[string][]*interfaces.ObjectVersion, error) {
+	containerURL := c.getContainerURL(containerName)
+	versions := make(map[string][]*interfaces.ObjectVersion)
+	order := make(map[*interfaces.ObjectVersion]string) // Creation timestamp of each version
+
+	options := azblob.ListBlobsSegmentOptions{
+		Details: azblob.BlobListingDetails{Versions: true, Snapshots: true},
+		Prefix:  prefix,
+	}
+
+	for marker := (azblob.Marker{}); marker.NotDone(); {
+		response, err := containerURL.ListBlobsFlatSegment(ctx, marker, options)
+		if err != nil {
+			return nil, fmt.Errorf("error listing blob versions from container %s: %w", containerName, classify(err))
+		}
+		marker = response.NextMarker
+
+		for _, blob := range response.Segment.BlobItems {
+			version := &interfaces.ObjectVersion{
+				Name:         blob.Name,
+				LastModified: blob.Properties.LastModified,
+				ETag:         string(blob.Properties.Etag),
+			}
+			if blob.Properties.ContentLength != nil {
+				version.Size = *blob.Properties.ContentLength
+			}
+			version.ContentType = stringValue(blob.Properties.ContentType)
+
+			switch {
+			case blob.VersionID != nil:
+				version.VersionID = *blob.VersionID
+				version.IsLatest = blob.IsCurrentVersion != nil && *blob.IsCurrentVersion
+				order[version] = version.VersionID
+			case blob.Snapshot != "":
+				version.VersionID = snapshotPrefix + blob.Snapshot
+				order[version] = blob.Snapshot
+			default:
+				version.IsLatest = true // Base blob of an account without versioning
+			}
+			versions[blob.Name] = append(versions[blob.Name], version)
+		}
+	}
+
+	for name, list := range versions {
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].IsLatest != list[j].IsLatest {
+				return list[j].IsLatest
+			}
+			return order[list[i]] < order[list[j]]
+		})
+
+		if last := list[len(list)-1]; !last.IsLatest && !strings.HasPrefix(last.VersionID, snapshotPrefix) {
+			versions[name] = append(list, &interfaces.ObjectVersion{
+				Name:         name,
+				VersionID:    last.VersionID + deletedSuffix,
+				LastModified: last.LastModified,
+				IsLatest:     true,
+				DeleteMarker: true,
+			})
+		}
+	}
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a specific version or snapshot of a blob
+func (c *Client) GetObjectVersion(ctx context.Context, containerName, blobName, versionID string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	blobURL := c.getBlobURL(containerName, blobName)
+	if snapshot, ok := strings.CutPrefix(versionID, snapshotPrefix); ok {
+		blobURL = blobURL.WithSnapshot(snapshot)
+	} else {
+		blobURL = blobURL.WithVersionID(versionID)
+	}
+
+	props, err := blobURL.GetProperties(ctx, azblob.BlobAccessConditions{}, c.readKey())
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting properties of version %s of blob %s: %w", versionID, blobName, classify(err))
+	}
+
+	info := propertiesInfo(containerName, blobName, props)
+	info.VersionID = versionID
+
+	response, err := blobURL.Download(ctx, 0, 0, azblob.BlobAccessConditions{}, false, c.readKey())
+	if err != nil {
+		return info, nil, fmt.Errorf("error downloading version %s of blob %s: %w", versionID, blobName, classify(err))
+	}
+	return info, response.Body(azblob.RetryReaderOptions{}), nil
+}
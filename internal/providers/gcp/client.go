@@ -1,52 +1,75 @@
-// Package gcp fornece a implementação da interface de armazenamento para Google Cloud Storage
+// Package gcp provides the storage interface implementation for Google Cloud Storage
 package gcp
 
 import (
 	"context"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/http"
 
 	"cloud.google.com/go/storage"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
 	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
 )
 
-// Client implementa a interface StorageProvider para Google Cloud Storage
+// Client implements the StorageProvider interface for Google Cloud Storage
 type Client struct {
-	client    *storage.Client
-	projectID string
+	client     *storage.Client
+	projectID  string
+	encryption *interfaces.Encryption
+
+	// Authenticated HTTP client and endpoint for resumable upload sessions,
+	// which the storage package does not let a new process continue
+	httpClient *http.Client
+	uploadURL  string
 }
 
-// Config contém a configuração necessária para o cliente GCS
+// Config holds the settings required by the GCS client
 type Config struct {
-	ProjectID string // Projeto responsável pela cobrança (requester pays)
+	ProjectID string // Project billed for requests (requester pays)
+	// Optional encryption of uploaded objects; a customer-supplied key is
+	// also used to read objects
+	Encryption *interfaces.Encryption
 }
 
-// NewClient cria um novo cliente GCS
+// NewClient creates a new GCS client
 func NewClient(ctx context.Context, config Config) (*Client, error) {
 	client, err := storage.NewClient(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao criar cliente GCS: %v", err)
+		return nil, fmt.Errorf("error creating GCS client: %w", err)
+	}
+
+	httpClient, _, err := htransport.NewClient(ctx, option.WithScopes(storage.ScopeReadWrite))
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("error creating GCS HTTP client: %w", err)
 	}
 
 	return &Client{
-		client:    client,
-		projectID: config.ProjectID,
+		client:     client,
+		projectID:  config.ProjectID,
+		encryption: config.Encryption,
+		httpClient: httpClient,
+		uploadURL:  defaultUploadURL,
 	}, nil
 }
 
-// Close fecha o cliente GCS
+// Close closes the GCS client
 func (c *Client) Close() error {
 	return c.client.Close()
 }
 
-// ListObjects lista todos os objetos em um bucket específico no GCS
-func (c *Client) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
-	// Configura o bucket com RequesterPays, se necessário
+// ListObjects lists every object in a GCS bucket whose name starts with prefix
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
+	// Configure the bucket for requester pays, if needed
 	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
 
 	objects := make(map[string]*interfaces.ObjectInfo)
-	query := &storage.Query{}
+	query := &storage.Query{Prefix: prefix}
 	it := bucket.Objects(ctx, query)
 
 	for {
@@ -55,101 +78,127 @@ func (c *Client) ListObjects(ctx context.Context, bucketName string) (map[string
 			break
 		}
 		if err != nil {
-			return nil, fmt.Errorf("erro ao iterar objetos do bucket %s: %v", bucketName, err)
+			return nil, fmt.Errorf("error iterating objects in bucket %s: %w", bucketName, classify(err))
 		}
 
-		objects[objAttrs.Name] = &interfaces.ObjectInfo{
-			Name:         objAttrs.Name,
-			Bucket:       bucketName,
-			Size:         objAttrs.Size,
-			ContentType:  objAttrs.ContentType,
-			LastModified: objAttrs.Updated,
-			ETag:         objAttrs.Etag,
-			Metadata:     objAttrs.Metadata,
-		}
+		objects[objAttrs.Name] = objectInfo(bucketName, objAttrs)
 	}
 
 	return objects, nil
 }
 
-// GetObject obtém um objeto armazenado no GCS
-func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
-	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
-	obj := bucket.Object(objectName)
+// objectInfo converts the attributes of a GCS object to an ObjectInfo. Tags
+// kept in the custom metadata are not reported as metadata.
+func objectInfo(bucketName string, attrs *storage.ObjectAttrs) *interfaces.ObjectInfo {
+	metadata, _ := splitTags(attrs.Metadata)
+	return &interfaces.ObjectInfo{
+		Name:               attrs.Name,
+		Bucket:             bucketName,
+		Size:               attrs.Size,
+		ContentType:        attrs.ContentType,
+		CacheControl:       attrs.CacheControl,
+		ContentEncoding:    attrs.ContentEncoding,
+		ContentDisposition: attrs.ContentDisposition,
+		StorageClass:       attrs.StorageClass,
+		LastModified:       attrs.Updated,
+		ETag:               attrs.Etag,
+		Metadata:           interfaces.NormalizeMetadata(metadata),
+	}
+}
 
-	// Obtém os atributos do objeto
-	attrs, err := obj.Attrs(ctx)
+// StatObject returns the attributes of an object without reading its content
+func (c *Client) StatObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, error) {
+	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
+	attrs, err := c.readHandle(bucket.Object(objectName)).Attrs(ctx)
 	if err != nil {
-		return nil, nil, fmt.Errorf("erro ao obter atributos do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error getting attributes of object %s: %w", objectName, classify(err))
 	}
+	return objectInfo(bucketName, attrs), nil
+}
+
+// GetObject retrieves an object stored in GCS
+func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
+	obj := c.readHandle(bucket.Object(objectName))
 
-	// Converte para nossa estrutura ObjectInfo
-	info := &interfaces.ObjectInfo{
-		Name:         attrs.Name,
-		Bucket:       bucketName,
-		Size:         attrs.Size,
-		ContentType:  attrs.ContentType,
-		LastModified: attrs.Updated,
-		ETag:         attrs.Etag,
-		Metadata:     attrs.Metadata,
+	// Fetch the object attributes
+	info, err := c.StatObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	// Abre um leitor para o objeto
+	// Open a reader for the object
 	reader, err := obj.NewReader(ctx)
 	if err != nil {
-		return info, nil, fmt.Errorf("erro ao criar leitor para o objeto %s: %v", objectName, err)
+		return info, nil, fmt.Errorf("error creating reader for object %s: %w", objectName, classify(err))
 	}
 
 	return info, reader, nil
 }
 
-// UploadObject faz upload de um objeto para o GCS
-func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+// UploadObject uploads an object to GCS
+func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
-	obj := bucket.Object(objectName)
-
-	wc := obj.NewWriter(ctx)
-	wc.ContentType = contentType
-
-	// Copia os dados do leitor para o writer
+	enc := c.uploadEncryption(opts)
+	obj := withKey(bucket.Object(objectName), enc)
+
+	// A canceled writer discards the upload, while closing it would commit
+	// whatever was written so far.
+	writeCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	wc := obj.NewWriter(writeCtx)
+	wc.ContentType = opts.ContentType
+	wc.CacheControl = opts.CacheControl
+	wc.ContentEncoding = opts.ContentEncoding
+	wc.ContentDisposition = opts.ContentDisposition
+	wc.Metadata = objectMetadata(opts)
+	wc.StorageClass = storageclass.Convert(opts.StorageClass, storageclass.GCS)
+	wc.KMSKeyName = kmsKeyName(enc)
+
+	// Copy the data from the reader to the writer
 	written, err := io.Copy(wc, reader)
 	if err != nil {
-		wc.Close()
-		return nil, fmt.Errorf("erro ao escrever objeto %s: %v", objectName, err)
+		cancel()
+		return nil, fmt.Errorf("error writing object %s: %w", objectName, classify(err))
 	}
 
-	// Fecha o writer para completar o upload
+	// Close the writer to complete the upload
 	if err := wc.Close(); err != nil {
-		return nil, fmt.Errorf("erro ao finalizar upload do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error finishing upload of object %s: %w", objectName, classify(err))
 	}
 
-	// Obtém os atributos do objeto após o upload
+	// Fetch the object attributes after the upload
 	attrs, err := obj.Attrs(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao obter atributos após upload do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error getting attributes of object %s after upload: %w", objectName, classify(err))
 	}
 
+	// GCS always reports the CRC32C; composite objects have no MD5
 	return &interfaces.UploadInfo{
-		Bucket: bucketName,
-		Key:    objectName,
-		ETag:   attrs.Etag,
-		Size:   written,
+		Bucket:     bucketName,
+		Key:        objectName,
+		ETag:       attrs.Etag,
+		Size:       written,
+		ContentMD5: hex.EncodeToString(attrs.MD5),
+		CRC32C:     fmt.Sprintf("%08x", attrs.CRC32C),
+		Encryption: attrsEncryption(attrs),
 	}, nil
 }
 
-// DeleteObject remove um objeto do GCS
+// DeleteObject removes an object from GCS
 func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
 	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
 	obj := bucket.Object(objectName)
 
 	if err := obj.Delete(ctx); err != nil {
-		return fmt.Errorf("erro ao remover objeto %s: %v", objectName, err)
+		return fmt.Errorf("error deleting object %s: %w", objectName, classify(err))
 	}
 
 	return nil
 }
 
-// BucketExists verifica se um bucket existe no GCS
+// BucketExists checks whether a bucket exists in GCS
 func (c *Client) BucketExists(ctx context.Context, bucketName string) (bool, error) {
 	bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
 	_, err := bucket.Attrs(ctx)
@@ -157,12 +206,12 @@ func (c *Client) BucketExists(ctx context.Context, bucketName string) (bool, err
 		return false, nil
 	}
 	if err != nil {
-		return false, fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
+		return false, fmt.Errorf("error checking whether bucket %s exists: %w", bucketName, classify(err))
 	}
 	return true, nil
 }
 
-// EnsureBucketExists garante que um bucket existe no GCS
+// EnsureBucketExists makes sure a bucket exists in GCS
 func (c *Client) EnsureBucketExists(ctx context.Context, bucketName string) error {
 	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
@@ -172,7 +221,7 @@ func (c *Client) EnsureBucketExists(ctx context.Context, bucketName string) erro
 	if !exists {
 		bucket := c.client.Bucket(bucketName).UserProject(c.projectID)
 		if err := bucket.Create(ctx, c.projectID, nil); err != nil {
-			return fmt.Errorf("erro ao criar bucket %s: %v", bucketName, err)
+			return fmt.Errorf("error creating bucket %s: %w", bucketName, classify(err))
 		}
 	}
 
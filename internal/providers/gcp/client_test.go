@@ -1,11 +1,69 @@
 package gcp
 
 import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"testing/iotest"
 
+	"cloud.google.com/go/storage"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
 )
 
 func TestClient_ImplementsStorageProvider(t *testing.T) {
 	var _ interfaces.StorageProvider = (*Client)(nil)
+	var _ interfaces.RangeReader = (*Client)(nil)
+	var _ interfaces.MultipartUploader = (*Client)(nil)
+	var _ interfaces.VersionLister = (*Client)(nil)
+	var _ interfaces.TagReader = (*Client)(nil)
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{storage.ErrObjectNotExist, interfaces.ErrNotFound},
+		{storage.ErrBucketNotExist, interfaces.ErrBucketNotFound},
+		{&googleapi.Error{Code: 403}, interfaces.ErrPermissionDenied},
+		{&googleapi.Error{Code: 429}, interfaces.ErrThrottled},
+		{&googleapi.Error{Code: 500}, interfaces.ErrTransient},
+		{&googleapi.Error{Code: 412}, interfaces.ErrPreconditionFailed},
+	}
+	for _, tt := range tests {
+		if err := classify(tt.err); !errors.Is(err, tt.want) {
+			t.Errorf("classify(%v) is not %v", tt.err, tt.want)
+		}
+	}
+}
+
+func TestUploadObject_DiscardsFailedStream(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"bucket": "lake", "name": "a.txt"}`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("failed to create storage client: %v", err)
+	}
+	c := &Client{client: client}
+
+	// The source stream breaks after part of the content was read.
+	reader := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errors.New("connection reset by peer")))
+	if _, err := c.UploadObject(ctx, "lake", "a.txt", reader, 100, interfaces.UploadOptions{}); err == nil {
+		t.Fatal("expected UploadObject to fail")
+	}
+	if len(requests) != 0 {
+		t.Errorf("expected the partial upload to be discarded, got requests %v", requests)
+	}
 }
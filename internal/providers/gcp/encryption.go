@@ -0,0 +1,67 @@
+package gcp
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// uploadEncryption returns the encryption to write an object with: the one in
+// the upload options, or else the client's
+func (c *Client) uploadEncryption(opts interfaces.UploadOptions) *interfaces.Encryption {
+	if opts.Encryption != nil {
+		return opts.Encryption
+	}
+	return c.encryption
+}
+
+// withKey returns the handle of an object encrypted with enc, which carries
+// the customer-supplied key every request on such an object must send
+func withKey(obj *storage.ObjectHandle, enc *interfaces.Encryption) *storage.ObjectHandle {
+	if enc == nil || enc.Mode != interfaces.EncryptionCustomerKey {
+		return obj
+	}
+	return obj.Key(enc.Key)
+}
+
+// readHandle returns the handle to read an object with, carrying the client's
+// customer-supplied key if it has one
+func (c *Client) readHandle(obj *storage.ObjectHandle) *storage.ObjectHandle {
+	return withKey(obj, c.encryption)
+}
+
+// kmsKeyName returns the Cloud KMS key to write an object with enc, empty for
+// the bucket's default key
+func kmsKeyName(enc *interfaces.Encryption) string {
+	if enc == nil || enc.Mode != interfaces.EncryptionKMS {
+		return ""
+	}
+	return enc.KeyID
+}
+
+// setKeyHeaders adds the customer-supplied key of enc to a request of the
+// JSON API, as the storage package does for its own requests
+func setKeyHeaders(header http.Header, enc *interfaces.Encryption) {
+	if enc == nil || enc.Mode != interfaces.EncryptionCustomerKey {
+		return
+	}
+	sum := sha256.Sum256(enc.Key)
+	header.Set("X-Goog-Encryption-Algorithm", "AES256")
+	header.Set("X-Goog-Encryption-Key", base64.StdEncoding.EncodeToString(enc.Key))
+	header.Set("X-Goog-Encryption-Key-Sha256", base64.StdEncoding.EncodeToString(sum[:]))
+}
+
+// attrsEncryption returns the encryption mode of a stored object. Objects
+// without a customer-supplied or Cloud KMS key use Google-managed keys.
+func attrsEncryption(attrs *storage.ObjectAttrs) interfaces.EncryptionMode {
+	switch {
+	case attrs.CustomerKeySHA256 != "":
+		return interfaces.EncryptionCustomerKey
+	case attrs.KMSKeyName != "":
+		return interfaces.EncryptionKMS
+	}
+	return interfaces.EncryptionManaged
+}
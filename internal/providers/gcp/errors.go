@@ -0,0 +1,28 @@
+package gcp
+
+import (
+	"errors"
+
+	"cloud.google.com/go/storage"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"google.golang.org/api/googleapi"
+)
+
+// classify marks an SDK error with the matching sentinel from the interfaces package.
+func classify(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, storage.ErrObjectNotExist):
+		return interfaces.Classify(interfaces.ErrNotFound, err)
+	case errors.Is(err, storage.ErrBucketNotExist):
+		return interfaces.Classify(interfaces.ErrBucketNotFound, err)
+	}
+
+	status := 0
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		status = apiErr.Code
+	}
+	return interfaces.ClassifyStatus(status, err)
+}
@@ -0,0 +1,207 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
+)
+
+// defaultUploadURL is the JSON API endpoint that starts resumable uploads
+const defaultUploadURL = "https://storage.googleapis.com/upload/storage/v1"
+
+// statusResumeIncomplete is returned for every chunk of a resumable upload
+// but the last
+const statusResumeIncomplete = 308
+
+// GetObjectRange reads length bytes of an object starting at offset
+func (c *Client) GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	obj := c.readHandle(c.client.Bucket(bucketName).UserProject(c.projectID).Object(objectName))
+
+	reader, err := obj.NewRangeReader(ctx, offset, length)
+	if err != nil {
+		return nil, fmt.Errorf("error creating range reader for object %s: %w", objectName, classify(err))
+	}
+	return reader, nil
+}
+
+// sessionMetadata is the object resource sent when a resumable upload session
+// is started, which sets the headers and metadata of the uploaded object.
+type sessionMetadata struct {
+	ContentType        string            `json:"contentType,omitempty"`
+	CacheControl       string            `json:"cacheControl,omitempty"`
+	ContentEncoding    string            `json:"contentEncoding,omitempty"`
+	ContentDisposition string            `json:"contentDisposition,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+	StorageClass       string            `json:"storageClass,omitempty"`
+}
+
+// CreateMultipartUpload starts a resumable upload session. The session URI
+// is the upload ID; it stays valid for a week.
+func (c *Client) CreateMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	if err := c.EnsureBucketExists(ctx, upload.Bucket); err != nil {
+		return err
+	}
+
+	enc := c.uploadEncryption(upload.Options)
+	query := url.Values{"uploadType": {"resumable"}, "name": {upload.Key}}
+	if c.projectID != "" {
+		query.Set("userProject", c.projectID)
+	}
+	if key := kmsKeyName(enc); key != "" {
+		query.Set("kmsKeyName", key)
+	}
+	endpoint := fmt.Sprintf("%s/b/%s/o?%s", c.uploadURL, url.PathEscape(upload.Bucket), query.Encode())
+
+	body, err := json.Marshal(sessionMetadata{
+		ContentType:        upload.Options.ContentType,
+		CacheControl:       upload.Options.CacheControl,
+		ContentEncoding:    upload.Options.ContentEncoding,
+		ContentDisposition: upload.Options.ContentDisposition,
+		Metadata:           objectMetadata(upload.Options),
+		StorageClass:       storageclass.Convert(upload.Options.StorageClass, storageclass.GCS),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("X-Upload-Content-Length", strconv.FormatInt(upload.Size, 10))
+	if upload.Options.ContentType != "" {
+		req.Header.Set("X-Upload-Content-Type", upload.Options.ContentType)
+	}
+	setKeyHeaders(req.Header, enc)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error starting resumable upload of object %s: %w", upload.Key, interfaces.ClassifyStatus(0, err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("error starting resumable upload of object %s: %w", upload.Key, responseError(resp))
+	}
+	upload.UploadID = resp.Header.Get("Location")
+	if upload.UploadID == "" {
+		return fmt.Errorf("error starting resumable upload of object %s: no session URI in response", upload.Key)
+	}
+	return nil
+}
+
+// UploadPart sends one chunk of a resumable upload. Every part but the last
+// must be a multiple of 256 KiB.
+func (c *Client) UploadPart(ctx context.Context, upload *interfaces.MultipartUpload, number int, reader io.Reader, size int64) (*interfaces.CompletedPart, error) {
+	offset := upload.Offset(number)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, upload.UploadID, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, offset+size-1, upload.Size))
+	setKeyHeaders(req.Header, c.uploadEncryption(upload.Options))
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error uploading part %d of object %s: %w", number, upload.Key, interfaces.ClassifyStatus(0, err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		// The last chunk finalizes the object
+	case statusResumeIncomplete:
+		// The service may persist only part of a chunk; the rest is sent again
+		if persisted := persistedBytes(resp.Header.Get("Range")); persisted < offset+size {
+			return nil, fmt.Errorf("error uploading part %d of object %s: %w", number, upload.Key,
+				interfaces.Classify(interfaces.ErrTransient, fmt.Errorf("only %d of %d bytes persisted", persisted, offset+size)))
+		}
+	default:
+		return nil, fmt.Errorf("error uploading part %d of object %s: %w", number, upload.Key, responseError(resp))
+	}
+
+	return &interfaces.CompletedPart{Number: number, Size: size}, nil
+}
+
+// persistedBytes parses the "bytes=0-N" Range header of a resumable upload
+// status into the number of bytes stored so far.
+func persistedBytes(header string) int64 {
+	_, last, ok := strings.Cut(strings.TrimPrefix(header, "bytes="), "-")
+	if !ok {
+		return 0
+	}
+	n, err := strconv.ParseInt(last, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n + 1
+}
+
+// CompleteMultipartUpload returns the object finalized by the last chunk
+func (c *Client) CompleteMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload, parts []interfaces.CompletedPart) (*interfaces.UploadInfo, error) {
+	obj := withKey(c.client.Bucket(upload.Bucket).UserProject(c.projectID).Object(upload.Key), c.uploadEncryption(upload.Options))
+	attrs, err := obj.Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting attributes of object %s after upload: %w", upload.Key, classify(err))
+	}
+	if attrs.Size != upload.Size {
+		return nil, fmt.Errorf("object %s has %d bytes after upload, expected %d", upload.Key, attrs.Size, upload.Size)
+	}
+
+	// A resumable upload creates a regular object, with the MD5 of its content
+	return &interfaces.UploadInfo{
+		Bucket:     upload.Bucket,
+		Key:        upload.Key,
+		ETag:       attrs.Etag,
+		Size:       attrs.Size,
+		ContentMD5: hex.EncodeToString(attrs.MD5),
+		CRC32C:     fmt.Sprintf("%08x", attrs.CRC32C),
+		Encryption: attrsEncryption(attrs),
+	}, nil
+}
+
+// AbortMultipartUpload cancels a resumable upload session
+func (c *Client) AbortMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, upload.UploadID, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("error aborting resumable upload of object %s: %w", upload.Key, interfaces.ClassifyStatus(0, err))
+	}
+	defer resp.Body.Close()
+
+	// A cancelled session answers 499; an expired one 404 or 410
+	switch resp.StatusCode {
+	case 499, http.StatusNoContent, http.StatusNotFound, http.StatusGone:
+		return nil
+	}
+	return fmt.Errorf("error aborting resumable upload of object %s: %w", upload.Key, responseError(resp))
+}
+
+// responseError builds a classified error from an unexpected response of the
+// upload endpoint. An expired session (410) is reported as ErrNotFound so the
+// caller starts a new one.
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err := errors.New(strings.TrimSpace(fmt.Sprintf("%s %s", resp.Status, body)))
+	if resp.StatusCode == http.StatusGone {
+		return interfaces.Classify(interfaces.ErrNotFound, err)
+	}
+	return interfaces.ClassifyStatus(resp.StatusCode, err)
+}
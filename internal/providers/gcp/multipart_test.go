@@ -0,0 +1,97 @@
+package gcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"google.golang.org/api/option"
+)
+
+func TestResumableUploadSession(t *testing.T) {
+	var ranges []string
+	var received bytes.Buffer
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /storage/v1/b/lake", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"name": "lake"}`))
+	})
+	mux.HandleFunc("POST /upload/b/lake/o", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("uploadType") != "resumable" || r.URL.Query().Get("name") != "dir/big.bin" {
+			t.Errorf("unexpected session request: %s", r.URL)
+		}
+		var object sessionMetadata
+		if err := json.NewDecoder(r.Body).Decode(&object); err != nil {
+			t.Errorf("failed to decode session metadata: %v", err)
+		}
+		if object.ContentEncoding != "gzip" || object.Metadata["owner"] != "data" {
+			t.Errorf("unexpected session metadata: %+v", object)
+		}
+		if r.Header.Get("X-Goog-Encryption-Key-Sha256") == "" {
+			t.Error("session request carries no customer-supplied key")
+		}
+		w.Header().Set("Location", "http://"+r.Host+"/session/1")
+	})
+	mux.HandleFunc("PUT /session/1", func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Content-Range"))
+		if r.Header.Get("X-Goog-Encryption-Key") == "" {
+			t.Error("chunk carries no customer-supplied key")
+		}
+		io.Copy(&received, r.Body)
+		if received.Len() < 10 {
+			w.Header().Set("Range", "bytes=0-3") // Only part of the second chunk was persisted
+			w.WriteHeader(statusResumeIncomplete)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx, option.WithEndpoint(server.URL+"/storage/v1/"), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("failed to create storage client: %v", err)
+	}
+	c := &Client{client: client, httpClient: server.Client(), uploadURL: server.URL + "/upload"}
+
+	upload := &interfaces.MultipartUpload{Bucket: "lake", Key: "dir/big.bin", Size: 10, PartSize: 4,
+		Options: interfaces.UploadOptions{ContentEncoding: "gzip", Metadata: map[string]string{"owner": "data"},
+			Encryption: &interfaces.Encryption{Mode: interfaces.EncryptionCustomerKey, Key: make([]byte, interfaces.CustomerKeySize)}}}
+	if err := c.CreateMultipartUpload(ctx, upload); err != nil {
+		t.Fatalf("CreateMultipartUpload returned error: %v", err)
+	}
+	if upload.UploadID != server.URL+"/session/1" {
+		t.Fatalf("expected the session URI as upload ID, got %q", upload.UploadID)
+	}
+
+	if _, err := c.UploadPart(ctx, upload, 1, strings.NewReader("0123"), 4); err != nil {
+		t.Fatalf("UploadPart(1) returned error: %v", err)
+	}
+	_, err = c.UploadPart(ctx, upload, 2, strings.NewReader("4567"), 4)
+	if !errors.Is(err, interfaces.ErrTransient) {
+		t.Fatalf("expected a transient error for a partially persisted chunk, got %v", err)
+	}
+	if _, err := c.UploadPart(ctx, upload, 3, strings.NewReader("89"), 2); err != nil {
+		t.Fatalf("UploadPart(3) returned error: %v", err)
+	}
+
+	want := []string{"bytes 0-3/10", "bytes 4-7/10", "bytes 8-9/10"}
+	if strings.Join(ranges, ",") != strings.Join(want, ",") {
+		t.Errorf("Content-Range headers = %v, want %v", ranges, want)
+	}
+}
+
+func TestPersistedBytes(t *testing.T) {
+	tests := map[string]int64{"bytes=0-262143": 262144, "": 0, "bytes=0-x": 0}
+	for header, want := range tests {
+		if got := persistedBytes(header); got != want {
+			t.Errorf("persistedBytes(%q) = %d, want %d", header, got, want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// GetObjectTags returns the tags of an object. GCS has no object tags, so
+// they are kept as custom metadata entries named
+// interfaces.TagMetadataPrefix plus the tag key.
+func (c *Client) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	attrs, err := c.client.Bucket(bucketName).UserProject(c.projectID).Object(objectName).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting attributes of object %s: %w", objectName, classify(err))
+	}
+	_, tags := splitTags(attrs.Metadata)
+	return tags, nil
+}
+
+// splitTags separates the tag entries of custom metadata from the rest.
+func splitTags(metadata map[string]string) (map[string]string, map[string]string) {
+	var rest, tags map[string]string
+	for k, v := range metadata {
+		if len(k) > len(interfaces.TagMetadataPrefix) && strings.EqualFold(k[:len(interfaces.TagMetadataPrefix)], interfaces.TagMetadataPrefix) {
+			if tags == nil {
+				tags = make(map[string]string)
+			}
+			tags[k[len(interfaces.TagMetadataPrefix):]] = v
+			continue
+		}
+		if rest == nil {
+			rest = make(map[string]string)
+		}
+		rest[k] = v
+	}
+	return rest, tags
+}
+
+// objectMetadata returns the custom metadata to store an object with: its
+// user metadata and its tags.
+func objectMetadata(opts interfaces.UploadOptions) map[string]string {
+	if len(opts.Tags) == 0 {
+		return opts.Metadata
+	}
+	metadata := make(map[string]string, len(opts.Metadata)+len(opts.Tags))
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range opts.Tags {
+		metadata[interfaces.TagMetadataPrefix+k] = v
+	}
+	return metadata
+}
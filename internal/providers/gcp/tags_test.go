@@ -0,0 +1,29 @@
+package gcp
+
+import (
+	"testing"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+func TestTagsInMetadata(t *testing.T) {
+	metadata := objectMetadata(interfaces.UploadOptions{
+		Metadata: map[string]string{"owner": "data"},
+		Tags:     map[string]string{"Tier": "cold"},
+	})
+	if len(metadata) != 2 || metadata["owner"] != "data" || metadata["tag-Tier"] != "cold" {
+		t.Fatalf("objectMetadata() = %v, want owner and tag-Tier", metadata)
+	}
+
+	rest, tags := splitTags(metadata)
+	if len(rest) != 1 || rest["owner"] != "data" {
+		t.Errorf("metadata without tags = %v, want only owner", rest)
+	}
+	if len(tags) != 1 || tags["Tier"] != "cold" {
+		t.Errorf("tags = %v, want Tier=cold", tags)
+	}
+
+	if rest, tags := splitTags(map[string]string{"tag-": "x"}); tags != nil || rest["tag-"] != "x" {
+		t.Errorf("an entry named only by the prefix should stay metadata, got %v and %v", rest, tags)
+	}
+}
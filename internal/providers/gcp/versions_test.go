@@ -0,0 +1,60 @@
+package gcp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+func TestGenerationVersions(t *testing.T) {
+	t0 := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name        string
+		generations []*storage.ObjectAttrs
+		want        string
+	}{
+		{
+			"overwritten",
+			[]*storage.ObjectAttrs{
+				{Name: "a", Generation: 2, Created: t0.Add(time.Hour)},
+				{Name: "a", Generation: 1, Created: t0, Deleted: t0.Add(time.Hour)},
+			},
+			"[1 2(latest)]",
+		},
+		{
+			"deleted and created again",
+			[]*storage.ObjectAttrs{
+				{Name: "a", Generation: 1, Created: t0, Deleted: t0.Add(time.Hour)},
+				{Name: "a", Generation: 2, Created: t0.Add(2 * time.Hour)},
+			},
+			"[1 1-deleted(marker) 2(latest)]",
+		},
+		{
+			"deleted",
+			[]*storage.ObjectAttrs{{Name: "a", Generation: 1, Created: t0, Deleted: t0.Add(time.Hour)}},
+			"[1 1-deleted(marker,latest)]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, v := range generationVersions(tt.generations) {
+				id := v.VersionID
+				switch {
+				case v.DeleteMarker && v.IsLatest:
+					id += "(marker,latest)"
+				case v.DeleteMarker:
+					id += "(marker)"
+				case v.IsLatest:
+					id += "(latest)"
+				}
+				got = append(got, id)
+			}
+			if fmt.Sprint(got) != tt.want {
+				t.Errorf("generationVersions() = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,20 +1,28 @@
-// Package minio fornece a implementação da interface de armazenamento para MinIO/S3-compatible
+// Package minio provides the storage interface implementation for MinIO/S3-compatible services
 package minio
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 
 	"github.com/minio/minio-go/v7"
 	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
 
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
 )
 
 type Client struct {
-	client *minio.Client
+	client     *minio.Client
+	encryption *interfaces.Encryption
+	readKey    encrypt.ServerSide // SSE-C key for reading objects, nil without a customer key
 }
 
 type Config struct {
@@ -23,6 +31,9 @@ type Config struct {
 	SecretKey string
 	UseSSL    bool
 	Region    string
+	// Optional encryption of uploaded objects; a customer key is also used
+	// to read objects
+	Encryption *interfaces.Encryption
 }
 
 func NewClient(config Config) (*Client, error) {
@@ -32,122 +43,239 @@ func NewClient(config Config) (*Client, error) {
 		Region: config.Region,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("erro ao criar cliente MinIO: %v", err)
+		return nil, fmt.Errorf("error creating MinIO client: %w", err)
 	}
 
-	return &Client{client: client}, nil
+	readKey, err := customerKey(config.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{client: client, encryption: config.Encryption, readKey: readKey}, nil
 }
 
 func (c *Client) EnsureBucketExists(ctx context.Context, bucketName string) error {
-	exists, err := c.client.BucketExists(ctx, bucketName)
+	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
-		return fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
+		return err
 	}
 
 	if !exists {
-		log.Printf("Bucket '%s' não existe, criando...", bucketName)
+		log.Printf("Bucket '%s' does not exist, creating...", bucketName)
 		err = c.client.MakeBucket(ctx, bucketName, minio.MakeBucketOptions{})
 		if err != nil {
-			return fmt.Errorf("erro ao criar o bucket %s: %v", bucketName, err)
+			return fmt.Errorf("error creating bucket %s: %w", bucketName, classify(err))
 		}
-		log.Printf("Bucket '%s' criado com sucesso", bucketName)
+		log.Printf("Bucket '%s' created successfully", bucketName)
 	}
 
 	return nil
 }
 
-func (c *Client) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
+func (c *Client) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
 	objects := make(map[string]*interfaces.ObjectInfo)
 
-	exists, err := c.client.BucketExists(ctx, bucketName)
+	exists, err := c.BucketExists(ctx, bucketName)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao verificar se o bucket %s existe: %v", bucketName, err)
+		return nil, err
 	}
 
 	if !exists {
-		return objects, nil
+		return nil, fmt.Errorf("error listing objects in bucket %s: %w", bucketName, interfaces.ErrBucketNotFound)
 	}
 
 	objectCh := c.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
-		Recursive: true,
+		Prefix:       prefix,
+		Recursive:    true,
+		WithMetadata: true, // Only MinIO servers return it
 	})
 
 	for object := range objectCh {
 		if object.Err != nil {
-			return nil, fmt.Errorf("erro ao listar objetos do bucket %s: %v", bucketName, object.Err)
+			return nil, fmt.Errorf("error listing objects in bucket %s: %w", bucketName, classify(object.Err))
 		}
 
-		objects[object.Key] = &interfaces.ObjectInfo{
+		info := &interfaces.ObjectInfo{
 			Name:         object.Key,
 			Bucket:       bucketName,
 			Size:         object.Size,
 			ContentType:  object.ContentType,
+			StorageClass: object.StorageClass,
 			LastModified: object.LastModified,
 			ETag:         object.ETag,
-			Metadata:     object.UserMetadata,
 		}
+		setListedMetadata(info, object.UserMetadata)
+		objects[object.Key] = info
 	}
 
 	return objects, nil
 }
 
-func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
-	objInfo, err := c.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{})
+// StatObject returns the metadata of an object without reading its content
+func (c *Client) StatObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, error) {
+	objInfo, err := c.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{ServerSideEncryption: c.readKey})
 	if err != nil {
-		return nil, nil, fmt.Errorf("erro ao obter metadados do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error getting metadata of object %s: %w", objectName, classify(err))
 	}
+	return statInfo(bucketName, objInfo), nil
+}
 
-	info := &interfaces.ObjectInfo{
-		Name:         objInfo.Key,
-		Bucket:       bucketName,
-		Size:         objInfo.Size,
-		ContentType:  objInfo.ContentType,
-		LastModified: objInfo.LastModified,
-		ETag:         objInfo.ETag,
-		Metadata:     objInfo.UserMetadata,
+func (c *Client) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	info, err := c.StatObject(ctx, bucketName, objectName)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	reader, err := c.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{})
+	reader, err := c.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{ServerSideEncryption: c.readKey})
 	if err != nil {
-		return info, nil, fmt.Errorf("erro ao obter objeto %s: %v", objectName, err)
+		return info, nil, fmt.Errorf("error getting object %s: %w", objectName, classify(err))
 	}
 
 	return info, reader, nil
 }
 
-func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+// statInfo converts the result of a StatObject call to an ObjectInfo
+func statInfo(bucketName string, objInfo minio.ObjectInfo) *interfaces.ObjectInfo {
+	return &interfaces.ObjectInfo{
+		Name:               objInfo.Key,
+		Bucket:             bucketName,
+		Size:               objInfo.Size,
+		ContentType:        objInfo.ContentType,
+		CacheControl:       objInfo.Metadata.Get("Cache-Control"),
+		ContentEncoding:    objInfo.Metadata.Get("Content-Encoding"),
+		ContentDisposition: objInfo.Metadata.Get("Content-Disposition"),
+		StorageClass:       statStorageClass(objInfo.StorageClass),
+		LastModified:       objInfo.LastModified,
+		ETag:               objInfo.ETag,
+		Metadata:           interfaces.NormalizeMetadata(objInfo.UserMetadata),
+	}
+}
+
+// setListedMetadata sets the headers and user metadata of a listed object
+// from the metadata MinIO servers return in listings, which holds both the
+// standard headers and the x-amz-meta- prefixed user metadata.
+func setListedMetadata(info *interfaces.ObjectInfo, listed map[string]string) {
+	metadata := make(map[string]string)
+	for k, v := range listed {
+		key := strings.ToLower(k)
+		switch key {
+		case "cache-control":
+			info.CacheControl = v
+		case "content-encoding":
+			info.ContentEncoding = v
+		case "content-disposition":
+			info.ContentDisposition = v
+		default:
+			if name, ok := strings.CutPrefix(key, "x-amz-meta-"); ok {
+				metadata[name] = v
+			}
+		}
+	}
+	info.Metadata = interfaces.NormalizeMetadata(metadata)
+}
+
+// putOptions converts upload options to the options of a MinIO upload, which
+// sends the metadata as x-amz-meta- headers and the tags as x-amz-tagging,
+// encrypting the object with sse
+func putOptions(opts interfaces.UploadOptions, sse encrypt.ServerSide) minio.PutObjectOptions {
+	return minio.PutObjectOptions{
+		ContentType:          opts.ContentType,
+		CacheControl:         opts.CacheControl,
+		ContentEncoding:      opts.ContentEncoding,
+		ContentDisposition:   opts.ContentDisposition,
+		UserMetadata:         opts.Metadata,
+		UserTags:             opts.Tags,
+		StorageClass:         storageclass.Convert(opts.StorageClass, storageclass.S3),
+		ServerSideEncryption: sse,
+	}
+}
+
+// statStorageClass returns the storage class reported by StatObject, which
+// is empty for STANDARD objects
+func statStorageClass(class string) string {
+	if class == "" {
+		return "STANDARD"
+	}
+	return class
+}
+
+// RequiresSize reports that uploads need their size: PutObject buffers
+// content of unknown size in parts of hundreds of megabytes.
+func (c *Client) RequiresSize() bool {
+	return true
+}
+
+func (c *Client) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	err := c.EnsureBucketExists(ctx, bucketName)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao garantir que o bucket %s existe: %v", bucketName, err)
+		return nil, fmt.Errorf("error ensuring bucket %s exists: %w", bucketName, err)
 	}
 
-	info, err := c.client.PutObject(ctx, bucketName, objectName, reader, size, minio.PutObjectOptions{
-		ContentType: contentType,
-	})
+	enc := c.uploadEncryption(opts)
+	sse, err := serverSide(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.client.PutObject(ctx, bucketName, objectName, reader, size, putOptions(opts, sse))
 
 	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer upload do objeto %s: %v", objectName, err)
+		return nil, fmt.Errorf("error uploading object %s: %w", objectName, classify(err))
 	}
 
 	return &interfaces.UploadInfo{
-		Bucket: bucketName,
-		Key:    objectName,
-		ETag:   info.ETag,
-		Size:   info.Size,
+		Bucket:     bucketName,
+		Key:        objectName,
+		ETag:       info.ETag,
+		Size:       info.Size,
+		ContentMD5: uploadedMD5(info.ETag, modeOf(enc)),
+		CRC32C:     crc32cFromChecksum(info.ChecksumCRC32C),
+		Encryption: modeOf(enc),
 	}, nil
 }
 
+// md5FromETag returns the hex MD5 carried by an ETag, or an empty string when
+// the ETag is not an MD5 of the content (multipart or encrypted uploads)
+func md5FromETag(etag string) string {
+	etag = strings.ToLower(strings.Trim(etag, `"`))
+	if len(etag) != 2*md5.Size {
+		return ""
+	}
+	if _, err := hex.DecodeString(etag); err != nil {
+		return ""
+	}
+	return etag
+}
+
+// crc32cFromChecksum converts the base64 CRC32C reported by the server to hex.
+// Composite checksums of multipart uploads ("<base64>-N") do not cover the
+// whole object and are ignored.
+func crc32cFromChecksum(checksum string) string {
+	if checksum == "" || strings.Contains(checksum, "-") {
+		return ""
+	}
+	raw, err := base64.StdEncoding.DecodeString(checksum)
+	if err != nil || len(raw) != 4 {
+		return ""
+	}
+	return hex.EncodeToString(raw)
+}
+
 func (c *Client) DeleteObject(ctx context.Context, bucketName, objectName string) error {
 	err := c.client.RemoveObject(ctx, bucketName, objectName, minio.RemoveObjectOptions{})
 	if err != nil {
-		return fmt.Errorf("erro ao remover objeto %s: %v", objectName, err)
+		return fmt.Errorf("error deleting object %s: %w", objectName, classify(err))
 	}
 
 	return nil
 }
 
 func (c *Client) BucketExists(ctx context.Context, bucketName string) (bool, error) {
-	return c.client.BucketExists(ctx, bucketName)
+	exists, err := c.client.BucketExists(ctx, bucketName)
+	if err != nil {
+		return false, fmt.Errorf("error checking whether bucket %s exists: %w", bucketName, classify(err))
+	}
+	return exists, nil
 }
 
 func (c *Client) Close() error {
@@ -1,11 +1,99 @@
 package minio
 
 import (
+	"errors"
 	"testing"
 
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
 )
 
 func TestClient_ImplementsStorageProvider(t *testing.T) {
 	var _ interfaces.StorageProvider = (*Client)(nil)
+	var _ interfaces.RangeReader = (*Client)(nil)
+	var _ interfaces.MultipartUploader = (*Client)(nil)
+	var _ interfaces.VersionLister = (*Client)(nil)
+	var _ interfaces.TagReader = (*Client)(nil)
+}
+
+func TestChecksumsFromResponse(t *testing.T) {
+	if got := md5FromETag(`"5D41402ABC4B2A76B9719D911017C592"`); got != "5d41402abc4b2a76b9719d911017c592" {
+		t.Errorf("md5FromETag = %q, want the lower-case MD5", got)
+	}
+	if got := md5FromETag(`"5d41402abc4b2a76b9719d911017c592-3"`); got != "" {
+		t.Errorf("md5FromETag of a multipart ETag = %q, want empty", got)
+	}
+	if got := crc32cFromChecksum("mnG7TA=="); got != "9a71bb4c" {
+		t.Errorf("crc32cFromChecksum = %q, want 9a71bb4c", got)
+	}
+	if got := crc32cFromChecksum("mnG7TA==-2"); got != "" {
+		t.Errorf("crc32cFromChecksum of a composite checksum = %q, want empty", got)
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{minio.ErrorResponse{Code: "NoSuchKey", StatusCode: 404}, interfaces.ErrNotFound},
+		{minio.ErrorResponse{Code: "NoSuchBucket", StatusCode: 404}, interfaces.ErrBucketNotFound},
+		{minio.ErrorResponse{Code: "AccessDenied", StatusCode: 403}, interfaces.ErrPermissionDenied},
+		{minio.ErrorResponse{Code: "SlowDown", StatusCode: 503}, interfaces.ErrThrottled},
+		{minio.ErrorResponse{Code: "Unknown", StatusCode: 500}, interfaces.ErrTransient},
+	}
+	for _, tt := range tests {
+		if err := classify(tt.err); !errors.Is(err, tt.want) {
+			t.Errorf("classify(%v) is not %v", tt.err, tt.want)
+		}
+	}
+}
+
+func TestSetListedMetadata(t *testing.T) {
+	info := &interfaces.ObjectInfo{}
+	setListedMetadata(info, map[string]string{
+		"content-type":        "text/css",
+		"Content-Encoding":    "gzip",
+		"Cache-Control":       "max-age=60",
+		"X-Amz-Meta-Owner":    "data",
+		"X-Amz-Tagging-Count": "1",
+	})
+	if info.ContentEncoding != "gzip" || info.CacheControl != "max-age=60" || info.ContentDisposition != "" {
+		t.Errorf("unexpected headers: %+v", info)
+	}
+	if len(info.Metadata) != 1 || info.Metadata["owner"] != "data" {
+		t.Errorf("Metadata = %v, want only owner", info.Metadata)
+	}
+}
+
+func TestServerSide(t *testing.T) {
+	key := make([]byte, interfaces.CustomerKeySize)
+	tests := []struct {
+		enc      *interfaces.Encryption
+		want     encrypt.Type // Empty for none
+		readsKey bool
+	}{
+		{nil, "", false},
+		{&interfaces.Encryption{Mode: interfaces.EncryptionManaged}, encrypt.S3, false},
+		{&interfaces.Encryption{Mode: interfaces.EncryptionKMS, KeyID: "replicas"}, encrypt.KMS, false},
+		{&interfaces.Encryption{Mode: interfaces.EncryptionCustomerKey, Key: key}, encrypt.SSEC, true},
+	}
+	for _, tt := range tests {
+		sse, err := serverSide(tt.enc)
+		if err != nil {
+			t.Fatalf("serverSide(%+v) returned error: %v", tt.enc, err)
+		}
+		if (sse == nil) != (tt.want == "") || sse != nil && sse.Type() != tt.want {
+			t.Errorf("serverSide(%+v) = %v, want type %q", tt.enc, sse, tt.want)
+		}
+		if readKey, _ := customerKey(tt.enc); (readKey != nil) != tt.readsKey {
+			t.Errorf("customerKey(%+v) = %v, want a key: %v", tt.enc, readKey, tt.readsKey)
+		}
+	}
+
+	if _, err := serverSide(&interfaces.Encryption{Mode: interfaces.EncryptionCustomerKey, Key: key[:16]}); err == nil {
+		t.Error("serverSide accepted a 128-bit customer key")
+	}
 }
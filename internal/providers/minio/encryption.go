@@ -0,0 +1,70 @@
+package minio
+
+import (
+	"fmt"
+
+	"github.com/minio/minio-go/v7/pkg/encrypt"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// uploadEncryption returns the encryption to write an object with: the one in
+// the upload options, or else the client's
+func (c *Client) uploadEncryption(opts interfaces.UploadOptions) *interfaces.Encryption {
+	if opts.Encryption != nil {
+		return opts.Encryption
+	}
+	return c.encryption
+}
+
+// serverSide converts an encryption to its MinIO form, nil for the bucket
+// default
+func serverSide(enc *interfaces.Encryption) (encrypt.ServerSide, error) {
+	if enc == nil {
+		return nil, nil
+	}
+	switch enc.Mode {
+	case interfaces.EncryptionManaged:
+		return encrypt.NewSSE(), nil
+	case interfaces.EncryptionKMS:
+		sse, err := encrypt.NewSSEKMS(enc.KeyID, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up SSE-KMS: %w", err)
+		}
+		return sse, nil
+	case interfaces.EncryptionCustomerKey:
+		sse, err := encrypt.NewSSEC(enc.Key)
+		if err != nil {
+			return nil, fmt.Errorf("error setting up SSE-C: %w", err)
+		}
+		return sse, nil
+	}
+	return nil, nil
+}
+
+// customerKey returns the SSE-C key every request reading or writing an
+// object encrypted with enc must carry, nil unless enc uses a customer key
+func customerKey(enc *interfaces.Encryption) (encrypt.ServerSide, error) {
+	if enc == nil || enc.Mode != interfaces.EncryptionCustomerKey {
+		return nil, nil
+	}
+	return serverSide(enc)
+}
+
+// uploadedMD5 returns the hex MD5 carried by the ETag of an upload encrypted
+// with mode. Objects encrypted with a KMS or customer key have ETags that are
+// not the MD5 of their content.
+func uploadedMD5(etag string, mode interfaces.EncryptionMode) string {
+	if mode == interfaces.EncryptionKMS || mode == interfaces.EncryptionCustomerKey {
+		return ""
+	}
+	return md5FromETag(etag)
+}
+
+// modeOf returns the mode of enc, which may be nil
+func modeOf(enc *interfaces.Encryption) interfaces.EncryptionMode {
+	if enc == nil {
+		return interfaces.EncryptionDefault
+	}
+	return enc.Mode
+}
@@ -0,0 +1,38 @@
+package minio
+
+import (
+	"github.com/minio/minio-go/v7"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// errorKinds maps S3 error codes returned by MinIO to the provider-independent sentinels.
+var errorKinds = map[string]error{
+	"NoSuchKey":                  interfaces.ErrNotFound,
+	"NoSuchUpload":               interfaces.ErrNotFound,
+	"NoSuchBucket":               interfaces.ErrBucketNotFound,
+	"AccessDenied":               interfaces.ErrPermissionDenied,
+	"InvalidAccessKeyId":         interfaces.ErrPermissionDenied,
+	"SignatureDoesNotMatch":      interfaces.ErrPermissionDenied,
+	"SlowDown":                   interfaces.ErrThrottled,
+	"SlowDownRead":               interfaces.ErrThrottled,
+	"SlowDownWrite":              interfaces.ErrThrottled,
+	"XMinioServerNotInitialized": interfaces.ErrTransient,
+	"InternalError":              interfaces.ErrTransient,
+	"ServiceUnavailable":         interfaces.ErrTransient,
+	"RequestTimeout":             interfaces.ErrTransient,
+	"PreconditionFailed":         interfaces.ErrPreconditionFailed,
+}
+
+// classify marks an SDK error with the matching sentinel from the interfaces package.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	resp := minio.ToErrorResponse(err)
+	if kind, ok := errorKinds[resp.Code]; ok {
+		return interfaces.Classify(kind, err)
+	}
+	return interfaces.ClassifyStatus(resp.StatusCode, err)
+}
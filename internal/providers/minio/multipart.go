@@ -0,0 +1,107 @@
+package minio
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// GetObjectRange reads length bytes of an object starting at offset
+func (c *Client) GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{ServerSideEncryption: c.readKey}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, fmt.Errorf("error setting range of object %s: %w", objectName, err)
+	}
+
+	reader, _, _, err := c.core().GetObject(ctx, bucketName, objectName, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error getting range of object %s: %w", objectName, classify(err))
+	}
+	return reader, nil
+}
+
+// core exposes the low-level S3 API needed for multipart uploads
+func (c *Client) core() minio.Core {
+	return minio.Core{Client: c.client}
+}
+
+// CreateMultipartUpload starts a multipart upload
+func (c *Client) CreateMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	if err := c.EnsureBucketExists(ctx, upload.Bucket); err != nil {
+		return fmt.Errorf("error ensuring bucket %s exists: %w", upload.Bucket, err)
+	}
+
+	sse, err := serverSide(c.uploadEncryption(upload.Options))
+	if err != nil {
+		return err
+	}
+
+	uploadID, err := c.core().NewMultipartUpload(ctx, upload.Bucket, upload.Key, putOptions(upload.Options, sse))
+	if err != nil {
+		return fmt.Errorf("error starting multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+
+	upload.UploadID = uploadID
+	return nil
+}
+
+// UploadPart uploads one part of a multipart upload
+func (c *Client) UploadPart(ctx context.Context, upload *interfaces.MultipartUpload, number int, reader io.Reader, size int64) (*interfaces.CompletedPart, error) {
+	enc := c.uploadEncryption(upload.Options)
+	key, err := customerKey(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	part, err := c.core().PutObjectPart(ctx, upload.Bucket, upload.Key, upload.UploadID, number, reader, size, minio.PutObjectPartOptions{SSE: key})
+	if err != nil {
+		return nil, fmt.Errorf("error uploading part %d of object %s: %w", number, upload.Key, classify(err))
+	}
+
+	return &interfaces.CompletedPart{
+		Number:     number,
+		ETag:       part.ETag,
+		Size:       part.Size,
+		ContentMD5: uploadedMD5(part.ETag, modeOf(enc)),
+	}, nil
+}
+
+// CompleteMultipartUpload assembles the uploaded parts into the object
+func (c *Client) CompleteMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload, parts []interfaces.CompletedPart) (*interfaces.UploadInfo, error) {
+	completed := make([]minio.CompletePart, len(parts))
+	for i, part := range parts {
+		completed[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+
+	enc := c.uploadEncryption(upload.Options)
+	sse, err := serverSide(enc)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.core().CompleteMultipartUpload(ctx, upload.Bucket, upload.Key, upload.UploadID, completed, putOptions(upload.Options, sse))
+	if err != nil {
+		return nil, fmt.Errorf("error completing multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+
+	// The ETag of a multipart object is not the MD5 of its content
+	return &interfaces.UploadInfo{
+		Bucket:     upload.Bucket,
+		Key:        upload.Key,
+		ETag:       info.ETag,
+		Size:       upload.Size,
+		Encryption: modeOf(enc),
+	}, nil
+}
+
+// AbortMultipartUpload discards a multipart upload and its parts
+func (c *Client) AbortMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	if err := c.core().AbortMultipartUpload(ctx, upload.Bucket, upload.Key, upload.UploadID); err != nil {
+		return fmt.Errorf("error aborting multipart upload of object %s: %w", upload.Key, classify(err))
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package minio
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// GetObjectTags returns the tags of an object
+func (c *Client) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	tags, err := c.client.GetObjectTagging(ctx, bucketName, objectName, minio.GetObjectTaggingOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error getting tags of object %s: %w", objectName, classify(err))
+	}
+
+	if tagMap := tags.ToMap(); len(tagMap) > 0 {
+		return tagMap, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,71 @@
+package minio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+
+	"github.com/minio/minio-go/v7"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// ListObjectVersions lists every version and delete marker of the objects in
+// a bucket whose name starts with prefix, oldest first
+func (c *Client) ListObjectVersions(ctx context.Context, bucketName, prefix string) (map[string][]*interfaces.ObjectVersion, error) {
+	versions := make(map[string][]*interfaces.ObjectVersion)
+
+	objectCh := c.client.ListObjects(ctx, bucketName, minio.ListObjectsOptions{
+		Prefix:       prefix,
+		Recursive:    true,
+		WithVersions: true,
+	})
+
+	// Versions of a key are listed newest first
+	for object := range objectCh {
+		if object.Err != nil {
+			return nil, fmt.Errorf("error listing object versions in bucket %s: %w", bucketName, classify(object.Err))
+		}
+
+		versions[object.Key] = append(versions[object.Key], &interfaces.ObjectVersion{
+			Name:         object.Key,
+			VersionID:    object.VersionID,
+			Size:         object.Size,
+			ContentType:  object.ContentType,
+			LastModified: object.LastModified,
+			ETag:         object.ETag,
+			IsLatest:     object.IsLatest,
+			DeleteMarker: object.IsDeleteMarker,
+		})
+	}
+
+	for _, list := range versions {
+		slices.Reverse(list)
+		sort.SliceStable(list, func(i, j int) bool {
+			if list[i].IsLatest != list[j].IsLatest {
+				return list[j].IsLatest
+			}
+			return list[i].LastModified.Before(list[j].LastModified)
+		})
+	}
+	return versions, nil
+}
+
+// GetObjectVersion retrieves a specific version of an object
+func (c *Client) GetObjectVersion(ctx context.Context, bucketName, objectName, versionID string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	objInfo, err := c.client.StatObject(ctx, bucketName, objectName, minio.StatObjectOptions{VersionID: versionID, ServerSideEncryption: c.readKey})
+	if err != nil {
+		return nil, nil, fmt.Errorf("error getting metadata of version %s of object %s: %w", versionID, objectName, classify(err))
+	}
+
+	info := statInfo(bucketName, objInfo)
+	info.VersionID = versionID
+
+	reader, err := c.client.GetObject(ctx, bucketName, objectName, minio.GetObjectOptions{VersionID: versionID, ServerSideEncryption: c.readKey})
+	if err != nil {
+		return info, nil, fmt.Errorf("error getting version %s of object %s: %w", versionID, objectName, classify(err))
+	}
+	return info, reader, nil
+}
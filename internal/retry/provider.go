@@ -0,0 +1,266 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// Provider wraps a StorageProvider and retries its operations according to a
+// Policy. Uploads are only retried when the reader can be rewound; callers
+// streaming from another provider reopen the source and retry themselves,
+// using PolicyOf to honour the target's policy.
+type Provider struct {
+	interfaces.StorageProvider
+	policy Policy
+	logger *slog.Logger
+}
+
+// Wrap returns provider with its operations retried according to policy. A
+// provider that implements both interfaces.RangeReader and
+// interfaces.MultipartUploader keeps them, and so does one implementing
+// interfaces.VersionLister, with retries applied as well. The returned
+// provider always implements interfaces.TagReader, failing with
+// errors.ErrUnsupported when provider does not, and
+// interfaces.SizeRequirer.
+func Wrap(provider interfaces.StorageProvider, policy Policy, logger *slog.Logger) interfaces.StorageProvider {
+	p := &Provider{StorageProvider: provider, policy: policy, logger: logger}
+
+	ranged, isRanged := provider.(interfaces.RangeReader)
+	multipart, isMultipart := provider.(interfaces.MultipartUploader)
+	lister, isVersioned := provider.(interfaces.VersionLister)
+	switch {
+	case isRanged && isMultipart && isVersioned:
+		return &versionedResumableProvider{
+			resumableProvider: &resumableProvider{Provider: p, ranged: ranged, multipart: multipart},
+			versions:          &versions{p: p, lister: lister},
+		}
+	case isRanged && isMultipart:
+		return &resumableProvider{Provider: p, ranged: ranged, multipart: multipart}
+	case isVersioned:
+		return &versionedProvider{Provider: p, versions: &versions{p: p, lister: lister}}
+	}
+	return p
+}
+
+// PolicyOf returns the retry policy of a provider returned by Wrap, or a
+// policy that never retries for any other provider.
+func PolicyOf(provider interfaces.StorageProvider) Policy {
+	if p, ok := provider.(interface{ retryPolicy() Policy }); ok {
+		return p.retryPolicy()
+	}
+	return Policy{MaxAttempts: 1}
+}
+
+func (p *Provider) retryPolicy() Policy {
+	return p.policy
+}
+
+func (p *Provider) do(ctx context.Context, op, bucketName, objectName string, fn func() error) error {
+	return Do(ctx, p.policy, fn, func(attempt int, delay time.Duration, err error) {
+		p.logger.Warn("Storage operation failed, retrying",
+			"operation", op, "bucket", bucketName, "object_name", objectName,
+			"attempt", attempt, "max_attempts", p.policy.MaxAttempts, "delay", delay, "error", err)
+	})
+}
+
+func (p *Provider) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
+	var objects map[string]*interfaces.ObjectInfo
+	err := p.do(ctx, "ListObjects", bucketName, prefix, func() error {
+		var err error
+		objects, err = p.StorageProvider.ListObjects(ctx, bucketName, prefix)
+		return err
+	})
+	return objects, err
+}
+
+func (p *Provider) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	var info *interfaces.ObjectInfo
+	var reader io.ReadCloser
+	err := p.do(ctx, "GetObject", bucketName, objectName, func() error {
+		var err error
+		info, reader, err = p.StorageProvider.GetObject(ctx, bucketName, objectName)
+		return err
+	})
+	return info, reader, err
+}
+
+func (p *Provider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	var info *interfaces.UploadInfo
+	err := p.doRewind(ctx, "UploadObject", bucketName, objectName, reader, func() error {
+		var err error
+		info, err = p.StorageProvider.UploadObject(ctx, bucketName, objectName, reader, size, opts)
+		return err
+	})
+	return info, err
+}
+
+// doRewind retries fn, which consumes reader, only when reader can be rewound
+// to where it was before the first attempt.
+func (p *Provider) doRewind(ctx context.Context, op, bucketName, objectName string, reader io.Reader, fn func() error) error {
+	seeker, ok := reader.(io.Seeker)
+	if !ok {
+		return fn()
+	}
+
+	start, err := seeker.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fn()
+	}
+
+	return p.do(ctx, op, bucketName, objectName, func() error {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+		return fn()
+	})
+}
+
+// RequiresSize reports whether the wrapped provider needs the size of uploads
+// in advance.
+func (p *Provider) RequiresSize() bool {
+	sized, ok := p.StorageProvider.(interfaces.SizeRequirer)
+	return ok && sized.RequiresSize()
+}
+
+func (p *Provider) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	tagger, ok := p.StorageProvider.(interfaces.TagReader)
+	if !ok {
+		return nil, fmt.Errorf("error getting tags of object %s: %w", objectName, errors.ErrUnsupported)
+	}
+
+	var tags map[string]string
+	err := p.do(ctx, "GetObjectTags", bucketName, objectName, func() error {
+		var err error
+		tags, err = tagger.GetObjectTags(ctx, bucketName, objectName)
+		return err
+	})
+	return tags, err
+}
+
+func (p *Provider) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	return p.do(ctx, "DeleteObject", bucketName, objectName, func() error {
+		return p.StorageProvider.DeleteObject(ctx, bucketName, objectName)
+	})
+}
+
+func (p *Provider) BucketExists(ctx context.Context, bucketName string) (bool, error) {
+	var exists bool
+	err := p.do(ctx, "BucketExists", bucketName, "", func() error {
+		var err error
+		exists, err = p.StorageProvider.BucketExists(ctx, bucketName)
+		return err
+	})
+	return exists, err
+}
+
+func (p *Provider) EnsureBucketExists(ctx context.Context, bucketName string) error {
+	return p.do(ctx, "EnsureBucketExists", bucketName, "", func() error {
+		return p.StorageProvider.EnsureBucketExists(ctx, bucketName)
+	})
+}
+
+// resumableProvider is a Provider whose wrapped provider can read ranges and
+// upload in parts.
+type resumableProvider struct {
+	*Provider
+	ranged    interfaces.RangeReader
+	multipart interfaces.MultipartUploader
+}
+
+func (p *resumableProvider) StatObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, error) {
+	var info *interfaces.ObjectInfo
+	err := p.do(ctx, "StatObject", bucketName, objectName, func() error {
+		var err error
+		info, err = p.ranged.StatObject(ctx, bucketName, objectName)
+		return err
+	})
+	return info, err
+}
+
+func (p *resumableProvider) GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	err := p.do(ctx, "GetObjectRange", bucketName, objectName, func() error {
+		var err error
+		reader, err = p.ranged.GetObjectRange(ctx, bucketName, objectName, offset, length)
+		return err
+	})
+	return reader, err
+}
+
+func (p *resumableProvider) CreateMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	return p.do(ctx, "CreateMultipartUpload", upload.Bucket, upload.Key, func() error {
+		return p.multipart.CreateMultipartUpload(ctx, upload)
+	})
+}
+
+func (p *resumableProvider) UploadPart(ctx context.Context, upload *interfaces.MultipartUpload, number int, reader io.Reader, size int64) (*interfaces.CompletedPart, error) {
+	var part *interfaces.CompletedPart
+	err := p.doRewind(ctx, "UploadPart", upload.Bucket, upload.Key, reader, func() error {
+		var err error
+		part, err = p.multipart.UploadPart(ctx, upload, number, reader, size)
+		return err
+	})
+	return part, err
+}
+
+func (p *resumableProvider) CompleteMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload, parts []interfaces.CompletedPart) (*interfaces.UploadInfo, error) {
+	var info *interfaces.UploadInfo
+	err := p.do(ctx, "CompleteMultipartUpload", upload.Bucket, upload.Key, func() error {
+		var err error
+		info, err = p.multipart.CompleteMultipartUpload(ctx, upload, parts)
+		return err
+	})
+	return info, err
+}
+
+func (p *resumableProvider) AbortMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	return p.do(ctx, "AbortMultipartUpload", upload.Bucket, upload.Key, func() error {
+		return p.multipart.AbortMultipartUpload(ctx, upload)
+	})
+}
+
+// versions retries the version operations of a provider.
+type versions struct {
+	p      *Provider
+	lister interfaces.VersionLister
+}
+
+func (v *versions) ListObjectVersions(ctx context.Context, bucketName, prefix string) (map[string][]*interfaces.ObjectVersion, error) {
+	var list map[string][]*interfaces.ObjectVersion
+	err := v.p.do(ctx, "ListObjectVersions", bucketName, "", func() error {
+		var err error
+		list, err = v.lister.ListObjectVersions(ctx, bucketName, prefix)
+		return err
+	})
+	return list, err
+}
+
+func (v *versions) GetObjectVersion(ctx context.Context, bucketName, objectName, versionID string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	var info *interfaces.ObjectInfo
+	var reader io.ReadCloser
+	err := v.p.do(ctx, "GetObjectVersion", bucketName, objectName, func() error {
+		var err error
+		info, reader, err = v.lister.GetObjectVersion(ctx, bucketName, objectName, versionID)
+		return err
+	})
+	return info, reader, err
+}
+
+// versionedProvider is a Provider whose wrapped provider can list versions.
+type versionedProvider struct {
+	*Provider
+	*versions
+}
+
+// versionedResumableProvider is a resumableProvider whose wrapped provider
+// can list versions as well.
+type versionedResumableProvider struct {
+	*resumableProvider
+	*versions
+}
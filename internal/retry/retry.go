@@ -0,0 +1,190 @@
+// Package retry retries failed storage operations with exponential backoff and jitter.
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// Defaults used for the zero fields of a Policy built from configuration.
+const (
+	DefaultMaxAttempts = 3
+	DefaultBaseDelay   = 200 * time.Millisecond
+	DefaultMaxDelay    = 10 * time.Second
+	DefaultJitter      = 0.5
+)
+
+// Policy describes how an operation is retried.
+type Policy struct {
+	MaxAttempts int           // Attempts including the first; values below 2 disable retries
+	BaseDelay   time.Duration // Delay after the first failure, doubled after each further failure
+	MaxDelay    time.Duration // Upper bound for a single delay
+	Jitter      float64       // Fraction (0-1) of each delay that is randomized
+	Retryable   func(error) bool
+}
+
+// DefaultPolicy returns the policy used when a provider configures none.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts: DefaultMaxAttempts,
+		BaseDelay:   DefaultBaseDelay,
+		MaxDelay:    DefaultMaxDelay,
+		Jitter:      DefaultJitter,
+		Retryable:   IsTransient,
+	}
+}
+
+// ShouldRetry reports whether an operation that failed with err on the given
+// attempt (starting at 1) should be attempted again.
+func (p Policy) ShouldRetry(err error, attempt int) bool {
+	if err == nil || attempt >= p.MaxAttempts {
+		return false
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if p.Retryable == nil {
+		return IsTransient(err)
+	}
+	return p.Retryable(err)
+}
+
+// Delay returns how long to wait after the given failed attempt (starting at
+// 1). The first jitter fraction of the delay is fixed and the rest random, so
+// concurrent workers spread their retries.
+func (p Policy) Delay(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay) && delay < time.Hour; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter <= 0 || delay <= 0 {
+		return delay
+	}
+
+	random := time.Duration(float64(delay) * p.Jitter)
+	return delay - random + rand.N(random+1)
+}
+
+// Do calls fn until it succeeds, fails with an error the policy does not
+// retry, attempts run out or ctx is done. onRetry, if not nil, is called
+// before each wait.
+func Do(ctx context.Context, p Policy, fn func() error, onRetry func(attempt int, delay time.Duration, err error)) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if !p.ShouldRetry(err, attempt) {
+			if err != nil && attempt > 1 {
+				return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+			}
+			return err
+		}
+
+		delay := p.Delay(attempt)
+		if onRetry != nil {
+			onRetry(attempt, delay, err)
+		}
+		if err := Sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Sleep waits for d or until ctx is done, in which case it returns ctx.Err().
+func Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// transientStatus matches HTTP 429 and 5xx statuses as the SDKs print them,
+// e.g. "status code: 503", "Error 429:" or "Status: 500".
+var transientStatus = regexp.MustCompile(`(?i)(status( code)?:?|error) (429|5\d\d)\b`)
+
+// transientMessages are error message fragments, in lower case, reported by
+// the SDKs for throttling and temporary service failures.
+var transientMessages = []string{
+	"slowdown",
+	"reduce your request rate",
+	"throttl",
+	"too many requests",
+	"requesttimeout",
+	"internalerror",
+	"serviceunavailable",
+	"server busy",
+	"serverbusy",
+	"connection reset",
+	"broken pipe",
+	"unexpected eof",
+	"i/o timeout",
+}
+
+// IsTransient reports whether err is a temporary failure. Errors classified
+// by the providers are decided by their sentinel; other errors are treated as
+// transient when they look like a network timeout or reset, a throttling
+// response or a 5xx server error.
+func IsTransient(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, interfaces.ErrThrottled), errors.Is(err, interfaces.ErrTransient):
+		return true
+	case errors.Is(err, interfaces.ErrNotFound), errors.Is(err, interfaces.ErrBucketNotFound),
+		errors.Is(err, interfaces.ErrPermissionDenied), errors.Is(err, interfaces.ErrPreconditionFailed):
+		return false
+	}
+
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	msg := err.Error()
+	if transientStatus.MatchString(msg) {
+		return true
+	}
+	return containsAny(strings.ToLower(msg), transientMessages)
+}
+
+// MatchAny returns a classifier that treats an error as retryable when base
+// does or when its message contains one of fragments (case-insensitive).
+func MatchAny(base func(error) bool, fragments []string) func(error) bool {
+	if len(fragments) == 0 {
+		return base
+	}
+	lowered := make([]string, len(fragments))
+	for i, fragment := range fragments {
+		lowered[i] = strings.ToLower(fragment)
+	}
+	return func(err error) bool {
+		return base(err) || containsAny(strings.ToLower(err.Error()), lowered)
+	}
+}
+
+func containsAny(s string, fragments []string) bool {
+	for _, fragment := range fragments {
+		if strings.Contains(s, fragment) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package retry
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("SlowDown: Please reduce your request rate. status code: 503, request id: X"), true},
+		{errors.New("googleapi: Error 429: rate limit exceeded"), true},
+		{errors.New("RESPONSE Status: 500 Internal Server Error"), true},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{errors.New("read tcp 10.0.0.1:443: connection reset by peer"), true},
+		{errors.New("AccessDenied: status code: 403"), false},
+		{errors.New("error uploading object report-500.csv: NoSuchBucket"), false},
+		{interfaces.Classify(interfaces.ErrThrottled, errors.New("server busy")), true},
+		{interfaces.Classify(interfaces.ErrNotFound, errors.New("status code: 503")), false},
+	}
+	for _, tt := range tests {
+		if got := IsTransient(tt.err); got != tt.want {
+			t.Errorf("IsTransient(%q) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestPolicy_Delay(t *testing.T) {
+	p := Policy{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	want := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second}
+	for i, w := range want {
+		if got := p.Delay(i + 1); got != w {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, w)
+		}
+	}
+
+	p.Jitter = 0.5
+	for range 100 {
+		if got := p.Delay(2); got < 100*time.Millisecond || got > 200*time.Millisecond {
+			t.Fatalf("Delay(2) with jitter = %s, want between 100ms and 200ms", got)
+		}
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	transient := errors.New("status code: 503")
+
+	calls := 0
+	err := Do(context.Background(), p, func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	}, nil)
+	if err != nil || calls != 3 {
+		t.Errorf("expected success on the third attempt, got err=%v after %d calls", err, calls)
+	}
+
+	calls = 0
+	err = Do(context.Background(), p, func() error { calls++; return transient }, nil)
+	if !errors.Is(err, transient) || calls != 3 {
+		t.Errorf("expected the last error after 3 attempts, got err=%v after %d calls", err, calls)
+	}
+
+	calls = 0
+	permanent := errors.New("status code: 403")
+	err = Do(context.Background(), p, func() error { calls++; return permanent }, nil)
+	if !errors.Is(err, permanent) || calls != 1 {
+		t.Errorf("expected no retry of a permanent error, got err=%v after %d calls", err, calls)
+	}
+}
+
+// flakyProvider fails the first upload after reading part of the stream.
+type flakyProvider struct {
+	interfaces.StorageProvider
+	uploads int
+	got     []byte
+}
+
+func (f *flakyProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	f.uploads++
+	if f.uploads == 1 {
+		io.CopyN(io.Discard, reader, 2)
+		return nil, errors.New("write: broken pipe")
+	}
+	f.got, _ = io.ReadAll(reader)
+	return &interfaces.UploadInfo{Bucket: bucketName, Key: objectName, Size: size}, nil
+}
+
+func TestProvider_UploadRewindsSeekableReader(t *testing.T) {
+	flaky := &flakyProvider{}
+	p := Wrap(flaky, Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	if _, err := p.UploadObject(context.Background(), "b", "k", bytes.NewReader([]byte("hello")), 5, interfaces.UploadOptions{}); err != nil {
+		t.Fatalf("UploadObject returned error: %v", err)
+	}
+	if flaky.uploads != 2 || string(flaky.got) != "hello" {
+		t.Errorf("expected a second upload of the whole content, got %d uploads and %q", flaky.uploads, flaky.got)
+	}
+
+	// Streams that cannot be rewound are left to the caller to reopen.
+	flaky = &flakyProvider{}
+	p = Wrap(flaky, Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if _, err := p.UploadObject(context.Background(), "b", "k", io.MultiReader(bytes.NewReader([]byte("hello"))), 5, interfaces.UploadOptions{}); err == nil {
+		t.Fatal("expected the upload error, got nil")
+	}
+	if flaky.uploads != 1 {
+		t.Errorf("expected a single upload attempt, got %d", flaky.uploads)
+	}
+}
+
+// partsProvider can read ranges and upload in parts.
+type partsProvider struct {
+	interfaces.StorageProvider
+	interfaces.RangeReader
+	interfaces.MultipartUploader
+}
+
+// versionedPartsProvider can list versions as well.
+type versionedPartsProvider struct {
+	partsProvider
+	interfaces.VersionLister
+}
+
+func TestWrap_KeepsMultipartCapabilities(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	p := Wrap(partsProvider{}, Policy{MaxAttempts: 2}, logger)
+	if _, ok := p.(interfaces.RangeReader); !ok {
+		t.Error("expected the wrapped provider to read ranges")
+	}
+	if _, ok := p.(interfaces.MultipartUploader); !ok {
+		t.Error("expected the wrapped provider to upload in parts")
+	}
+	if got := PolicyOf(p).MaxAttempts; got != 2 {
+		t.Errorf("expected the policy of the wrapper, got %d attempts", got)
+	}
+
+	if _, ok := Wrap(&flakyProvider{}, Policy{}, logger).(interfaces.MultipartUploader); ok {
+		t.Error("expected a provider without multipart support to stay without it")
+	}
+	if _, ok := p.(interfaces.VersionLister); ok {
+		t.Error("expected a provider without version support to stay without it")
+	}
+
+	p = Wrap(versionedPartsProvider{}, Policy{MaxAttempts: 3}, logger)
+	if _, ok := p.(interfaces.VersionLister); !ok {
+		t.Error("expected the wrapped provider to list versions")
+	}
+	if _, ok := p.(interfaces.MultipartUploader); !ok {
+		t.Error("expected the wrapped provider to keep uploading in parts")
+	}
+	if got := PolicyOf(p).MaxAttempts; got != 3 {
+		t.Errorf("expected the policy of the wrapper, got %d attempts", got)
+	}
+}
+
+// taggedProvider reads tags, failing on the first attempt.
+type taggedProvider struct {
+	interfaces.StorageProvider
+	calls int
+}
+
+func (f *taggedProvider) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	f.calls++
+	if f.calls == 1 {
+		return nil, errors.New("status code: 503")
+	}
+	return map[string]string{"tier": "cold"}, nil
+}
+
+func TestProvider_GetObjectTags(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tagged := &taggedProvider{}
+	p := Wrap(tagged, Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}, logger)
+	tags, err := p.(interfaces.TagReader).GetObjectTags(context.Background(), "b", "k")
+	if err != nil || tags["tier"] != "cold" || tagged.calls != 2 {
+		t.Errorf("expected the tags after a retry, got %v, %v after %d calls", tags, err, tagged.calls)
+	}
+
+	p = Wrap(&flakyProvider{}, Policy{MaxAttempts: 2}, logger)
+	if _, err := p.(interfaces.TagReader).GetObjectTags(context.Background(), "b", "k"); !errors.Is(err, errors.ErrUnsupported) {
+		t.Errorf("expected errors.ErrUnsupported for a provider without tags, got %v", err)
+	}
+}
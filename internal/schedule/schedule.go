@@ -0,0 +1,236 @@
+// Package schedule parses the run schedules of mappings: standard 5-field
+// cron expressions, the usual @daily style shorthands and @every intervals.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule computes when a mapping runs next.
+type Schedule interface {
+	// Next returns the first run time after t, or the zero time if the
+	// schedule never runs again.
+	Next(t time.Time) time.Time
+}
+
+// Every runs at a fixed interval from the start of the previous run.
+type Every time.Duration
+
+// Next returns t plus the interval.
+func (e Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(e))
+}
+
+func (e Every) String() string {
+	return "@every " + time.Duration(e).String()
+}
+
+// shorthands are the cron expressions behind the @ descriptors.
+var shorthands = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule: a cron expression with the fields minute, hour,
+// day of month, month and day of week, a shorthand such as @daily or
+// @hourly, or "@every <duration>", e.g. "@every 5m". Cron expressions are
+// evaluated in the local time zone.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if rest, ok := strings.CutPrefix(spec, "@every "); ok {
+		d, err := time.ParseDuration(strings.TrimSpace(rest))
+		if err != nil || d < time.Second {
+			return nil, fmt.Errorf("@every needs a duration of at least 1s: %q", rest)
+		}
+		return Every(d), nil
+	}
+	if expr, ok := shorthands[strings.ToLower(spec)]; ok {
+		spec = expr
+	} else if strings.HasPrefix(spec, "@") {
+		return nil, fmt.Errorf("unknown schedule descriptor: %s", spec)
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression must have 5 fields (minute hour day-of-month month day-of-week), got %d: %q", len(fields), spec)
+	}
+	c := &cron{spec: spec}
+	var err error
+	for i, f := range []struct {
+		bits *uint64
+		spec field
+	}{
+		{&c.minute, minute},
+		{&c.hour, hour},
+		{&c.dom, dayOfMonth},
+		{&c.month, month},
+		{&c.dow, dayOfWeek},
+	} {
+		if *f.bits, err = f.spec.parse(fields[i]); err != nil {
+			return nil, fmt.Errorf("invalid %s field %q: %w", f.spec.name, fields[i], err)
+		}
+	}
+	if c.dow&(1<<7) != 0 { // 7 is another name for Sunday
+		c.dow |= 1
+	}
+	c.domStar = strings.HasPrefix(fields[2], "*")
+	c.dowStar = strings.HasPrefix(fields[4], "*")
+
+	if c.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression never matches a date: %q", spec)
+	}
+	return c, nil
+}
+
+// field describes one field of a cron expression.
+type field struct {
+	name     string
+	min, max int
+	names    []string // Names of the values from min, e.g. JAN for 1
+}
+
+var (
+	minute     = field{name: "minute", min: 0, max: 59}
+	hour       = field{name: "hour", min: 0, max: 23}
+	dayOfMonth = field{name: "day-of-month", min: 1, max: 31}
+	month      = field{name: "month", min: 1, max: 12,
+		names: []string{"JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}}
+	dayOfWeek = field{name: "day-of-week", min: 0, max: 7,
+		names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}}
+)
+
+// parse returns the set of values a field matches as a bit mask. Each
+// comma-separated item is *, a value or a range a-b, optionally followed by
+// /step; a value followed by a step runs up to the field's maximum.
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepText); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step: %q", stepText)
+			}
+		}
+
+		var lo, hi int
+		switch lowText, highText, isRange := strings.Cut(rng, "-"); {
+		case rng == "*":
+			lo, hi = f.min, f.max
+		case isRange:
+			var err error
+			if lo, err = f.value(lowText); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(highText); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("range %s starts after it ends", rng)
+			}
+		default:
+			var err error
+			if lo, err = f.value(rng); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if hasStep {
+				hi = f.max
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a number or name of the field.
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %q", s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("value %d outside %d-%d", v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// cron is a parsed cron expression. Each field is a bit mask of the values
+// it matches.
+type cron struct {
+	spec                          string
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool // The field starts with *, i.e. does not restrict the day
+}
+
+func (c *cron) String() string {
+	return c.spec
+}
+
+// maxSearchYears bounds the search for the next run, long enough to reach
+// the next 29 February.
+const maxSearchYears = 8
+
+// Next returns the first minute after t that matches the expression, in t's
+// location. Times skipped by a daylight saving change are not run, and times
+// it repeats run once.
+func (c *cron) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + maxSearchYears
+
+	for t.Year() <= limit {
+		switch {
+		case c.month&(1<<int(t.Month())) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc))
+		case !c.dayMatches(t):
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc))
+		case c.hour&(1<<t.Hour()) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc))
+		case c.minute&(1<<t.Minute()) == 0:
+			t = forward(t, time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc))
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// forward returns next, the start of the following month, day, hour or
+// minute, unless time.Date normalized a wall time skipped by a daylight
+// saving change to before t, in which case it returns t an hour later.
+func forward(t, next time.Time) time.Time {
+	if next.After(t) {
+		return next
+	}
+	return t.Add(time.Hour)
+}
+
+// dayMatches reports whether t's day matches the day-of-month and
+// day-of-week fields. As in cron, a day matching either is enough when both
+// restrict the day.
+func (c *cron) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<t.Day()) != 0
+	dow := c.dow&(1<<int(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,107 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		wantErr bool
+	}{
+		{"* * * * *", false},
+		{"0 2 * * *", false},
+		{"*/15 8-18 * * MON-FRI", false},
+		{"0 0 1,15 jan,jul 7", false},
+		{"@daily", false},
+		{"@HOURLY", false},
+		{"@every 5m", false},
+		{"@every 1h30m", false},
+		{"", true},
+		{"0 2 * *", true},
+		{"0 2 * * * *", true},
+		{"60 * * * *", true},
+		{"* 24 * * *", true},
+		{"* * 0 * *", true},
+		{"* * * 13 *", true},
+		{"* * * * 8", true},
+		{"5-1 * * * *", true},
+		{"*/0 * * * *", true},
+		{"* * * FOO *", true},
+		{"0 0 30 2 *", true},
+		{"@every", true},
+		{"@every 10ms", true},
+		{"@every soon", true},
+		{"@reboot", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			_, err := Parse(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 2025-05-01 is a Thursday.
+	from := time.Date(2025, 5, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2025, 5, 1, 10, 8, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2025, 5, 2, 2, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 5, 1, 10, 15, 0, 0, time.UTC)},
+		{"5/20 * * * *", time.Date(2025, 5, 1, 10, 25, 0, 0, time.UTC)},
+		{"30 9 * * MON-FRI", time.Date(2025, 5, 2, 9, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 5, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 13 * 5", time.Date(2025, 5, 2, 0, 0, 0, 0, time.UTC)}, // Either day field matches
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{"@every 5m", from.Add(5 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.spec, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, want %s", from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNext_DaylightSaving(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone database not available: %v", err)
+	}
+	s, err := Parse("30 2 * * *")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	// 02:30 does not exist on 2025-03-09, when clocks skip from 02:00 to 03:00.
+	got := s.Next(time.Date(2025, 3, 8, 12, 0, 0, 0, loc))
+	if want := time.Date(2025, 3, 10, 2, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next across the spring change = %s, want %s", got, want)
+	}
+
+	// 01:30 happens twice on 2025-11-02, when clocks go back from 02:00 to
+	// 01:00; it runs at the first.
+	s, err = Parse("30 1 * * *")
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	first := s.Next(time.Date(2025, 11, 2, 0, 0, 0, 0, loc))
+	if _, offset := first.Zone(); first.Hour() != 1 || first.Minute() != 30 || offset != -4*3600 {
+		t.Errorf("Next before the fall change = %s, want 01:30 EDT", first)
+	}
+	if got, want := s.Next(first), time.Date(2025, 11, 3, 1, 30, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Next after the first 01:30 = %s, want %s", got, want)
+	}
+}
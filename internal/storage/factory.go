@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog" // Import slog
+	"time"
 
 	"github.com/DjonatanS/cloud-data-sync/internal/config"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
@@ -11,6 +12,7 @@ import (
 	"github.com/DjonatanS/cloud-data-sync/internal/providers/azure"
 	"github.com/DjonatanS/cloud-data-sync/internal/providers/gcp"
 	"github.com/DjonatanS/cloud-data-sync/internal/providers/minio"
+	"github.com/DjonatanS/cloud-data-sync/internal/retry"
 )
 
 // Factory manages storage provider instances
@@ -29,34 +31,39 @@ func NewFactory(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*
 
 	for _, providerCfg := range cfg.Providers {
 		var provider interfaces.StorageProvider
-		var err error
 
 		factory.logger.Info("Initializing storage provider", "provider_id", providerCfg.ID, "provider_type", providerCfg.Type)
 
+		encryption, err := providerCfg.Encryption.Encryption()
+		if err != nil {
+			factory.Close()
+			return nil, fmt.Errorf("provider %s has invalid encryption settings: %w", providerCfg.ID, err)
+		}
+
 		switch providerCfg.Type {
 		case config.GCS:
 			if providerCfg.GCS == nil {
 				return nil, fmt.Errorf("GCS provider %s has no configuration", providerCfg.ID)
 			}
-			provider, err = createGCSProvider(ctx, providerCfg)
+			provider, err = createGCSProvider(ctx, providerCfg, encryption)
 
 		case config.MINIO:
 			if providerCfg.MinIO == nil {
 				return nil, fmt.Errorf("MinIO provider %s has no configuration", providerCfg.ID)
 			}
-			provider, err = createMinioProvider(providerCfg)
+			provider, err = createMinioProvider(providerCfg, encryption)
 
 		case config.AWS:
 			if providerCfg.AWS == nil {
 				return nil, fmt.Errorf("AWS provider %s has no configuration", providerCfg.ID)
 			}
-			provider, err = createAWSProvider(providerCfg)
+			provider, err = createAWSProvider(providerCfg, encryption)
 
 		case config.AZURE:
 			if providerCfg.Azure == nil {
 				return nil, fmt.Errorf("Azure provider %s has no configuration", providerCfg.ID)
 			}
-			provider, err = createAzureProvider(providerCfg)
+			provider, err = createAzureProvider(providerCfg, encryption)
 
 		default:
 			return nil, fmt.Errorf("unknown provider type: %s", providerCfg.Type)
@@ -66,11 +73,13 @@ func NewFactory(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*
 			factory.logger.Error("Failed to initialize provider", "provider_id", providerCfg.ID, "provider_type", providerCfg.Type, "error", err)
 			// Close any previously initialized providers before returning error
 			factory.Close()
-			return nil, fmt.Errorf("error creating provider %s: %v", providerCfg.ID, err)
+			return nil, fmt.Errorf("error creating provider %s: %w", providerCfg.ID, err)
 		}
 
-		factory.providers[providerCfg.ID] = provider
-		factory.logger.Info("Successfully initialized provider", "provider_id", providerCfg.ID, "provider_type", providerCfg.Type)
+		policy := retryPolicy(providerCfg.Retry)
+		factory.providers[providerCfg.ID] = retry.Wrap(provider, policy, factory.logger.With("provider_id", providerCfg.ID))
+		factory.logger.Info("Successfully initialized provider", "provider_id", providerCfg.ID, "provider_type", providerCfg.Type,
+			"retry_max_attempts", policy.MaxAttempts)
 	}
 
 	return factory, nil
@@ -101,43 +110,71 @@ func (f *Factory) Close() {
 	f.logger.Info("Storage provider connections closed.")
 }
 
-func createGCSProvider(ctx context.Context, providerCfg config.ProviderConfig) (interfaces.StorageProvider, error) {
+// retryPolicy builds a provider's retry policy, filling unset fields with the
+// retry package defaults.
+func retryPolicy(cfg *config.RetryConfig) retry.Policy {
+	policy := retry.DefaultPolicy()
+	if cfg == nil {
+		return policy
+	}
+
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.BaseDelay > 0 {
+		policy.BaseDelay = time.Duration(cfg.BaseDelay)
+	}
+	if cfg.MaxDelay > 0 {
+		policy.MaxDelay = time.Duration(cfg.MaxDelay)
+	}
+	if cfg.Jitter != nil {
+		policy.Jitter = *cfg.Jitter
+	}
+	policy.Retryable = retry.MatchAny(retry.IsTransient, cfg.RetryableErrors)
+	return policy
+}
+
+func createGCSProvider(ctx context.Context, providerCfg config.ProviderConfig, encryption *interfaces.Encryption) (interfaces.StorageProvider, error) {
 	clientConfig := gcp.Config{
-		ProjectID: providerCfg.GCS.ProjectID,
+		ProjectID:  providerCfg.GCS.ProjectID,
+		Encryption: encryption,
 	}
 
 	return gcp.NewClient(ctx, clientConfig)
 }
 
-func createMinioProvider(providerCfg config.ProviderConfig) (interfaces.StorageProvider, error) {
+func createMinioProvider(providerCfg config.ProviderConfig, encryption *interfaces.Encryption) (interfaces.StorageProvider, error) {
 	clientConfig := minio.Config{
-		Endpoint:  providerCfg.MinIO.Endpoint,
-		AccessKey: providerCfg.MinIO.AccessKey,
-		SecretKey: providerCfg.MinIO.SecretKey,
-		UseSSL:    providerCfg.MinIO.UseSSL,
-		Region:    providerCfg.MinIO.Region,
+		Endpoint:   providerCfg.MinIO.Endpoint,
+		AccessKey:  providerCfg.MinIO.AccessKey,
+		SecretKey:  providerCfg.MinIO.SecretKey,
+		UseSSL:     providerCfg.MinIO.UseSSL,
+		Region:     providerCfg.MinIO.Region,
+		Encryption: encryption,
 	}
 
 	return minio.NewClient(clientConfig)
 }
 
-func createAWSProvider(providerCfg config.ProviderConfig) (interfaces.StorageProvider, error) {
+func createAWSProvider(providerCfg config.ProviderConfig, encryption *interfaces.Encryption) (interfaces.StorageProvider, error) {
 	clientConfig := aws.Config{
 		Region:          providerCfg.AWS.Region,
 		AccessKeyID:     providerCfg.AWS.AccessKeyID,
 		SecretAccessKey: providerCfg.AWS.SecretAccessKey,
 		Endpoint:        providerCfg.AWS.Endpoint,
 		DisableSSL:      providerCfg.AWS.DisableSSL,
+		Encryption:      encryption,
 	}
 
 	return aws.NewClient(clientConfig)
 }
 
-func createAzureProvider(providerCfg config.ProviderConfig) (interfaces.StorageProvider, error) {
+func createAzureProvider(providerCfg config.ProviderConfig, encryption *interfaces.Encryption) (interfaces.StorageProvider, error) {
 	clientConfig := azure.Config{
 		AccountName: providerCfg.Azure.AccountName,
 		AccountKey:  providerCfg.Azure.AccountKey,
 		EndpointURL: providerCfg.Azure.EndpointURL,
+		Encryption:  encryption,
 	}
 
 	return azure.NewClient(clientConfig)
@@ -15,13 +15,13 @@ import (
 // and simulates no-op behavior
 type fakeProvider struct{}
 
-func (f *fakeProvider) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
+func (f *fakeProvider) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
 	return nil, nil
 }
 func (f *fakeProvider) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
 	return nil, nil, nil
 }
-func (f *fakeProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+func (f *fakeProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	return nil, nil
 }
 func (f *fakeProvider) DeleteObject(ctx context.Context, bucketName, objectName string) error {
@@ -0,0 +1,96 @@
+// Package storageclass translates storage classes between providers: S3
+// storage classes, GCS storage classes and Azure access tiers.
+package storageclass
+
+import "strings"
+
+// Preserve is the mapping setting that stores each object in the class
+// equivalent to its class in the source.
+const Preserve = "preserve"
+
+// Provider is a family of providers sharing the same class names.
+type Provider int
+
+const (
+	S3    Provider = iota // Amazon S3 and S3-compatible services such as MinIO
+	GCS                   // Google Cloud Storage
+	Azure                 // Azure Blob Storage access tiers
+)
+
+// tier is a row of equivalent classes. The first name of each provider is
+// the one objects of the tier are written with; the others are only read.
+type tier struct {
+	names [3][]string // By Provider
+}
+
+var tiers = []tier{
+	{names: [3][]string{
+		{"STANDARD", "INTELLIGENT_TIERING", "REDUCED_REDUNDANCY", "EXPRESS_ONEZONE"},
+		{"STANDARD", "MULTI_REGIONAL", "REGIONAL", "DURABLE_REDUCED_AVAILABILITY"},
+		{"Hot", "Premium"},
+	}},
+	{names: [3][]string{
+		{"STANDARD_IA", "ONEZONE_IA"},
+		{"NEARLINE"},
+		{"Cool"},
+	}},
+	{names: [3][]string{
+		{"GLACIER_IR"},
+		{"COLDLINE"},
+		{"Cool"}, // The Cold tier is newer than the API version of the Azure client
+	}},
+	{names: [3][]string{
+		{"GLACIER", "DEEP_ARCHIVE"},
+		{"ARCHIVE"},
+		{"Archive"},
+	}},
+}
+
+// Valid reports whether class is a class name of any provider, compared
+// without regard to case.
+func Valid(class string) bool {
+	_, _, ok := find(class)
+	return ok
+}
+
+// Convert returns the name under which provider stores objects of class,
+// which may be named after any provider. A class of provider itself is
+// returned in its canonical spelling; classes of other providers become the
+// equivalent class of provider. It returns an empty string, meaning the
+// bucket's default, when class is empty or unknown.
+func Convert(class string, provider Provider) string {
+	t, own, ok := find(class)
+	if !ok {
+		return ""
+	}
+	if own[provider] != "" {
+		return own[provider]
+	}
+	return t.names[provider][0]
+}
+
+// find returns the tier of class and, for each provider, the name class has
+// among the provider's classes of that tier, if any.
+func find(class string) (*tier, [3]string, bool) {
+	var own [3]string
+	if class == "" {
+		return nil, own, false
+	}
+
+	var found *tier
+	for i := range tiers {
+		for p, names := range tiers[i].names {
+			for _, name := range names {
+				if strings.EqualFold(name, class) {
+					if found == nil {
+						found = &tiers[i]
+					}
+					if found == &tiers[i] && own[p] == "" {
+						own[p] = name
+					}
+				}
+			}
+		}
+	}
+	return found, own, found != nil
+}
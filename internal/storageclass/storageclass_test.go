@@ -0,0 +1,40 @@
+package storageclass
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		class    string
+		provider Provider
+		want     string
+	}{
+		{"GLACIER_IR", GCS, "COLDLINE"},
+		{"GLACIER_IR", Azure, "Cool"},
+		{"COLDLINE", S3, "GLACIER_IR"},
+		{"coldline", GCS, "COLDLINE"},
+		{"Archive", S3, "GLACIER"},
+		{"DEEP_ARCHIVE", S3, "DEEP_ARCHIVE"},
+		{"DEEP_ARCHIVE", Azure, "Archive"},
+		{"Cool", S3, "STANDARD_IA"},
+		{"cool", Azure, "Cool"},
+		{"NEARLINE", Azure, "Cool"},
+		{"STANDARD", Azure, "Hot"},
+		{"REGIONAL", S3, "STANDARD"},
+		{"ONEZONE_IA", GCS, "NEARLINE"},
+		{"", S3, ""},
+		{"OUTPOSTS", GCS, ""},
+	}
+	for _, tt := range tests {
+		if got := Convert(tt.class, tt.provider); got != tt.want {
+			t.Errorf("Convert(%q, %d) = %q, want %q", tt.class, tt.provider, got, tt.want)
+		}
+	}
+}
+
+func TestValid(t *testing.T) {
+	for class, want := range map[string]bool{"GLACIER_IR": true, "archive": true, "Hot": true, "": false, "FROZEN": false, Preserve: false} {
+		if got := Valid(class); got != want {
+			t.Errorf("Valid(%q) = %t, want %t", class, got, want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// removal is a target object that no longer has a counterpart in the source.
+type removal struct {
+	targetKey string
+	sourceKey string // Empty when the target object cannot be traced back to a source key
+	info      *interfaces.ObjectInfo
+}
+
+// objectsToDelete returns, sorted by target key, the target objects that no
+// longer exist in the source, and the number of target objects the mapping
+// manages, which deletion thresholds are computed against. Only objects the
+// mapping wrote itself, as recorded in the database, are managed, unless the
+// mapping adopts existing objects. Objects already in the mapping's trash and
+// objects outside the mapping's filters are ignored.
+func (s *Synchronizer) objectsToDelete(run *targetRun, logger *slog.Logger) ([]removal, int) {
+	var removals []removal
+	managed := 0
+	for targetKey, info := range run.targetObjects {
+		if isTrashed(run.mapping, targetKey) {
+			continue
+		}
+		if _, expected := run.sourceKeys[targetKey]; expected || run.collisions[targetKey] != nil {
+			managed++
+			continue
+		}
+
+		var sourceKey string
+		stored, err := s.db.GetFileMetadataByTargetKey(run.mappingID, targetKey)
+		if errors.Is(err, database.ErrAmbiguousTargetKey) {
+			logger.Warn("Target object cannot be traced back to a single source object, keeping it", "object_name", targetKey, "error", err)
+			managed++
+			continue
+		}
+		if err != nil {
+			logger.Warn("Error looking up target object in DB, keeping it", "object_name", targetKey, "error", err)
+			continue
+		}
+		switch {
+		case stored != nil && stored.Owned:
+			sourceKey = stored.ObjectName
+		case run.mapping.AdoptExisting:
+			// Templated keys, and keys changed by transforms, cannot be
+			// traced back without the database.
+			if len(run.transformers) == 0 {
+				sourceKey, _ = run.keys.SourceKey(targetKey)
+			}
+		default:
+			logger.Debug("Target object was not written by this mapping, keeping it", "object_name", targetKey)
+			continue
+		}
+
+		// Objects written outside this tool under a templated layout are
+		// filtered by their key relative to the target prefix.
+		filterKey := sourceKey
+		if filterKey == "" {
+			filterKey = strings.TrimPrefix(targetKey, run.mapping.TargetPrefix)
+		}
+		if !run.matcher.Match(filterKey) {
+			continue
+		}
+
+		managed++
+		removals = append(removals, removal{targetKey: targetKey, sourceKey: sourceKey, info: info})
+	}
+
+	sort.Slice(removals, func(i, j int) bool { return removals[i].targetKey < removals[j].targetKey })
+	return removals, managed
+}
+
+// deletePolicy returns the effective delete policy of a mapping.
+func deletePolicy(mapping config.BucketMapping) config.DeletePolicy {
+	if mapping.DeletePolicy == "" {
+		return config.DeletePolicyMirror
+	}
+	return mapping.DeletePolicy
+}
+
+// trashLocation returns the bucket and key a trashed target object is moved to.
+func trashLocation(mapping config.BucketMapping, objName string) (string, string) {
+	bucket := mapping.TrashBucket
+	if bucket == "" {
+		bucket = mapping.TargetBucket
+	}
+	prefix := mapping.TrashPrefix
+	if prefix == "" {
+		prefix = config.DefaultTrashPrefix
+	}
+	return bucket, prefix + objName
+}
+
+// isTrashed reports whether a target key lives in the mapping's trash, which
+// only overlaps the target listing when trash and target share a bucket.
+func isTrashed(mapping config.BucketMapping, objName string) bool {
+	if deletePolicy(mapping) != config.DeletePolicyTrash {
+		return false
+	}
+	bucket, prefix := trashLocation(mapping, "")
+	return bucket == mapping.TargetBucket && strings.HasPrefix(objName, prefix)
+}
+
+// removeDeletedObjects applies the mapping's delete policy to target objects
+// that no longer exist in the source, adding the objects removed and the
+// failures to counters.
+func (s *Synchronizer) removeDeletedObjects(ctx context.Context, run *targetRun, counters *syncCounters, logger *slog.Logger) error {
+	policy := deletePolicy(run.mapping)
+	logger = logger.With("delete_policy", policy)
+	logger.Info("Checking for objects to remove from target")
+
+	candidates, managed := s.objectsToDelete(run, logger)
+
+	if policy == config.DeletePolicyNone {
+		for _, r := range candidates {
+			if r.sourceKey == "" {
+				continue
+			}
+			if err := s.db.SetSyncStatus(run.mappingID, r.sourceKey, statusRetained, time.Now().UTC()); err != nil {
+				logger.Error("Error recording retained object in DB", "object_name", r.targetKey, "error", err)
+			}
+		}
+		logger.Info("Object removal phase skipped by delete policy", "retained", len(candidates))
+		return nil
+	}
+
+	if err := checkDeleteThreshold(run.mapping, len(candidates), managed, len(run.targetKeys)); err != nil {
+		if !s.allowMassDeletes {
+			logger.Error("Object removal phase aborted, confirm with --allow-mass-delete to proceed",
+				"candidates", len(candidates), "target_objects", managed, "error", err)
+			return fmt.Errorf("mapping %s: %w", run.mappingID, err)
+		}
+		logger.Warn("Deletion threshold exceeded but mass deletes were explicitly allowed",
+			"candidates", len(candidates), "target_objects", managed, "error", err)
+	}
+
+	deleteCounter := 0
+	errorCounter := 0
+
+	for _, r := range candidates {
+		objLogger := logger.With("object_name", r.targetKey) // Logger with object context
+
+		if policy == config.DeletePolicyTrash {
+			objLogger.Info("Moving object to trash on target (deleted from source)")
+			if err := s.trashObject(ctx, run, r); err != nil {
+				objLogger.Error("Error moving object to trash", "error", err)
+				errorCounter++
+				continue
+			}
+
+			if r.sourceKey != "" {
+				if err := s.db.SetSyncStatus(run.mappingID, r.sourceKey, statusTrashed, time.Now().UTC()); err != nil {
+					objLogger.Error("Error recording trashed object in DB", "error", err)
+				}
+			}
+
+			objLogger.Info("Object moved to trash successfully")
+			deleteCounter++
+			continue
+		}
+
+		objLogger.Info("Removing object from target (deleted from source)")
+
+		// An object that is already gone counts as removed.
+		if err := run.target.DeleteObject(ctx, run.mapping.TargetBucket, r.targetKey); err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+			objLogger.Error("Error removing object from target", "error", err)
+			errorCounter++
+			continue // Skip DB deletion if target deletion failed
+		}
+
+		if r.sourceKey != "" {
+			objLogger.Debug("Removing object metadata from DB")
+			if err := s.db.DeleteFileMetadata(run.mappingID, r.sourceKey); err != nil {
+				objLogger.Error("Error removing metadata from DB", "error", err)
+				// Log error but continue, object was deleted from target
+			}
+		}
+
+		objLogger.Info("Object removed successfully from target")
+		deleteCounter++
+	}
+	logger.Info("Object removal phase complete", "removed", deleteCounter, "errors", errorCounter)
+	counters.deleted.Add(int64(deleteCounter))
+	counters.errors.Add(int64(errorCounter))
+	return nil
+}
+
+// checkDeleteThreshold returns ErrDeleteThresholdExceeded when removing count
+// of the targetTotal target objects the mapping manages would break the mapping's safety limits.
+// Unless maxDeletePercent is 100, a run whose source selected no objects
+// (sourceTotal) may not remove anything, since that usually means the source
+// listed empty by mistake.
+func checkDeleteThreshold(mapping config.BucketMapping, count, targetTotal, sourceTotal int) error {
+	if mapping.MaxDeletePercent < 100 && sourceTotal == 0 && count > 0 {
+		return fmt.Errorf("%w: source is empty and %d target objects would be removed, set maxDeletePercent to 100 to allow it", ErrDeleteThresholdExceeded, count)
+	}
+	if mapping.MaxDeletes > 0 && count > mapping.MaxDeletes {
+		return fmt.Errorf("%w: %d objects to remove, limit is %d", ErrDeleteThresholdExceeded, count, mapping.MaxDeletes)
+	}
+	if mapping.MaxDeletePercent > 0 && targetTotal > 0 {
+		percent := float64(count) * 100 / float64(targetTotal)
+		if percent > mapping.MaxDeletePercent {
+			return fmt.Errorf("%w: %.1f%% of target objects to remove, limit is %g%%", ErrDeleteThresholdExceeded, percent, mapping.MaxDeletePercent)
+		}
+	}
+	return nil
+}
+
+// trashObject copies a target object to the mapping's trash location and then
+// deletes the original.
+func (s *Synchronizer) trashObject(ctx context.Context, run *targetRun, r removal) error {
+	mapping := run.mapping
+	trashBucket, trashKey := trashLocation(mapping, r.targetKey)
+
+	info, reader, err := run.target.GetObject(ctx, mapping.TargetBucket, r.targetKey)
+	if err != nil {
+		return fmt.Errorf("error reading object %s from target: %w", r.targetKey, err)
+	}
+	defer reader.Close()
+
+	if trashBucket != mapping.TargetBucket {
+		if err := run.target.EnsureBucketExists(ctx, trashBucket); err != nil {
+			return fmt.Errorf("error ensuring trash bucket %s exists: %w", trashBucket, err)
+		}
+	}
+
+	opts := interfaces.UploadOptions{ContentType: r.info.ContentType}
+	if info != nil {
+		opts = info.UploadOptions()
+	}
+	opts.Encryption = run.encryption
+	if _, err := run.target.UploadObject(ctx, trashBucket, trashKey, reader, r.info.Size, opts); err != nil {
+		return fmt.Errorf("error copying object %s to trash %s/%s: %w", r.targetKey, trashBucket, trashKey, err)
+	}
+
+	if err := run.target.DeleteObject(ctx, mapping.TargetBucket, r.targetKey); err != nil {
+		return fmt.Errorf("error removing trashed object %s: %w", r.targetKey, err)
+	}
+	return nil
+}
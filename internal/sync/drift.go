@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"strings"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// driftPolicy returns the drift policy of a mapping, defaulting to report.
+func driftPolicy(mapping config.BucketMapping) config.DriftPolicy {
+	if mapping.DriftPolicy == "" {
+		return config.DriftPolicyReport
+	}
+	return mapping.DriftPolicy
+}
+
+// objectDecision is syncDecision extended with a check of the target copy of
+// objects that are otherwise up to date. A copy that was changed or removed
+// outside of the sync is only uploaded again under the repair policy; under
+// report the drift reason is returned with needsSync false. An object whose
+// older versions were just written over the copy, or whose copy has another
+// encryption mode than the target requires, is always uploaded again.
+func objectDecision(stored *database.FileMetadata, src *interfaces.ObjectInfo, tags map[string]string, target *targetRun, targetKey string, now time.Time) (bool, string) {
+	needsSync, reason := syncDecision(stored, src, tags, targetKey, now)
+	if reason != ReasonUnchanged {
+		return needsSync, reason
+	}
+	if target.rewrite[stored.ObjectName] {
+		return true, ReasonVersionsReplicated
+	}
+	if encryptionChanged(stored, target) {
+		return true, ReasonEncryptionChanged
+	}
+
+	policy := driftPolicy(target.mapping)
+	if policy == config.DriftPolicyIgnore {
+		return needsSync, reason
+	}
+	if drift := targetDrift(stored, target, targetKey); drift != "" {
+		return policy == config.DriftPolicyRepair, drift
+	}
+	return needsSync, reason
+}
+
+// targetDrift compares the target listing with what the target reported when
+// the object was last uploaded to targetKey. It returns ReasonTargetMissing or
+// ReasonTargetChanged, or "" when the copy is intact or cannot be checked.
+func targetDrift(stored *database.FileMetadata, target *targetRun, targetKey string) string {
+	if !target.listed {
+		return "" // Nothing to compare with
+	}
+
+	obj, ok := target.targetObjects[targetKey]
+	switch {
+	case !ok:
+		return ReasonTargetMissing
+	case stored.TargetETag == "":
+		return "" // Uploaded before target ETags were recorded
+	case trimETag(obj.ETag) != trimETag(stored.TargetETag), obj.Size != stored.TargetSize:
+		return ReasonTargetChanged
+	default:
+		return ""
+	}
+}
+
+// trimETag strips the quotes some providers wrap ETags in.
+func trimETag(etag string) string {
+	return strings.Trim(etag, `"`)
+}
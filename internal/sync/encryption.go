@@ -0,0 +1,29 @@
+package sync
+
+import (
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// encryptionMode returns the encryption mode the copies of a single-target
+// mapping must have: the mapping's, or else the target provider's. It is
+// empty when neither sets one and the bucket default applies.
+func (s *Synchronizer) encryptionMode(mapping config.BucketMapping) interfaces.EncryptionMode {
+	if mapping.Encryption != nil {
+		return mapping.Encryption.Mode
+	}
+	for _, provider := range s.config.Providers {
+		if provider.ID == mapping.TargetProviderID && provider.Encryption != nil {
+			return provider.Encryption.Mode
+		}
+	}
+	return interfaces.EncryptionDefault
+}
+
+// encryptionChanged reports whether the copy of an object was written with an
+// encryption mode other than the one the target requires, e.g. because it
+// was uploaded before the mapping or provider set one.
+func encryptionChanged(stored *database.FileMetadata, target *targetRun) bool {
+	return target.mode != interfaces.EncryptionDefault && stored.Encryption != string(target.mode)
+}
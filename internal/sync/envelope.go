@@ -0,0 +1,72 @@
+package sync
+
+import (
+	"errors"
+	"io"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/envelope"
+)
+
+// keyring returns the client-side encryption keyring of a mapping. It is
+// created on the mapping's first run and kept for later ones, so a
+// passphrase is derived into a key once per process rather than per run.
+func (s *Synchronizer) keyring(mapping config.BucketMapping, mappingID string) (*envelope.Keyring, error) {
+	s.keyringsMu.Lock()
+	defer s.keyringsMu.Unlock()
+	if k, ok := s.keyrings[mappingID]; ok {
+		return k, nil
+	}
+	k, err := mapping.ClientEncryption.Keyring()
+	if err != nil {
+		return nil, err
+	}
+	if s.keyrings == nil {
+		s.keyrings = make(map[string]*envelope.Keyring)
+	}
+	s.keyrings[mappingID] = k
+	return k, nil
+}
+
+// clientEncryption applies the mapping's client-side encryption to the
+// content of an object read from the source. It returns the content to
+// upload, its size and the user metadata to upload it with. Objects already
+// in the wanted form, encrypted ones when encrypting and plain ones when
+// decrypting, pass through unchanged.
+func clientEncryption(run *mappingRun, objName string, r io.Reader, size int64, metadata map[string]string) (io.Reader, int64, map[string]string, error) {
+	if run.keyring == nil {
+		return r, size, metadata, nil
+	}
+
+	switch run.mapping.ClientEncryption.Mode {
+	case config.ClientEncryptionEncrypt:
+		if envelope.Encrypted(metadata) {
+			run.logger.Debug("Object is already client-side encrypted, copying it as is", "object_name", objName)
+			return r, size, metadata, nil
+		}
+		encrypted, encryptedSize, envelopeMetadata, err := run.keyring.Encrypt(r, size)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		merged := make(map[string]string, len(metadata)+len(envelopeMetadata))
+		for k, v := range metadata {
+			merged[k] = v
+		}
+		for k, v := range envelopeMetadata {
+			merged[k] = v
+		}
+		return encrypted, encryptedSize, merged, nil
+
+	case config.ClientEncryptionDecrypt:
+		decrypted, plainSize, err := run.keyring.Decrypt(r, size, metadata)
+		if errors.Is(err, envelope.ErrNotEncrypted) {
+			run.logger.Debug("Object is not client-side encrypted, copying it as is", "object_name", objName)
+			return r, size, metadata, nil
+		}
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return decrypted, plainSize, envelope.StripMetadata(metadata), nil
+	}
+	return r, size, metadata, nil
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"errors"
+	"io"
+	gosync "sync"
+)
+
+// errUploadReturned unblocks the fan-out when an upload stops reading early.
+var errUploadReturned = errors.New("upload returned before reading the whole stream")
+
+// fanOut streams src to every upload concurrently, reading src only once, and
+// returns the error of each upload by index. Uploads proceed at the pace of
+// the slowest one; an upload that fails is dropped without affecting the
+// others.
+func fanOut(src io.Reader, uploads []func(io.Reader) error) []error {
+	errs := make([]error, len(uploads))
+	if len(uploads) == 1 {
+		errs[0] = uploads[0](src)
+		return errs
+	}
+
+	writers := make([]*io.PipeWriter, len(uploads))
+	var wg gosync.WaitGroup
+	for i, upload := range uploads {
+		pr, pw := io.Pipe()
+		writers[i] = pw
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = upload(pr)
+			pr.CloseWithError(errUploadReturned)
+		}()
+	}
+
+	readErr := copyToAll(src, writers)
+	for _, pw := range writers {
+		pw.CloseWithError(readErr) // A nil error closes the pipe with io.EOF
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err == nil && readErr != nil {
+			errs[i] = readErr
+		}
+	}
+	return errs
+}
+
+// copyToAll copies src to every writer until EOF, dropping writers that fail.
+// It returns the read error of src, if any.
+func copyToAll(src io.Reader, writers []*io.PipeWriter) error {
+	active := make([]bool, len(writers))
+	remaining := len(writers)
+	for i := range active {
+		active[i] = true
+	}
+
+	buf := make([]byte, 32*1024)
+	for remaining > 0 {
+		n, err := src.Read(buf)
+		if n > 0 {
+			for i, pw := range writers {
+				if !active[i] {
+					continue
+				}
+				if _, werr := pw.Write(buf[:n]); werr != nil {
+					active[i] = false
+					remaining--
+				}
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/hooks"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// beforeRun runs the beforeRun hooks of a run. A failing hook vetoes the run.
+func beforeRun(ctx context.Context, runner *hooks.Runner, record *database.SyncRun) error {
+	if err := runner.Run(ctx, runPayload(hooks.BeforeRun, record)); err != nil {
+		return fmt.Errorf("%w: %w", ErrRunVetoed, err)
+	}
+	return nil
+}
+
+// afterRun runs the afterRun hooks of a finished run, unless it was vetoed,
+// and its onFailure hooks if it was vetoed, failed or failed to copy some
+// objects. They run even when ctx is canceled, within their own timeouts,
+// and their failures are only logged since the run is over.
+func afterRun(ctx context.Context, runner *hooks.Runner, record *database.SyncRun, vetoed bool, logger *slog.Logger) {
+	ctx = context.WithoutCancel(ctx)
+	if !vetoed {
+		if err := runner.Run(ctx, runPayload(hooks.AfterRun, record)); err != nil {
+			logger.Warn("afterRun hook failed", "error", err)
+		}
+	}
+	if vetoed || record.Status != database.RunStatusSuccess {
+		if err := runner.Run(ctx, runPayload(hooks.OnFailure, record)); err != nil {
+			logger.Warn("onFailure hook failed", "error", err)
+		}
+	}
+}
+
+// runPayload describes a run to the hooks of event.
+func runPayload(event hooks.Event, record *database.SyncRun) hooks.Payload {
+	run := &hooks.Run{
+		ID:               record.ID,
+		Status:           record.Status,
+		StartedAt:        record.StartedAt,
+		Synced:           record.Synced,
+		Skipped:          record.Skipped,
+		Failed:           record.Failed,
+		Deleted:          record.Deleted,
+		BytesTransferred: record.BytesTransferred,
+	}
+	if !record.FinishedAt.IsZero() {
+		finishedAt := record.FinishedAt
+		run.FinishedAt = &finishedAt
+	}
+	return hooks.Payload{Event: event, Mapping: record.MappingID, Run: run, Error: record.Error}
+}
+
+// afterUpload runs the afterUpload hooks for an object copied to a target. A
+// failing hook is only logged; the object stays synchronized.
+func (s *Synchronizer) afterUpload(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo, result transferResult) {
+	if !run.hooks.Has(hooks.AfterUpload) {
+		return
+	}
+	p := result.pending
+	object := &hooks.Object{
+		Key:            objName,
+		TargetProvider: p.target.mapping.TargetProviderID,
+		TargetBucket:   p.target.mapping.TargetBucket,
+		TargetKey:      p.targetKey,
+		Size:           srcObjInfo.Size,
+		Checksum:       result.checksum,
+	}
+	if result.uploaded != nil {
+		if result.uploaded.Size > 0 {
+			object.Size = result.uploaded.Size
+		}
+		object.ETag = result.uploaded.ETag
+	}
+	if err := run.hooks.Run(ctx, hooks.Payload{Event: hooks.AfterUpload, Mapping: run.mappingID, Object: object}); err != nil {
+		p.logger.Warn("afterUpload hook failed", "target_key", p.targetKey, "error", err)
+	}
+}
@@ -0,0 +1,40 @@
+package sync
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// metadataHash returns a hash of the headers and user metadata an object is
+// uploaded with, so that a change to them alone is noticed. It does not
+// depend on the order of the metadata keys.
+func metadataHash(info *interfaces.ObjectInfo) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "content-type:%s\ncache-control:%s\ncontent-encoding:%s\ncontent-disposition:%s\n",
+		info.ContentType, info.CacheControl, info.ContentEncoding, info.ContentDisposition)
+
+	keys := make([]string, 0, len(info.Metadata))
+	for k := range info.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "meta:%q=%q\n", k, info.Metadata[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// storedMetadataHash returns the metadata hash of the last sync of an object.
+// Objects synced before metadata was tracked were uploaded with their content
+// type alone, which is what their hash is computed from.
+func storedMetadataHash(stored *database.FileMetadata) string {
+	if stored.MetadataHash != "" {
+		return stored.MetadataHash
+	}
+	return metadataHash(&interfaces.ObjectInfo{ContentType: stored.ContentType})
+}
@@ -0,0 +1,318 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// maxParts is the largest number of parts an upload may have (the S3 limit).
+const maxParts = 10000
+
+// multipartOptions decides which objects are uploaded in parts.
+type multipartOptions struct {
+	threshold int64
+	partSize  int64
+}
+
+func newMultipartOptions(cfg *config.MultipartConfig) multipartOptions {
+	opts := multipartOptions{threshold: config.DefaultMultipartThreshold, partSize: config.DefaultPartSize}
+	if cfg == nil {
+		return opts
+	}
+
+	if cfg.Threshold > 0 {
+		opts.threshold = cfg.Threshold
+	}
+	if cfg.PartSize > 0 {
+		opts.partSize = cfg.PartSize
+	}
+	return opts
+}
+
+// partSizeFor returns the part size for an object, raised when needed so the
+// object fits in maxParts parts.
+func (o multipartOptions) partSizeFor(size int64) int64 {
+	partSize := o.partSize
+	if least := (size + maxParts - 1) / maxParts; partSize < least {
+		partSize = (least + config.PartSizeAlignment - 1) / config.PartSizeAlignment * config.PartSizeAlignment
+	}
+	return partSize
+}
+
+// transferAll copies an object to the pending targets. Objects above the
+// multipart threshold go in resumable parts to the targets that support it,
+// when the source can read ranges; everything else, including older
+// versions of an object and objects the mapping transforms or encrypts on
+// the client, is streamed in one piece.
+func (s *Synchronizer) transferAll(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo, tags map[string]string, pending []pendingUpload) []transferResult {
+	var streamed, parted []pendingUpload
+	_, ranged := run.source.(interfaces.RangeReader)
+	ranged = ranged && srcObjInfo.VersionID == "" && run.keyring == nil && len(run.transformers) == 0
+	for _, p := range pending {
+		if _, ok := p.target.target.(interfaces.MultipartUploader); ok && ranged && srcObjInfo.Size >= s.multipart.threshold {
+			parted = append(parted, p)
+		} else {
+			streamed = append(streamed, p)
+		}
+	}
+
+	var results []transferResult
+	if len(streamed) > 0 {
+		results = append(results, s.transfer(ctx, run, objName, srcObjInfo, tags, streamed)...)
+	}
+	if len(parted) > 0 {
+		results = append(results, s.transferParts(ctx, run, objName, srcObjInfo, tags, parted)...)
+	}
+	return results
+}
+
+// partTarget is a target receiving an object in parts.
+type partTarget struct {
+	pending    pendingUpload
+	objectName string
+	result     *transferResult
+	uploader   interfaces.MultipartUploader
+	upload     *interfaces.MultipartUpload
+	parts      []interfaces.CompletedPart
+	resumed    bool // Some parts were uploaded by a previous run
+	verified   bool // Every part uploaded by this run matched the checksum the target reported
+}
+
+func (t *partTarget) done(number int) bool {
+	return slices.ContainsFunc(t.parts, func(part interfaces.CompletedPart) bool { return part.Number == number })
+}
+
+func (t *partTarget) failed() bool {
+	return t.result.status != ""
+}
+
+// transferParts uploads an object in parts to every pending target, resuming
+// the uploads a previous run left unfinished. Each part is read from the
+// source once, with a ranged read, and streamed to every target missing it;
+// completed parts are recorded in the database as they finish.
+func (s *Synchronizer) transferParts(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo, tags map[string]string, pending []pendingUpload) []transferResult {
+	results := make([]transferResult, len(pending))
+	for i, p := range pending {
+		results[i].pending = p
+	}
+
+	// Listings do not report every header, and the parts are read without
+	// them, so they are taken from the object's metadata.
+	source := run.source.(interfaces.RangeReader)
+	opened, err := source.StatObject(ctx, run.mapping.SourceBucket, objName)
+	if err != nil {
+		for i := range results {
+			results[i].status, results[i].err = statusFailedGet, err
+		}
+		return results
+	}
+
+	partSize := s.multipart.partSizeFor(srcObjInfo.Size)
+	opts := uploadOptions(srcObjInfo, opened)
+	opts.Tags = tags
+	opts.StorageClass = objectStorageClass(run, objName, srcObjInfo)
+
+	var targets []*partTarget
+	for i, p := range pending {
+		target, err := s.startUpload(ctx, p, objName, srcObjInfo, opts, partSize)
+		if err != nil {
+			results[i].status, results[i].err = statusFailedUpload, err
+			continue
+		}
+		target.result = &results[i]
+		targets = append(targets, target)
+	}
+	if len(targets) == 0 {
+		return results
+	}
+
+	run.logger.Info("Synchronizing object in parts", "object_name", objName, "targets", len(targets),
+		"size", srcObjInfo.Size, "part_size", partSize, "parts", targets[0].upload.Parts())
+
+	// The whole content is hashed only when every part is read by this run.
+	whole := newChecksumReader(nil)
+	wholeRead := true
+
+	for number := 1; number <= targets[0].upload.Parts(); number++ {
+		var active []*partTarget
+		for _, t := range targets {
+			if !t.failed() && !t.done(number) {
+				active = append(active, t)
+			}
+		}
+		if len(active) == 0 {
+			wholeRead = false
+			continue
+		}
+
+		offset := targets[0].upload.Offset(number)
+		length := min(partSize, srcObjInfo.Size-offset)
+		reader, err := source.GetObjectRange(ctx, run.mapping.SourceBucket, objName, offset, length)
+		if err != nil {
+			for _, t := range active {
+				t.result.status, t.result.err = statusFailedGet, err
+			}
+			wholeRead = false
+			continue
+		}
+
+		sum := newChecksumReader(io.TeeReader(reader, whole))
+		uploads := make([]func(io.Reader) error, len(active))
+		completed := make([]*interfaces.CompletedPart, len(active))
+		for i, t := range active {
+			uploads[i] = func(r io.Reader) error {
+				r = s.throttle(ctx, run, r)
+				t.pending.logger.Debug("Uploading part", "part", number, "offset", offset, "size", length)
+				part, err := t.uploader.UploadPart(ctx, t.upload, number, r, length)
+				completed[i] = part
+				return err
+			}
+		}
+		errs := fanOut(sum, uploads)
+		reader.Close()
+		if sum.n != length {
+			wholeRead = false
+		}
+
+		for i, t := range active {
+			if errs[i] != nil {
+				t.result.status, t.result.err = statusFailedUpload, errs[i]
+				s.forgetExpiredUpload(t, errs[i])
+				continue
+			}
+			verified, err := sum.verify(&interfaces.UploadInfo{ContentMD5: completed[i].ContentMD5}, length)
+			if err != nil {
+				t.result.status, t.result.err = statusFailedVerify, fmt.Errorf("part %d: %w", number, err)
+				continue
+			}
+			t.verified = t.verified && verified
+
+			part := *completed[i]
+			part.Number, part.Size = number, length
+			t.parts = append(t.parts, part)
+			if err := s.db.AddMultipartPart(t.pending.target.mappingID, objName, database.MultipartPart{
+				Number: part.Number, ETag: part.ETag, Size: part.Size, ContentMD5: part.ContentMD5,
+			}); err != nil {
+				t.pending.logger.Warn("Error recording uploaded part, it will be uploaded again if the upload is resumed", "part", number, "error", err)
+			}
+		}
+	}
+
+	for _, t := range targets {
+		if t.failed() {
+			continue
+		}
+
+		slices.SortFunc(t.parts, func(a, b interfaces.CompletedPart) int { return a.Number - b.Number })
+		info, err := t.uploader.CompleteMultipartUpload(ctx, t.upload, t.parts)
+		if err != nil {
+			t.result.status, t.result.err = statusFailedUpload, err
+			s.forgetExpiredUpload(t, err)
+			continue
+		}
+		if err := s.db.DeleteMultipartUpload(t.pending.target.mappingID, objName); err != nil {
+			t.pending.logger.Warn("Error removing completed multipart upload from DB", "error", err)
+		}
+		t.result.uploaded = info
+
+		t.result.verified = t.verified && !t.resumed
+		if wholeRead {
+			t.result.checksum = whole.Checksum()
+			verified, err := whole.verify(info, srcObjInfo.Size)
+			if err != nil {
+				t.result.status, t.result.err = statusFailedVerify, err
+				continue
+			}
+			t.result.verified = t.result.verified || verified
+		}
+		t.result.status = statusSuccess
+	}
+	return results
+}
+
+// startUpload resumes the upload of an object recorded by a previous run, or
+// starts a new one when there is none or it no longer matches the object.
+// The upload writes the object with opts.
+func (s *Synchronizer) startUpload(ctx context.Context, p pendingUpload, objName string, srcObjInfo *interfaces.ObjectInfo, opts interfaces.UploadOptions, partSize int64) (*partTarget, error) {
+	uploader := p.target.target.(interfaces.MultipartUploader)
+	target := &partTarget{pending: p, objectName: objName, uploader: uploader, verified: true}
+	opts.Encryption = p.target.encryption
+
+	stored, err := s.db.GetMultipartUpload(p.target.mappingID, objName)
+	if err != nil {
+		p.logger.Warn("Error fetching multipart upload from DB, starting a new upload", "error", err)
+	}
+	if stored != nil {
+		target.upload = &interfaces.MultipartUpload{
+			Bucket:   p.target.mapping.TargetBucket,
+			Key:      stored.TargetKey,
+			UploadID: stored.UploadID,
+			Size:     stored.Size,
+			PartSize: stored.PartSize,
+			Options:  opts,
+		}
+		if stored.TargetKey == p.targetKey && stored.SourceETag == srcObjInfo.ETag && stored.Size == srcObjInfo.Size && stored.PartSize == partSize {
+			for _, part := range stored.Parts {
+				target.parts = append(target.parts, interfaces.CompletedPart{
+					Number: part.Number, ETag: part.ETag, Size: part.Size, ContentMD5: part.ContentMD5,
+				})
+			}
+			target.resumed = len(target.parts) > 0
+			p.logger.Info("Resuming multipart upload", "completed_parts", len(target.parts), "parts", target.upload.Parts(),
+				"started_at", stored.CreatedAt)
+			return target, nil
+		}
+
+		p.logger.Info("Discarding multipart upload of a previous version of the object", "started_at", stored.CreatedAt)
+		if err := uploader.AbortMultipartUpload(ctx, target.upload); err != nil {
+			p.logger.Warn("Error aborting outdated multipart upload", "error", err)
+		}
+	}
+
+	target.upload = &interfaces.MultipartUpload{
+		Bucket:   p.target.mapping.TargetBucket,
+		Key:      p.targetKey,
+		Size:     srcObjInfo.Size,
+		PartSize: partSize,
+		Options:  opts,
+	}
+	if err := uploader.CreateMultipartUpload(ctx, target.upload); err != nil {
+		return nil, err
+	}
+
+	err = s.db.CreateMultipartUpload(&database.MultipartUpload{
+		MappingID:   p.target.mappingID,
+		ObjectName:  objName,
+		TargetKey:   p.targetKey,
+		UploadID:    target.upload.UploadID,
+		SourceETag:  srcObjInfo.ETag,
+		Size:        srcObjInfo.Size,
+		PartSize:    partSize,
+		ContentType: srcObjInfo.ContentType,
+		CreatedAt:   time.Now().UTC(),
+	})
+	if err != nil {
+		p.logger.Warn("Error recording multipart upload, it cannot be resumed after a restart", "error", err)
+	}
+	return target, nil
+}
+
+// forgetExpiredUpload drops the recorded upload of a target that no longer
+// knows it, so the next attempt starts a new one instead of failing again.
+func (s *Synchronizer) forgetExpiredUpload(t *partTarget, err error) {
+	if !errors.Is(err, interfaces.ErrNotFound) {
+		return
+	}
+	t.pending.logger.Warn("Multipart upload no longer exists on the target, it will be restarted", "error", err)
+	if err := s.db.DeleteMultipartUpload(t.pending.target.mappingID, t.objectName); err != nil {
+		t.pending.logger.Warn("Error removing multipart upload from DB", "error", err)
+	}
+}
@@ -0,0 +1,172 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+)
+
+// Reasons attached to sync and deletion decisions.
+const (
+	ReasonNew                = "new"
+	ReasonETagChanged        = "etag_changed"
+	ReasonModified           = "modified"
+	ReasonPreviousFailure    = "previous_failure"
+	ReasonTargetKeyChanged   = "target_key_changed"
+	ReasonUnchanged          = "unchanged"
+	ReasonDeletedInSource    = "deleted_in_source"
+	ReasonRetryScheduled     = "retry_scheduled"     // Previous sync failed and the backoff has not elapsed
+	ReasonQuarantined        = "quarantined"         // Failed too many times; skipped until requeued
+	ReasonTargetMissing      = "target_missing"      // The copy on the target was removed outside of the sync
+	ReasonTargetChanged      = "target_changed"      // The copy on the target was replaced outside of the sync
+	ReasonVersionsReplicated = "versions_replicated" // Older versions were written after the current one
+	ReasonMetadataChanged    = "metadata_changed"    // Headers or user metadata changed in the source
+	ReasonEncryptionChanged  = "encryption_changed"  // The copy on the target has another encryption mode than configured
+	ReasonTagsChanged        = "tags_changed"        // Source tags or the mapping's static tags changed
+)
+
+// PlanAction is the operation a synchronization run would perform on an object.
+type PlanAction string
+
+const (
+	ActionUpload PlanAction = "upload"
+	ActionSkip   PlanAction = "skip"
+	ActionDelete PlanAction = "delete"
+	ActionTrash  PlanAction = "trash"
+	ActionKeep   PlanAction = "keep"
+)
+
+// PlanItem describes what would happen to a single object.
+type PlanItem struct {
+	Action PlanAction `json:"action"`
+	Object string     `json:"object"` // Source key; empty for target objects that cannot be traced to one
+	Target string     `json:"target"` // Target key
+	Reason string     `json:"reason"`
+	Size   int64      `json:"size"`
+}
+
+// Plan is the set of changes a synchronization run would apply to a mapping.
+type Plan struct {
+	MappingID       string     `json:"mappingId"`
+	Items           []PlanItem `json:"items"`
+	Uploads         int        `json:"uploads"`
+	Skips           int        `json:"skips"`
+	Deletes         int        `json:"deletes"` // Objects deleted or moved to trash
+	BytesToTransfer int64      `json:"bytesToTransfer"`
+	Warnings        []string   `json:"warnings,omitempty"`
+}
+
+func (p *Plan) add(item PlanItem) {
+	p.Items = append(p.Items, item)
+	switch item.Action {
+	case ActionUpload:
+		p.Uploads++
+		p.BytesToTransfer += item.Size
+	case ActionSkip:
+		p.Skips++
+	case ActionDelete, ActionTrash:
+		p.Deletes++
+	}
+}
+
+// Plan computes the uploads, skips and deletions SyncBuckets would perform for
+// a mapping without uploading, deleting or recording anything. It returns one
+// plan per target of the mapping.
+func (s *Synchronizer) Plan(ctx context.Context, mapping config.BucketMapping) ([]*Plan, error) {
+	logger := s.logger.With("mapping_id", mappingKey(mapping), "dry_run", true)
+
+	run, err := s.prepareRun(ctx, mapping, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(run.sourceObjects))
+	for objName := range run.sourceObjects {
+		names = append(names, objName)
+	}
+	sort.Strings(names)
+
+	// Tags are read once for all targets; an object whose tags cannot be
+	// read would fail.
+	tags := make(map[string]map[string]string, len(names))
+	tagErrs := make(map[string]error)
+	for _, objName := range names {
+		objTags, err := s.objectTags(ctx, run, objName, run.sourceObjects[objName])
+		if err != nil {
+			tagErrs[objName] = err
+			continue
+		}
+		tags[objName] = objTags
+	}
+
+	plans := make([]*Plan, 0, len(run.targets))
+	for _, target := range run.targets {
+		plans = append(plans, s.planTarget(run, target, names, tags, tagErrs))
+	}
+	return plans, nil
+}
+
+// planTarget computes the plan of a single target for the sorted source keys,
+// given the tags of each object or the error reading them.
+func (s *Synchronizer) planTarget(run *mappingRun, target *targetRun, names []string, tags map[string]map[string]string, tagErrs map[string]error) *Plan {
+	logger := target.logger
+	plan := &Plan{MappingID: target.mappingID}
+
+	for _, objName := range names {
+		srcObjInfo := run.sourceObjects[objName]
+		targetKey, ok := target.targetKeys[objName]
+		if !ok {
+			continue
+		}
+		if err := tagErrs[objName]; err != nil {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("tags of object %s cannot be read and it will not be copied: %v", objName, err))
+			continue
+		}
+
+		storedMetadata, err := s.db.GetFileMetadata(target.mappingID, objName)
+		if err != nil {
+			logger.Warn("Error fetching metadata from DB, planning object as new", "object_name", objName, "error", err)
+		}
+
+		action := ActionSkip
+		needsSync, reason := objectDecision(storedMetadata, srcObjInfo, tags[objName], target, targetKey, time.Now())
+		if needsSync {
+			action = ActionUpload
+		}
+		plan.add(PlanItem{Action: action, Object: objName, Target: targetKey, Reason: reason, Size: srcObjInfo.Size})
+	}
+
+	collided := make([]string, 0, len(target.collisions))
+	for targetKey := range target.collisions {
+		collided = append(collided, targetKey)
+	}
+	sort.Strings(collided)
+	for _, targetKey := range collided {
+		plan.Warnings = append(plan.Warnings, fmt.Sprintf("objects %s map to the same target key %s and are not copied",
+			strings.Join(target.collisions[targetKey], ", "), targetKey))
+	}
+
+	removal := ActionDelete
+	switch deletePolicy(target.mapping) {
+	case config.DeletePolicyTrash:
+		removal = ActionTrash
+	case config.DeletePolicyNone:
+		removal = ActionKeep
+	}
+	candidates, managed := s.objectsToDelete(target, logger)
+	for _, r := range candidates {
+		plan.add(PlanItem{Action: removal, Object: r.sourceKey, Target: r.targetKey, Reason: ReasonDeletedInSource, Size: r.info.Size})
+	}
+
+	if removal != ActionKeep {
+		if err := checkDeleteThreshold(target.mapping, len(candidates), managed, len(target.targetKeys)); err != nil {
+			plan.Warnings = append(plan.Warnings, err.Error()+"; the deletion phase will be aborted unless --allow-mass-delete is set")
+		}
+	}
+
+	return plan
+}
@@ -0,0 +1,22 @@
+package sync
+
+import (
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
+)
+
+// objectStorageClass returns the storage class to write an object with, named
+// after any provider's; each target client converts it to its own. With
+// storageclass.Preserve it is the class the source reported for the object,
+// and empty, the bucket's default, when that class has no equivalent.
+func objectStorageClass(run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo) string {
+	if run.mapping.StorageClass != storageclass.Preserve {
+		return run.mapping.StorageClass
+	}
+	if srcObjInfo.StorageClass != "" && !storageclass.Valid(srcObjInfo.StorageClass) {
+		run.logger.Debug("Source storage class has no equivalent, using the target bucket's default",
+			"object_name", objName, "storage_class", srcObjInfo.StorageClass)
+		return ""
+	}
+	return srcObjInfo.StorageClass
+}
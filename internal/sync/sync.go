@@ -3,33 +3,180 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog" // Import slog
+	"sort"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/DjonatanS/cloud-data-sync/internal/config"
 	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/envelope"
+	"github.com/DjonatanS/cloud-data-sync/internal/filter"
+	"github.com/DjonatanS/cloud-data-sync/internal/hooks"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/keymap"
+	"github.com/DjonatanS/cloud-data-sync/internal/retry"
 	"github.com/DjonatanS/cloud-data-sync/internal/storage"
+	"github.com/DjonatanS/cloud-data-sync/internal/throttle"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
 )
 
+// ErrDeleteThresholdExceeded is returned when a run would delete more target
+// objects than the mapping's maxDeletes/maxDeletePercent allow.
+var ErrDeleteThresholdExceeded = errors.New("deletion threshold exceeded")
+
+// ErrRunVetoed is returned when a beforeRun hook fails, so that the mapping
+// is not synchronized.
+var ErrRunVetoed = errors.New("run vetoed by beforeRun hook")
+
 type Synchronizer struct {
-	db              *database.DB
-	config          *config.Config
-	providerFactory *storage.Factory
-	logger          *slog.Logger
+	db               *database.DB
+	config           *config.Config
+	providerFactory  *storage.Factory
+	logger           *slog.Logger
+	slots            *semaphore.Weighted // Global transfer cap shared by all mappings; nil when unlimited
+	failures         retry.Policy        // Backoff between runs for failed objects; MaxAttempts is the quarantine limit
+	multipart        multipartOptions    // Which objects are uploaded in resumable parts
+	bandwidth        *throttle.Limiter   // Global upload rate shared by all mappings; nil when unlimited
+	allowMassDeletes bool
+
+	transforms map[string]TransformFactory // Transform types registered with WithTransform
+
+	keyringsMu gosync.Mutex
+	keyrings   map[string]*envelope.Keyring // Client-side encryption keyrings by mappingID, kept across runs
 }
 
-func NewSynchronizer(db *database.DB, cfg *config.Config, factory *storage.Factory, logger *slog.Logger) *Synchronizer { // Accept logger
-	return &Synchronizer{
+// Option customizes a Synchronizer.
+type Option func(*Synchronizer)
+
+// WithAllowMassDeletes lets the deletion phase proceed even when a mapping's
+// deletion threshold is exceeded. It is meant for operator-confirmed runs.
+func WithAllowMassDeletes(allow bool) Option {
+	return func(s *Synchronizer) {
+		s.allowMassDeletes = allow
+	}
+}
+
+func NewSynchronizer(db *database.DB, cfg *config.Config, factory *storage.Factory, logger *slog.Logger, opts ...Option) *Synchronizer { // Accept logger
+	s := &Synchronizer{
 		db:              db,
 		config:          cfg,
 		providerFactory: factory,
 		logger:          logger,
+		failures:        failurePolicy(cfg.Failures),
+		multipart:       newMultipartOptions(cfg.Multipart),
+	}
+	if cfg.MaxConcurrency > 0 {
+		s.slots = semaphore.NewWeighted(int64(cfg.MaxConcurrency))
+	}
+	bandwidth, err := throttle.New(cfg.MaxBytesPerSecond, cfg.BandwidthSchedule)
+	if err != nil {
+		logger.Error("Invalid global bandwidth settings, uploads are not throttled globally", "error", err)
+	}
+	s.bandwidth = bandwidth
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Sync statuses stored in file_metadata.
+const (
+	statusSuccess      = "success"
+	statusFailedGet    = "failed_get"
+	statusFailedUpload = "failed_upload"
+	statusFailedVerify = "failed_verify" // Uploaded but the target reported a different checksum
+	statusTrashed      = "trashed"       // Removed from the source and moved to the trash location
+	statusRetained     = "retained"      // Removed from the source but kept on the target (delete policy none)
+	statusQuarantined  = database.StatusQuarantined
+)
+
+// failurePolicy builds the policy that spaces out and limits the retries of
+// failed objects across runs, filling unset fields with the config defaults.
+func failurePolicy(cfg *config.FailureConfig) retry.Policy {
+	policy := retry.Policy{
+		MaxAttempts: config.DefaultMaxObjectAttempts,
+		BaseDelay:   config.DefaultFailureBaseDelay,
+		MaxDelay:    config.DefaultFailureMaxDelay,
+	}
+	if cfg == nil {
+		return policy
+	}
+
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.BaseDelay > 0 {
+		policy.BaseDelay = time.Duration(cfg.BaseDelay)
+	}
+	if cfg.MaxDelay > 0 {
+		policy.MaxDelay = time.Duration(cfg.MaxDelay)
 	}
+	return policy
 }
 
+// objectOutcome is the result of processing a single source object.
+type objectOutcome int
+
+const (
+	outcomeSynced objectOutcome = iota
+	outcomeSkipped
+	outcomeFailed
+)
+
+// syncCounters aggregates per-object outcomes from concurrent workers.
+type syncCounters struct {
+	synced  atomic.Int64
+	skipped atomic.Int64
+	errors  atomic.Int64
+	deleted atomic.Int64
+	bytes   atomic.Int64 // Size of the objects synced, counted once per target
+}
+
+func (c *syncCounters) record(outcome objectOutcome) {
+	switch outcome {
+	case outcomeSynced:
+		c.synced.Add(1)
+	case outcomeSkipped:
+		c.skipped.Add(1)
+	case outcomeFailed:
+		c.errors.Add(1)
+	}
+}
+
+// mappingConcurrency returns the worker pool size for a mapping.
+func mappingConcurrency(mapping config.BucketMapping) int {
+	if mapping.Concurrency > 0 {
+		return mapping.Concurrency
+	}
+	return config.DefaultConcurrency
+}
+
+// acquireSlot blocks until the global transfer cap allows another transfer.
+func (s *Synchronizer) acquireSlot(ctx context.Context) error {
+	if s.slots == nil {
+		return ctx.Err()
+	}
+	return s.slots.Acquire(ctx, 1)
+}
+
+func (s *Synchronizer) releaseSlot() {
+	if s.slots != nil {
+		s.slots.Release(1)
+	}
+}
+
+// SyncAll synchronizes every configured mapping in turn. A failing mapping
+// does not stop the others; the errors of all failed mappings are returned
+// together.
 func (s *Synchronizer) SyncAll(ctx context.Context) error {
+	var errs []error
 	for _, mapping := range s.config.Mappings {
 		mapLogger := s.logger.With(
 			"source_provider", mapping.SourceProviderID,
@@ -42,6 +189,7 @@ func (s *Synchronizer) SyncAll(ctx context.Context) error {
 		err := s.SyncBuckets(ctx, mapping, mapLogger) // Pass logger down
 		if err != nil {
 			mapLogger.Error("Error synchronizing mapping", "error", err)
+			errs = append(errs, fmt.Errorf("mapping %s: %w", mappingKey(mapping), err))
 			// Continue with the next mapping even in case of error
 			continue
 		}
@@ -49,186 +197,700 @@ func (s *Synchronizer) SyncAll(ctx context.Context) error {
 		mapLogger.Info("Synchronization mapping completed successfully")
 	}
 
-	return nil
+	return errors.Join(errs...)
 }
 
-// SyncBuckets synchronizes a specific mapping between buckets
+// SyncBuckets synchronizes a specific mapping between buckets and records the
+// run with its statistics in the sync_runs table. The global and mapping hooks
+// run around it; a failing beforeRun hook vetoes the run with ErrRunVetoed.
 func (s *Synchronizer) SyncBuckets(ctx context.Context, mapping config.BucketMapping, logger *slog.Logger) error { // Accept logger
-	sourceProvider, err := s.providerFactory.GetProvider(mapping.SourceProviderID)
+	record := &database.SyncRun{MappingID: mappingKey(mapping), StartedAt: time.Now().UTC(), Status: database.RunStatusRunning}
+	recorded := true
+	if err := s.db.StartSyncRun(record); err != nil {
+		logger.Warn("Error recording start of sync run in DB", "error", err)
+		recorded = false
+	}
+
+	var counters syncCounters
+	runner, err := hooks.New(s.config.Hooks, mapping.Hooks)
+	if err != nil {
+		err = fmt.Errorf("error configuring hooks: %w", err)
+	} else if err = beforeRun(ctx, runner, record); err != nil {
+		logger.Warn("Run vetoed by beforeRun hook", "error", err)
+	} else {
+		err = s.syncMapping(ctx, mapping, runner, &counters, logger)
+	}
+
+	s.finishRun(record, &counters, err, recorded, logger)
+	afterRun(ctx, runner, record, errors.Is(err, ErrRunVetoed), logger)
+	return err
+}
+
+// finishRun fills in the outcome and statistics of a run started by
+// SyncBuckets and records them if the start of the run was.
+func (s *Synchronizer) finishRun(record *database.SyncRun, counters *syncCounters, syncErr error, recorded bool, logger *slog.Logger) {
+	record.FinishedAt = time.Now().UTC()
+	record.Synced = counters.synced.Load()
+	record.Skipped = counters.skipped.Load()
+	record.Failed = counters.errors.Load()
+	record.Deleted = counters.deleted.Load()
+	record.BytesTransferred = counters.bytes.Load()
+
+	switch {
+	case syncErr != nil:
+		record.Status, record.Error = database.RunStatusFailed, syncErr.Error()
+	case record.Failed > 0:
+		record.Status = database.RunStatusPartial
+	default:
+		record.Status = database.RunStatusSuccess
+	}
+
+	if !recorded {
+		return
+	}
+	if err := s.db.FinishSyncRun(record); err != nil {
+		logger.Warn("Error recording end of sync run in DB", "run_id", record.ID, "error", err)
+	}
+}
+
+// syncMapping copies new and changed objects of a mapping and applies its
+// delete policy, adding what it does to counters.
+func (s *Synchronizer) syncMapping(ctx context.Context, mapping config.BucketMapping, runner *hooks.Runner, counters *syncCounters, logger *slog.Logger) error {
+	run, err := s.prepareRun(ctx, mapping, logger)
+	if err != nil {
+		return err
+	}
+	run.hooks = runner
+
+	for _, target := range run.targets {
+		target.logger.Debug("Ensuring target bucket exists")
+		if err := target.target.EnsureBucketExists(ctx, target.mapping.TargetBucket); err != nil {
+			target.logger.Error("Failed to ensure target bucket exists", "error", err)
+			return fmt.Errorf("error ensuring target bucket %s exists: %w", target.mapping.TargetBucket, err)
+		}
+	}
+
+	if mapping.ReplicateVersions {
+		if err := s.syncVersions(ctx, run, counters); err != nil {
+			return err
+		}
+	}
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(mappingConcurrency(mapping))
+
+	for objName, srcObjInfo := range run.sourceObjects {
+		if gctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			if err := s.acquireSlot(gctx); err != nil {
+				return err
+			}
+			defer s.releaseSlot()
+
+			for _, outcome := range s.syncObject(gctx, run, objName, srcObjInfo) {
+				counters.record(outcome)
+				if outcome == outcomeSynced {
+					counters.bytes.Add(srcObjInfo.Size)
+				}
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		logger.Error("Object synchronization phase interrupted", "error", err)
+		return fmt.Errorf("error synchronizing objects for mapping %s: %w", run.mappingID, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	logger.Info("Object synchronization phase complete",
+		"synced", counters.synced.Load(),
+		"skipped", counters.skipped.Load(),
+		"errors", counters.errors.Load(),
+		"total_source_objects", len(run.sourceObjects),
+		"targets", len(run.targets))
+
+	var errs []error
+	for _, target := range run.targets {
+		// Pass logger to removeDeletedObjects
+		if err := s.removeDeletedObjects(ctx, target, counters, target.logger); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// mappingRun holds what a single synchronization or planning pass knows about
+// a mapping.
+type mappingRun struct {
+	mapping       config.BucketMapping
+	mappingID     string
+	source        interfaces.StorageProvider
+	sourceObjects map[string]*interfaces.ObjectInfo // Selected source objects by source key
+	matcher       *filter.Matcher
+	targets       []*targetRun
+	bandwidth     *throttle.Limiter // Upload rate shared by the transfers of the mapping; nil when unlimited
+	keyring       *envelope.Keyring // Client-side encryption keyring; nil when the mapping has none
+	transformers  []Transformer
+	hooks         *hooks.Runner // Hooks of the mapping; nil when it has none or is only planned
+	logger        *slog.Logger
+}
+
+// targetRun holds the state of one target of a mapping. Each target keeps its
+// own object state in the database under its mappingID.
+type targetRun struct {
+	mapping       config.BucketMapping // Single-target view of the mapping
+	mappingID     string
+	target        interfaces.StorageProvider
+	keys          *keymap.Mapper
+	matcher       *filter.Matcher
+	targetObjects map[string]*interfaces.ObjectInfo // Target objects under the target prefix by target key
+	listed        bool                              // targetObjects holds a successful listing
+	rewrite       map[string]bool                   // Source keys whose older versions this run wrote after the current one
+	targetKeys    map[string]string                 // Target key of each selected source object
+	sourceKeys    map[string]string                 // Selected source object of each target key in targetKeys
+	collisions    map[string][]string               // Target keys several selected source objects map to, with those objects; none of them is copied
+	transformers  []Transformer                     // Transform pipeline of the mapping, which may change target keys
+	encryption    *interfaces.Encryption            // Encryption the mapping writes objects with; nil for the target provider's
+	mode          interfaces.EncryptionMode         // Encryption mode the copies must have; empty for the bucket default
+	logger        *slog.Logger
+}
+
+// mappingKey returns the identifier under which the object state of a
+// single-target mapping is stored in the database. For fan-out mappings it
+// identifies the mapping as a whole in logs.
+func mappingKey(mapping config.BucketMapping) string {
+	if len(mapping.Targets) > 0 {
+		targets := make([]string, 0, len(mapping.Targets))
+		for _, target := range mapping.Targets {
+			targets = append(targets, target.ProviderID+":"+bucketPath(target.Bucket, target.Prefix))
+		}
+		return fmt.Sprintf("%s:%s->[%s]",
+			mapping.SourceProviderID, bucketPath(mapping.SourceBucket, mapping.SourcePrefix),
+			strings.Join(targets, ","))
+	}
+
+	return fmt.Sprintf("%s:%s->%s:%s",
+		mapping.SourceProviderID, bucketPath(mapping.SourceBucket, mapping.SourcePrefix),
+		mapping.TargetProviderID, bucketPath(mapping.TargetBucket, mapping.TargetPrefix))
+}
+
+// MappingID returns the identifier under which the runs of a mapping are
+// recorded in the sync_runs table.
+func MappingID(mapping config.BucketMapping) string {
+	return mappingKey(mapping)
+}
+
+func bucketPath(bucket, prefix string) string {
+	if prefix == "" {
+		return bucket
+	}
+	return bucket + "/" + prefix
+}
+
+// prepareRun resolves the providers of a mapping, lists the source and every
+// target and computes the target keys of every selected source object. A
+// failure to list a target is only logged, since the bucket may not exist yet.
+func (s *Synchronizer) prepareRun(ctx context.Context, mapping config.BucketMapping, logger *slog.Logger) (*mappingRun, error) {
+	run := &mappingRun{
+		mapping:   mapping,
+		mappingID: mappingKey(mapping),
+		logger:    logger,
+	}
+
+	var err error
+	run.source, err = s.providerFactory.GetProvider(mapping.SourceProviderID)
 	if err != nil {
 		logger.Error("Failed to get source provider", "error", err)
-		return fmt.Errorf("error getting source provider %s: %w", mapping.SourceProviderID, err)
+		return nil, fmt.Errorf("error getting source provider %s: %w", mapping.SourceProviderID, err)
 	}
 
-	targetProvider, err := s.providerFactory.GetProvider(mapping.TargetProviderID)
+	matcher, err := filter.New(mapping.Include, mapping.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("error compiling filters: %w", err)
+	}
+	run.matcher = matcher
+	if run.bandwidth, err = throttle.New(mapping.MaxBytesPerSecond, mapping.BandwidthSchedule); err != nil {
+		return nil, fmt.Errorf("error configuring bandwidth limit: %w", err)
+	}
+	encryption, err := mapping.Encryption.Encryption()
 	if err != nil {
-		logger.Error("Failed to get target provider", "error", err)
-		return fmt.Errorf("error getting target provider %s: %w", mapping.TargetProviderID, err)
+		return nil, fmt.Errorf("error configuring encryption: %w", err)
+	}
+	if run.transformers, err = s.newTransformers(mapping.Transforms); err != nil {
+		return nil, fmt.Errorf("error configuring transforms: %w", err)
+	}
+	if mapping.ClientEncryption != nil {
+		if run.keyring, err = s.keyring(mapping, run.mappingID); err != nil {
+			return nil, fmt.Errorf("error configuring client-side encryption: %w", err)
+		}
+	}
+
+	for _, single := range mapping.SingleTargets() {
+		target := &targetRun{
+			mapping:      single,
+			mappingID:    mappingKey(single),
+			matcher:      matcher,
+			targetKeys:   make(map[string]string),
+			sourceKeys:   make(map[string]string),
+			collisions:   make(map[string][]string),
+			rewrite:      make(map[string]bool),
+			transformers: run.transformers,
+			encryption:   encryption,
+			mode:         s.encryptionMode(single),
+			logger: logger.With(
+				"target_provider", single.TargetProviderID,
+				"target_bucket", single.TargetBucket,
+			),
+		}
+
+		target.target, err = s.providerFactory.GetProvider(single.TargetProviderID)
+		if err != nil {
+			logger.Error("Failed to get target provider", "error", err)
+			return nil, fmt.Errorf("error getting target provider %s: %w", single.TargetProviderID, err)
+		}
+		if target.keys, err = keymap.New(single.SourcePrefix, single.TargetPrefix, single.KeyTemplate); err != nil {
+			return nil, fmt.Errorf("error compiling key mapping: %w", err)
+		}
+		run.targets = append(run.targets, target)
 	}
 
-	logger.Debug("Listing objects from source bucket") // Use Debug for finer-grained logs
-	sourceObjects, err := sourceProvider.ListObjects(ctx, mapping.SourceBucket)
+	logger.Debug("Listing objects from source bucket", "prefix", mapping.SourcePrefix) // Use Debug for finer-grained logs
+	sourceObjects, err := run.source.ListObjects(ctx, mapping.SourceBucket, mapping.SourcePrefix)
 	if err != nil {
 		logger.Error("Failed to list objects from source bucket", "error", err)
-		return fmt.Errorf("error listing objects from source bucket %s: %w", mapping.SourceBucket, err)
+		return nil, fmt.Errorf("error listing objects from source bucket %s: %w", mapping.SourceBucket, err)
 	}
-	logger.Debug("Listed source objects", "count", len(sourceObjects))
 
-	logger.Debug("Listing objects from target bucket")
-	targetObjects, err := targetProvider.ListObjects(ctx, mapping.TargetBucket)
-	if err != nil {
-		// Log warning instead of error? Maybe bucket doesn't exist yet.
-		logger.Warn("Failed to list objects from target bucket, attempting to ensure bucket exists", "error", err)
-		// Continue, EnsureBucketExists will handle creation or return error
-	} else {
-		logger.Debug("Listed target objects", "count", len(targetObjects))
+	run.sourceObjects = make(map[string]*interfaces.ObjectInfo, len(sourceObjects))
+	for name, info := range sourceObjects {
+		if matcher.Match(name) {
+			run.sourceObjects[name] = info
+		}
+	}
+	logger.Debug("Listed source objects", "count", len(run.sourceObjects), "listed", len(sourceObjects))
+
+	for _, target := range run.targets {
+		for name, info := range run.sourceObjects {
+			targetKey, err := target.keys.TargetKey(name, info.LastModified)
+			if err != nil {
+				target.logger.Error("Failed to compute target key, skipping object", "object_name", name, "error", err)
+				continue
+			}
+			targetKey = transformedKey(target.transformers, targetKey)
+			if other, ok := target.sourceKeys[targetKey]; ok {
+				delete(target.targetKeys, other)
+				delete(target.sourceKeys, targetKey)
+				target.collisions[targetKey] = []string{other}
+			}
+			if target.collisions[targetKey] != nil {
+				target.collisions[targetKey] = append(target.collisions[targetKey], name)
+				continue
+			}
+			target.targetKeys[name] = targetKey
+			target.sourceKeys[targetKey] = name
+		}
+		for targetKey, names := range target.collisions {
+			sort.Strings(names)
+			target.logger.Error("Source objects map to the same target key, skipping them",
+				"target_key", targetKey, "objects", names)
+		}
+
+		target.logger.Debug("Listing objects from target bucket", "prefix", target.mapping.TargetPrefix)
+		target.targetObjects, err = target.target.ListObjects(ctx, target.mapping.TargetBucket, target.mapping.TargetPrefix)
+		if err != nil {
+			// Maybe the bucket doesn't exist yet.
+			target.logger.Warn("Failed to list objects from target bucket, treating it as empty", "error", err)
+			target.targetObjects = nil
+		} else {
+			target.listed = true
+			target.logger.Debug("Listed target objects", "count", len(target.targetObjects))
+		}
 	}
 
-	logger.Debug("Ensuring target bucket exists")
-	if err := targetProvider.EnsureBucketExists(ctx, mapping.TargetBucket); err != nil {
-		logger.Error("Failed to ensure target bucket exists", "error", err)
-		return fmt.Errorf("error ensuring target bucket %s exists: %w", mapping.TargetBucket, err)
+	return run, nil
+}
+
+// syncDecision reports whether a source object must be copied to targetKey
+// with tags given the metadata stored for it, and why. A failed object whose
+// source is unchanged waits until its next retry time, or indefinitely once
+// quarantined.
+func syncDecision(stored *database.FileMetadata, src *interfaces.ObjectInfo, tags map[string]string, targetKey string, now time.Time) (bool, string) {
+	switch {
+	case stored == nil, stored.SyncStatus == statusTrashed, stored.SyncStatus == statusRetained:
+		return true, ReasonNew
+	case stored.ETag != src.ETag:
+		return true, ReasonETagChanged
+	case !stored.LastModified.Equal(src.LastModified):
+		return true, ReasonModified
+	case stored.SyncStatus == statusQuarantined:
+		return false, ReasonQuarantined
+	case stored.SyncStatus != statusSuccess && now.Before(stored.NextRetryAt):
+		return false, ReasonRetryScheduled
+	case stored.SyncStatus != statusSuccess:
+		return true, ReasonPreviousFailure
+	case stored.TargetKey != targetKey:
+		return true, ReasonTargetKeyChanged
+	case storedMetadataHash(stored) != metadataHash(src):
+		return true, ReasonMetadataChanged
+	case stored.TagsHash != tagsHash(tags):
+		return true, ReasonTagsChanged
+	default:
+		return false, ReasonUnchanged
 	}
+}
+
+// pendingUpload is a target that needs a copy of the object being synchronized.
+type pendingUpload struct {
+	target    *targetRun
+	targetKey string
+	stored    *database.FileMetadata // Metadata of the previous sync, nil if none
+	logger    *slog.Logger
+}
 
-	mappingID := fmt.Sprintf("%s:%s->%s:%s",
-		mapping.SourceProviderID, mapping.SourceBucket,
-		mapping.TargetProviderID, mapping.TargetBucket)
+// syncObject copies a single object from source to every target whose stored
+// metadata shows it is new, changed or previously failed. The source is read
+// once however many targets need the object. It returns one outcome per
+// target and is safe to call from multiple goroutines.
+func (s *Synchronizer) syncObject(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo) []objectOutcome {
+	outcomes := make([]objectOutcome, 0, len(run.targets))
+	var pending []pendingUpload
 
-	syncCounter := 0
-	skipCounter := 0
-	errorCounter := 0
+	// The tags are compared with those of the last sync, so they are read
+	// first. An object whose tags cannot be read fails on every target.
+	tags, tagsErr := s.objectTags(ctx, run, objName, srcObjInfo)
 
-	for objName, srcObjInfo := range sourceObjects {
-		objLogger := logger.With("object_name", objName) // Logger with object context
+	for _, target := range run.targets {
+		objLogger := target.logger.With("object_name", objName) // Logger with object context
 		objLogger.Debug("Processing object")
 
-		storedMetadata, err := s.db.GetFileMetadata(mappingID, objName)
+		targetKey, ok := target.targetKeys[objName]
+		if !ok {
+			outcomes = append(outcomes, outcomeFailed)
+			continue
+		}
+
+		storedMetadata, err := s.db.GetFileMetadata(target.mappingID, objName)
 		if err != nil {
 			// Log error but continue, treat as if metadata doesn't exist
 			objLogger.Warn("Error fetching metadata from DB, proceeding as if object is new/changed", "error", err)
 		}
+		if tagsErr != nil {
+			pending = append(pending, pendingUpload{target: target, targetKey: targetKey, stored: storedMetadata, logger: objLogger})
+			continue
+		}
 
-		needsSync := true
+		needsSync, reason := objectDecision(storedMetadata, srcObjInfo, tags, target, targetKey, time.Now())
+		switch {
+		case reason == ReasonQuarantined:
+			objLogger.Debug("Object is quarantined, skipping until requeued or changed in the source",
+				"attempts", storedMetadata.AttemptCount, "last_error", storedMetadata.LastError)
+			outcomes = append(outcomes, outcomeSkipped)
+			continue
+		case reason == ReasonRetryScheduled:
+			objLogger.Debug("Previous sync failed, waiting before retrying",
+				"attempts", storedMetadata.AttemptCount, "next_retry_at", storedMetadata.NextRetryAt)
+			outcomes = append(outcomes, outcomeSkipped)
+			continue
+		case !needsSync && (reason == ReasonTargetMissing || reason == ReasonTargetChanged):
+			objLogger.Warn("Target copy was changed or removed outside of the sync, leaving it as is",
+				"reason", reason, "target_key", targetKey, "drift_policy", driftPolicy(target.mapping))
+			outcomes = append(outcomes, outcomeSkipped)
+			continue
+		case !needsSync:
+			objLogger.Debug("Object metadata matches and last sync succeeded, skipping",
+				"db_last_modified", storedMetadata.LastModified, "src_last_modified", srcObjInfo.LastModified,
+				"db_etag", storedMetadata.ETag, "src_etag", srcObjInfo.ETag)
+			outcomes = append(outcomes, outcomeSkipped)
+			continue
+		}
 		if storedMetadata != nil {
-			// Compare metadata
-			if storedMetadata.LastModified.Equal(srcObjInfo.LastModified) && storedMetadata.ETag == srcObjInfo.ETag && storedMetadata.SyncStatus == "success" {
-				needsSync = false
-				objLogger.Debug("Object metadata matches and last sync succeeded, skipping",
-					"db_last_modified", storedMetadata.LastModified, "src_last_modified", srcObjInfo.LastModified,
-					"db_etag", storedMetadata.ETag, "src_etag", srcObjInfo.ETag)
-				skipCounter++
-			} else {
-				objLogger.Info("Object changed or previous sync failed, needs sync",
-					"db_last_modified", storedMetadata.LastModified, "src_last_modified", srcObjInfo.LastModified,
-					"db_etag", storedMetadata.ETag, "src_etag", srcObjInfo.ETag,
-					"db_sync_status", storedMetadata.SyncStatus)
-			}
+			objLogger.Info("Object changed or previous sync failed, needs sync",
+				"reason", reason,
+				"db_last_modified", storedMetadata.LastModified, "src_last_modified", srcObjInfo.LastModified,
+				"db_etag", storedMetadata.ETag, "src_etag", srcObjInfo.ETag,
+				"db_sync_status", storedMetadata.SyncStatus)
 		} else {
 			objLogger.Info("Object not found in DB or failed to fetch metadata, needs sync")
 		}
 
-		if needsSync {
-			objLogger.Info("Synchronizing object")
+		pending = append(pending, pendingUpload{target: target, targetKey: targetKey, stored: storedMetadata, logger: objLogger})
+	}
 
-			objLogger.Debug("Getting object from source")
-			_, reader, err := sourceProvider.GetObject(ctx, mapping.SourceBucket, objName)
-			if err != nil {
-				objLogger.Error("Error getting object from source", "error", err)
-				s.updateObjectMetadata(mappingID, objName, srcObjInfo, "failed_get", objLogger)
-				errorCounter++
+	var results []transferResult
+	if tagsErr != nil {
+		results = failedTransfers(pending, tagsErr)
+	} else {
+		results = s.transferWithRetry(ctx, run, objName, srcObjInfo, tags, pending)
+	}
+	for _, result := range results {
+		p := result.pending
+		if result.status == statusFailedGet && errors.Is(result.err, interfaces.ErrNotFound) {
+			p.logger.Info("Object was removed from the source during the run, skipping", "error", result.err)
+			outcomes = append(outcomes, outcomeSkipped)
+			continue
+		}
+
+		switch result.status {
+		case statusSuccess:
+			p.logger.Info("Object synchronized successfully", "target_key", p.targetKey, "verified", result.verified)
+			outcomes = append(outcomes, outcomeSynced)
+			s.afterUpload(ctx, run, objName, srcObjInfo, result)
+		case statusFailedGet:
+			p.logger.Error("Error getting object from source", "error", result.err)
+			outcomes = append(outcomes, outcomeFailed)
+		case statusFailedUpload:
+			p.logger.Error("Error uploading object to target", "error", result.err)
+			outcomes = append(outcomes, outcomeFailed)
+		case statusFailedVerify:
+			p.logger.Error("Uploaded object failed verification", "target_key", p.targetKey, "error", result.err)
+			outcomes = append(outcomes, outcomeFailed)
+		}
+		s.updateObjectMetadata(objName, srcObjInfo, tags, result)
+	}
+	return outcomes
+}
+
+// transferWithRetry copies an object to the pending targets and returns the
+// final result of each. Upload failures are retried with a fresh source
+// stream, since the failed attempt consumed the previous one. Multipart
+// uploads resume from the last recorded part.
+func (s *Synchronizer) transferWithRetry(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo, tags map[string]string, pending []pendingUpload) []transferResult {
+	var results []transferResult
+	for attempt := 1; len(pending) > 0; attempt++ {
+		var retries []pendingUpload
+		var delay time.Duration
+
+		for _, result := range s.transferAll(ctx, run, objName, srcObjInfo, tags, pending) {
+			p := result.pending
+			policy := retry.PolicyOf(p.target.target)
+			if result.status == statusFailedUpload && policy.ShouldRetry(result.err, attempt) {
+				wait := policy.Delay(attempt)
+				p.logger.Warn("Error uploading object to target, retrying with a new source stream",
+					"attempt", attempt, "max_attempts", policy.MaxAttempts, "delay", wait, "error", result.err)
+				retries = append(retries, p)
+				delay = max(delay, wait)
 				continue
 			}
-			// garante fechamento ao final
-			defer reader.Close()
+			results = append(results, result)
+		}
 
-			objLogger.Debug("Uploading object to target (stream)", "size", srcObjInfo.Size, "content_type", srcObjInfo.ContentType)
-			_, err = targetProvider.UploadObject(
+		pending = retries
+		if len(pending) > 0 {
+			if err := retry.Sleep(ctx, delay); err != nil {
+				for _, p := range pending {
+					p.logger.Warn("Upload retry interrupted", "error", err)
+					results = append(results, transferResult{pending: p, status: statusFailedUpload, err: err})
+				}
+				break
+			}
+		}
+	}
+	return results
+}
+
+// failedTransfers returns a failed source read for every pending target, for
+// an object that could not be read at all.
+func failedTransfers(pending []pendingUpload, err error) []transferResult {
+	results := make([]transferResult, len(pending))
+	for i, p := range pending {
+		results[i] = transferResult{pending: p, status: statusFailedGet, err: err}
+	}
+	return results
+}
+
+// transferResult is the result of streaming an object to one target.
+type transferResult struct {
+	pending  pendingUpload
+	status   string
+	err      error
+	checksum string
+	verified bool                   // The target reported a checksum that matched the content
+	uploaded *interfaces.UploadInfo // What the target reported for the stored copy; nil if the upload did not complete
+}
+
+// transfer reads an object from the source once and streams it to every
+// pending target, verifying each upload against the checksum the target
+// reports.
+func (s *Synchronizer) transfer(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo, tags map[string]string, pending []pendingUpload) []transferResult {
+	results := make([]transferResult, len(pending))
+	for i, p := range pending {
+		results[i].pending = p
+	}
+
+	run.logger.Info("Synchronizing object", "object_name", objName, "targets", len(pending))
+
+	run.logger.Debug("Getting object from source", "object_name", objName, "version_id", srcObjInfo.VersionID)
+	info, reader, err := openSource(ctx, run, objName, srcObjInfo)
+	if err != nil {
+		for i := range results {
+			results[i].status, results[i].err = statusFailedGet, err
+		}
+		return results
+	}
+	defer reader.Close()
+
+	opts := uploadOptions(srcObjInfo, info)
+	opts.Tags = tags
+	opts.StorageClass = objectStorageClass(run, objName, srcObjInfo)
+
+	content, cleanup, err := s.transformContent(run, objName, reader, srcObjInfo.Size, opts, pending)
+	defer cleanup()
+	if err != nil {
+		run.logger.Error("Failed to transform object content", "object_name", objName, "error", err)
+		for i := range results {
+			results[i].status, results[i].err = statusFailedGet, err
+		}
+		return results
+	}
+	opts, size := content.Options, content.Size
+
+	// Hash the stream once, after the transforms; every target receives the
+	// same bytes.
+	sum := newChecksumReader(content.Reader)
+
+	uploads := make([]func(io.Reader) error, len(pending))
+	uploaded := make([]*interfaces.UploadInfo, len(pending))
+	for i, p := range pending {
+		uploads[i] = func(r io.Reader) error {
+			r = s.throttle(ctx, run, r)
+			p.logger.Debug("Uploading object to target (stream)", "target_key", p.targetKey, "size", size, "content_type", opts.ContentType)
+			opts := opts
+			opts.Encryption = p.target.encryption
+			info, err := p.target.target.UploadObject(
 				ctx,
-				mapping.TargetBucket,
-				objName,
-				reader,          // leio direto do ReadCloser
-				srcObjInfo.Size, // tamanho já conhecido em srcObjInfo
-				srcObjInfo.ContentType,
+				p.target.mapping.TargetBucket,
+				p.targetKey,
+				r,    // stream straight from the source ReadCloser
+				size, // size known from the listing, unless a transform changed it
+				opts,
 			)
-			if err != nil {
-				objLogger.Error("Error uploading object to target", "error", err)
-				s.updateObjectMetadata(mappingID, objName, srcObjInfo, "failed_upload", objLogger)
-				errorCounter++
-				continue
-			}
+			uploaded[i] = info
+			return err
+		}
+	}
+
+	for i, err := range fanOut(sum, uploads) {
+		if err != nil {
+			results[i].status, results[i].err = statusFailedUpload, err
+			continue
+		}
+		results[i].uploaded = uploaded[i]
 
-			objLogger.Info("Object synchronized successfully")
-			s.updateObjectMetadata(mappingID, objName, srcObjInfo, "success", objLogger)
-			syncCounter++
+		results[i].checksum = sum.Checksum()
+		results[i].verified, err = sum.verify(uploaded[i], size)
+		if err != nil {
+			results[i].status, results[i].err = statusFailedVerify, err
+			continue
+		}
+		if !results[i].verified {
+			results[i].pending.logger.Debug("Target reported no comparable checksum, upload not verified", "target_key", results[i].pending.targetKey)
 		}
+		results[i].status = statusSuccess
 	}
+	return results
+}
 
-	logger.Info("Object synchronization phase complete",
-		"synced", syncCounter,
-		"skipped", skipCounter,
-		"errors", errorCounter,
-		"total_source_objects", len(sourceObjects))
+// uploadOptions returns the headers and user metadata to upload an object
+// with. The info returned when the object was opened describes the content
+// being read and is preferred; not every listing reports all of it.
+func uploadOptions(listed, opened *interfaces.ObjectInfo) interfaces.UploadOptions {
+	if opened == nil {
+		return listed.UploadOptions()
+	}
+	opts := opened.UploadOptions()
+	if listed.ContentType != "" {
+		opts.ContentType = listed.ContentType
+	}
+	return opts
+}
 
-	// Pass logger to removeDeletedObjects
-	s.removeDeletedObjects(ctx, mappingID, mapping, sourceObjects, targetObjects, targetProvider, logger)
+// openSource opens the object or, when srcObjInfo names one, the version of
+// the object to copy.
+func openSource(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	if srcObjInfo.VersionID == "" {
+		return run.source.GetObject(ctx, run.mapping.SourceBucket, objName)
+	}
 
-	return nil
+	lister, ok := run.source.(interfaces.VersionLister)
+	if !ok {
+		return nil, nil, fmt.Errorf("source provider %s does not support object versions", run.mapping.SourceProviderID)
+	}
+	return lister.GetObjectVersion(ctx, run.mapping.SourceBucket, objName, srcObjInfo.VersionID)
 }
 
-// updateObjectMetadata updates object metadata in the database
-func (s *Synchronizer) updateObjectMetadata(mappingID string, objectName string, info *interfaces.ObjectInfo, status string, logger *slog.Logger) { // Accept logger
+// throttle limits an upload stream to the global rate and the rate of the
+// mapping. Every upload of a fan-out mapping counts against both.
+func (s *Synchronizer) throttle(ctx context.Context, run *mappingRun, r io.Reader) io.Reader {
+	return throttle.NewReader(ctx, r, s.bandwidth, run.bandwidth)
+}
+
+// updateObjectMetadata records the result of transferring an object to one
+// target in the database. A failure is counted against the object and
+// schedules its next retry; once the object has failed the configured number
+// of times in a row it is quarantined instead.
+func (s *Synchronizer) updateObjectMetadata(objectName string, info *interfaces.ObjectInfo, tags map[string]string, result transferResult) {
+	p, status, syncErr := result.pending, result.status, result.err
+	now := time.Now().UTC()
 	metadata := &database.FileMetadata{
-		MappingID:    mappingID,
+		MappingID:    p.target.mappingID,
 		ObjectName:   objectName,
 		Size:         info.Size,
 		LastModified: info.LastModified,
 		ETag:         info.ETag,
 		ContentType:  info.ContentType,
-		LastSynced:   time.Now().UTC(), // Use UTC
+		MetadataHash: metadataHash(info),
+		TagsHash:     tagsHash(tags),
+		LastSynced:   now,
 		SyncStatus:   status,
+		TargetKey:    p.targetKey,
+		Checksum:     result.checksum,
 	}
 
-	logger.Debug("Upserting file metadata", "status", status)
-	if err := s.db.UpsertFileMetadata(metadata); err != nil {
-		// Use the passed-in object-specific logger
-		logger.Error("Error updating metadata in DB", "error", err)
-	}
-}
-
-// removeDeletedObjects removes objects from the target that no longer exist in the source
-func (s *Synchronizer) removeDeletedObjects(
-	ctx context.Context,
-	mappingID string,
-	mapping config.BucketMapping,
-	sourceObjects map[string]*interfaces.ObjectInfo,
-	targetObjects map[string]*interfaces.ObjectInfo,
-	targetProvider interfaces.StorageProvider,
-	logger *slog.Logger, // Accept logger
-) {
-	logger.Info("Checking for objects to remove from target")
-	deleteCounter := 0
-	errorCounter := 0
-
-	for objName := range targetObjects {
-		if _, exists := sourceObjects[objName]; !exists {
-			objLogger := logger.With("object_name", objName) // Logger with object context
-			objLogger.Info("Removing object from target (deleted from source)")
-
-			if err := targetProvider.DeleteObject(ctx, mapping.TargetBucket, objName); err != nil {
-				objLogger.Error("Error removing object from target", "error", err)
-				errorCounter++
-				continue // Skip DB deletion if target deletion failed
-			}
+	// The object is ours once an upload reached the target, and stays ours
+	// through later failures as long as it keeps its target key.
+	metadata.Owned = status == statusSuccess || status == statusFailedVerify ||
+		(p.stored != nil && p.stored.Owned && p.stored.TargetKey == p.targetKey)
+
+	// Remember what the target holds so that later runs notice when the copy
+	// is changed or removed behind our back. A failed upload leaves the
+	// previous copy in place.
+	if result.uploaded != nil {
+		metadata.TargetETag, metadata.TargetSize = result.uploaded.ETag, result.uploaded.Size
+		metadata.Encryption = string(result.uploaded.Encryption)
+	} else if p.stored != nil && p.stored.TargetKey == p.targetKey {
+		metadata.TargetETag, metadata.TargetSize = p.stored.TargetETag, p.stored.TargetSize
+		metadata.Encryption = p.stored.Encryption
+	}
 
-			objLogger.Debug("Removing object metadata from DB")
-			if err := s.db.DeleteFileMetadata(mappingID, objName); err != nil {
-				objLogger.Error("Error removing metadata from DB", "error", err)
-				// Log error but continue, object was deleted from target
-			}
+	if status != statusSuccess {
+		// Failures are counted per source version; a changed object starts over.
+		metadata.AttemptCount = 1
+		if prev := p.stored; prev != nil && prev.AttemptCount > 0 && prev.ETag == info.ETag && prev.LastModified.Equal(info.LastModified) {
+			metadata.AttemptCount = prev.AttemptCount + 1
+		}
+		if syncErr != nil {
+			metadata.LastError = syncErr.Error()
+		}
 
-			objLogger.Info("Object removed successfully from target")
-			deleteCounter++
+		if metadata.AttemptCount >= s.failures.MaxAttempts {
+			metadata.SyncStatus = statusQuarantined
+			p.logger.Error("Object failed too many times, quarantining it until requeued",
+				"attempts", metadata.AttemptCount, "status", status)
+		} else {
+			metadata.NextRetryAt = now.Add(s.failures.Delay(metadata.AttemptCount))
+			p.logger.Warn("Scheduled retry of failed object", "attempts", metadata.AttemptCount,
+				"max_attempts", s.failures.MaxAttempts, "next_retry_at", metadata.NextRetryAt)
 		}
 	}
-	logger.Info("Object removal phase complete", "removed", deleteCounter, "errors", errorCounter)
+
+	p.logger.Debug("Upserting file metadata", "status", metadata.SyncStatus)
+	if err := s.db.UpsertFileMetadata(metadata); err != nil {
+		p.logger.Error("Error updating metadata in DB", "error", err)
+	}
 }
@@ -3,7 +3,19 @@ package sync
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	gosync "sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -11,24 +23,58 @@ import (
 
 	"github.com/DjonatanS/cloud-data-sync/internal/config"
 	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/envelope"
+	"github.com/DjonatanS/cloud-data-sync/internal/filter"
+	"github.com/DjonatanS/cloud-data-sync/internal/hooks"
 	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/DjonatanS/cloud-data-sync/internal/retry"
 	"github.com/DjonatanS/cloud-data-sync/internal/storage"
+	"github.com/DjonatanS/cloud-data-sync/internal/storageclass"
 )
 
+// withPrefix returns the objects whose key starts with prefix.
+func withPrefix(objects map[string]*interfaces.ObjectInfo, prefix string) map[string]*interfaces.ObjectInfo {
+	selected := make(map[string]*interfaces.ObjectInfo)
+	for name, info := range objects {
+		if strings.HasPrefix(name, prefix) {
+			selected[name] = info
+		}
+	}
+	return selected
+}
+
 // fakeSourceProvider implements StorageProvider for source, returns predefined objects and data
 type fakeSourceProvider struct {
 	objects map[string]*interfaces.ObjectInfo
 	data    map[string][]byte
+	heads   map[string]*interfaces.ObjectInfo // Reported when an object is read instead of its listed info, if set
+	listErr error                             // Returned by ListObjects, e.g. for a missing bucket
+	gets    atomic.Int32                      // GetObject calls
+	ranges  atomic.Int32                      // GetObjectRange calls
 }
 
-func (f *fakeSourceProvider) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
-	return f.objects, nil
+func (f *fakeSourceProvider) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	return withPrefix(f.objects, prefix), nil
 }
 func (f *fakeSourceProvider) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
-	info := f.objects[objectName]
+	f.gets.Add(1)
+	info, _ := f.StatObject(ctx, bucketName, objectName)
 	return info, io.NopCloser(bytes.NewReader(f.data[objectName])), nil
 }
-func (f *fakeSourceProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+func (f *fakeSourceProvider) StatObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, error) {
+	if info, ok := f.heads[objectName]; ok {
+		return info, nil
+	}
+	return f.objects[objectName], nil
+}
+func (f *fakeSourceProvider) GetObjectRange(ctx context.Context, bucketName, objectName string, offset, length int64) (io.ReadCloser, error) {
+	f.ranges.Add(1)
+	return io.NopCloser(bytes.NewReader(f.data[objectName][offset : offset+length])), nil
+}
+func (f *fakeSourceProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	// not used
 	return nil, nil
 }
@@ -45,25 +91,46 @@ func (f *fakeSourceProvider) Close() error { return nil }
 
 // fakeTargetProvider captures uploaded objects
 type fakeTargetProvider struct {
+	mu       gosync.Mutex
 	uploaded map[string][]byte
 	objects  map[string]*interfaces.ObjectInfo // initial target objects
 	deleted  []string
+	corrupt  bool                                // Store a damaged copy of every upload, as a faulty target would
+	options  map[string]interfaces.UploadOptions // Options of the last upload of every key
 }
 
-func (f *fakeTargetProvider) ListObjects(ctx context.Context, bucketName string) (map[string]*interfaces.ObjectInfo, error) {
-	return f.objects, nil
+func (f *fakeTargetProvider) ListObjects(ctx context.Context, bucketName, prefix string) (map[string]*interfaces.ObjectInfo, error) {
+	return withPrefix(f.objects, prefix), nil
 }
 func (f *fakeTargetProvider) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
-	// not used
-	return nil, nil, nil
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.objects[objectName], io.NopCloser(bytes.NewReader(f.uploaded[objectName])), nil
 }
-func (f *fakeTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, contentType string) (*interfaces.UploadInfo, error) {
+func (f *fakeTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
 	buf := new(bytes.Buffer)
 	io.Copy(buf, reader)
-	f.uploaded[objectName] = buf.Bytes()
-	return &interfaces.UploadInfo{Bucket: bucketName, Key: objectName, Size: size}, nil
+	data := buf.Bytes()
+	if f.corrupt && len(data) > 0 {
+		data[0] ^= 0xff
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploaded[objectName] = data
+	if f.options == nil {
+		f.options = make(map[string]interfaces.UploadOptions)
+	}
+	f.options[objectName] = opts
+	sum := md5.Sum(data)
+	info := &interfaces.UploadInfo{Bucket: bucketName, Key: objectName, ETag: `"` + hex.EncodeToString(sum[:]) + `"`, Size: int64(len(data)), ContentMD5: hex.EncodeToString(sum[:])}
+	if opts.Encryption != nil {
+		info.Encryption = opts.Encryption.Mode
+	}
+	return info, nil
 }
 func (f *fakeTargetProvider) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	f.deleted = append(f.deleted, objectName)
 	return nil
 }
@@ -125,3 +192,1734 @@ func TestSyncBuckets_CopyAndMetadata(t *testing.T) {
 		t.Errorf("unexpected metadata: %+v", meta)
 	}
 }
+
+func TestSyncBuckets_ConcurrentTransfers(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	srcObjects := make(map[string]*interfaces.ObjectInfo)
+	srcData := make(map[string][]byte)
+	for i := 0; i < 50; i++ {
+		name := fmt.Sprintf("obj-%02d", i)
+		srcObjects[name] = &interfaces.ObjectInfo{Name: name, Bucket: "src", Size: int64(len(name)), LastModified: now, ETag: "etag-" + name}
+		srcData[name] = []byte(name)
+	}
+	source := &fakeSourceProvider{objects: srcObjects, data: srcData}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}
+
+	db, err := database.NewDB(t.TempDir() + "/sync.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	defer db.Close()
+
+	cfg := &config.Config{
+		MaxConcurrency: 3,
+		Mappings:       []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt", Concurrency: 8}},
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := storage.NewFactoryWithProviders(
+		map[string]interfaces.StorageProvider{"src": source, "tgt": target},
+		logger,
+	)
+
+	syncer := NewSynchronizer(db, cfg, factory, logger)
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	if len(target.uploaded) != len(srcObjects) {
+		t.Fatalf("expected %d uploads, got %d", len(srcObjects), len(target.uploaded))
+	}
+	list, err := db.ListFileMetadataByMapping("src:src->tgt:tgt")
+	if err != nil {
+		t.Fatalf("ListFileMetadataByMapping failed: %v", err)
+	}
+	if len(list) != len(srcObjects) {
+		t.Errorf("expected %d metadata rows, got %d", len(srcObjects), len(list))
+	}
+	for _, meta := range list {
+		if meta.SyncStatus != "success" {
+			t.Errorf("object %s has status %s, want success", meta.ObjectName, meta.SyncStatus)
+		}
+	}
+}
+
+// newTestSynchronizer wires a Synchronizer to the given fake providers and a
+// temporary database.
+func newTestSynchronizer(t *testing.T, cfg *config.Config, providers map[string]interfaces.StorageProvider, opts ...Option) (*Synchronizer, *database.DB) {
+	t.Helper()
+	db, err := database.NewDB(t.TempDir() + "/sync.db")
+	if err != nil {
+		t.Fatalf("failed to create DB: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	factory := storage.NewFactoryWithProviders(providers, logger)
+	return NewSynchronizer(db, cfg, factory, logger, opts...), db
+}
+
+func TestPlan_ReportsChangesWithoutApplying(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	srcObjects := map[string]*interfaces.ObjectInfo{
+		"new.txt":     {Name: "new.txt", Size: 3, LastModified: now, ETag: "e-new"},
+		"same.txt":    {Name: "same.txt", Size: 4, LastModified: now, ETag: "e-same"},
+		"changed.txt": {Name: "changed.txt", Size: 7, LastModified: now, ETag: "e-changed-2"},
+		"failed.txt":  {Name: "failed.txt", Size: 6, LastModified: now, ETag: "e-failed"},
+	}
+	source := &fakeSourceProvider{objects: srcObjects, data: map[string][]byte{}}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+		"same.txt": {Name: "same.txt", Size: 4},
+		"gone.txt": {Name: "gone.txt", Size: 9},
+	}}
+
+	cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt"}}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+	mappingID := "src:src->tgt:tgt"
+	for name, etag := range map[string]string{"same.txt": "e-same", "changed.txt": "e-changed-1", "failed.txt": "e-failed", "gone.txt": "e-gone"} {
+		status := "success"
+		if name == "failed.txt" {
+			status = "failed_upload"
+		}
+		if err := db.UpsertFileMetadata(&database.FileMetadata{MappingID: mappingID, ObjectName: name, LastModified: now, ETag: etag, LastSynced: now, SyncStatus: status, Owned: status == "success"}); err != nil {
+			t.Fatalf("UpsertFileMetadata failed: %v", err)
+		}
+	}
+
+	plans, err := syncer.Plan(context.Background(), cfg.Mappings[0])
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected one plan, got %d", len(plans))
+	}
+	plan := plans[0]
+
+	want := map[string]PlanItem{
+		"new.txt":     {Action: ActionUpload, Reason: ReasonNew},
+		"same.txt":    {Action: ActionSkip, Reason: ReasonUnchanged},
+		"changed.txt": {Action: ActionUpload, Reason: ReasonETagChanged},
+		"failed.txt":  {Action: ActionUpload, Reason: ReasonPreviousFailure},
+		"gone.txt":    {Action: ActionDelete, Reason: ReasonDeletedInSource},
+	}
+	if len(plan.Items) != len(want) {
+		t.Fatalf("expected %d plan items, got %d: %+v", len(want), len(plan.Items), plan.Items)
+	}
+	for _, item := range plan.Items {
+		w := want[item.Object]
+		if item.Action != w.Action || item.Reason != w.Reason {
+			t.Errorf("object %s: got %s/%s, want %s/%s", item.Object, item.Action, item.Reason, w.Action, w.Reason)
+		}
+	}
+	if plan.Uploads != 3 || plan.Skips != 1 || plan.Deletes != 1 || plan.BytesToTransfer != 16 {
+		t.Errorf("unexpected plan totals: %+v", plan)
+	}
+
+	if len(target.uploaded) != 0 || len(target.deleted) != 0 {
+		t.Errorf("plan modified target: uploaded=%v deleted=%v", target.uploaded, target.deleted)
+	}
+	if meta, _ := db.GetFileMetadata(mappingID, "new.txt"); meta != nil {
+		t.Errorf("plan wrote metadata for new.txt: %+v", meta)
+	}
+}
+
+func TestSyncBuckets_DeletePolicy(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	tests := []struct {
+		name        string
+		policy      config.DeletePolicy
+		wantDeleted []string
+		wantTrash   string
+		wantStatus  string
+	}{
+		{name: "mirror", policy: config.DeletePolicyMirror, wantDeleted: []string{"gone.txt"}},
+		{name: "none", policy: config.DeletePolicyNone, wantStatus: statusRetained},
+		{name: "trash", policy: config.DeletePolicyTrash, wantDeleted: []string{"gone.txt"}, wantTrash: ".trash/gone.txt", wantStatus: statusTrashed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSourceProvider{
+				objects: map[string]*interfaces.ObjectInfo{"kept.txt": {Name: "kept.txt", Size: 4, LastModified: now, ETag: "k"}},
+				data:    map[string][]byte{"kept.txt": []byte("kept")},
+			}
+			target := &fakeTargetProvider{
+				uploaded: map[string][]byte{"gone.txt": []byte("old"), ".trash/older.txt": []byte("older")},
+				objects: map[string]*interfaces.ObjectInfo{
+					"gone.txt":         {Name: "gone.txt", Size: 3},
+					".trash/older.txt": {Name: ".trash/older.txt", Size: 5},
+				},
+			}
+			cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt", DeletePolicy: tt.policy}}}
+			syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+			mappingID := "src:src->tgt:tgt"
+			if err := db.UpsertFileMetadata(&database.FileMetadata{MappingID: mappingID, ObjectName: "gone.txt", Size: 3, LastModified: now, ETag: "e", LastSynced: now, SyncStatus: statusSuccess, Owned: true}); err != nil {
+				t.Fatalf("UpsertFileMetadata failed: %v", err)
+			}
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+
+			if fmt.Sprint(target.deleted) != fmt.Sprint(tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", target.deleted, tt.wantDeleted)
+			}
+			if tt.wantTrash != "" && !bytes.Equal(target.uploaded[tt.wantTrash], []byte("old")) {
+				t.Errorf("expected trashed copy at %s, got %v", tt.wantTrash, target.uploaded)
+			}
+
+			meta, err := db.GetFileMetadata(mappingID, "gone.txt")
+			if err != nil {
+				t.Fatalf("GetFileMetadata failed: %v", err)
+			}
+			switch {
+			case tt.wantStatus == "" && meta != nil:
+				t.Errorf("expected metadata to be removed, got %+v", meta)
+			case tt.wantStatus != "" && (meta == nil || meta.SyncStatus != tt.wantStatus):
+				t.Errorf("expected status %s, got %+v", tt.wantStatus, meta)
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_MissingSourceBucket(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	source := &fakeSourceProvider{listErr: fmt.Errorf("error listing objects in bucket typo: %w", interfaces.ErrBucketNotFound)}
+	target := &fakeTargetProvider{
+		uploaded: map[string][]byte{"kept.txt": []byte("data")},
+		objects:  map[string]*interfaces.ObjectInfo{"kept.txt": {Name: "kept.txt", Size: 4}},
+	}
+	cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "typo", TargetProviderID: "tgt", TargetBucket: "tgt"}}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+	mappingID := "src:typo->tgt:tgt"
+	if err := db.UpsertFileMetadata(&database.FileMetadata{MappingID: mappingID, ObjectName: "kept.txt", Size: 4, LastModified: now, ETag: "e", LastSynced: now, SyncStatus: statusSuccess, Owned: true}); err != nil {
+		t.Fatalf("UpsertFileMetadata failed: %v", err)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger)
+	if !errors.Is(err, interfaces.ErrBucketNotFound) {
+		t.Fatalf("SyncBuckets error = %v, want ErrBucketNotFound", err)
+	}
+	if len(target.deleted) != 0 {
+		t.Errorf("missing source bucket deleted %v from the target", target.deleted)
+	}
+	if meta, err := db.GetFileMetadata(mappingID, "kept.txt"); err != nil || meta == nil {
+		t.Errorf("GetFileMetadata = %+v, %v; want the metadata kept", meta, err)
+	}
+}
+
+func TestSyncBuckets_MassDeleteGuard(t *testing.T) {
+	newTarget := func() *fakeTargetProvider {
+		objects := make(map[string]*interfaces.ObjectInfo)
+		for i := 0; i < 10; i++ {
+			name := fmt.Sprintf("obj-%d", i)
+			objects[name] = &interfaces.ObjectInfo{Name: name, Size: 1}
+		}
+		return &fakeTargetProvider{uploaded: make(map[string][]byte), objects: objects}
+	}
+	cfg := &config.Config{Mappings: []config.BucketMapping{{
+		SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt",
+		MaxDeletePercent: 50, AdoptExisting: true,
+	}}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	// Source lists empty: every target object would be deleted.
+	source := &fakeSourceProvider{objects: map[string]*interfaces.ObjectInfo{}, data: map[string][]byte{}}
+	target := newTarget()
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+	err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger)
+	if !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("expected ErrDeleteThresholdExceeded, got %v", err)
+	}
+	if len(target.deleted) != 0 {
+		t.Fatalf("expected no deletions, got %v", target.deleted)
+	}
+
+	plans, err := syncer.Plan(context.Background(), cfg.Mappings[0])
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if len(plans) != 1 {
+		t.Fatalf("expected one plan, got %d", len(plans))
+	}
+	plan := plans[0]
+	if len(plan.Warnings) != 1 {
+		t.Errorf("expected a threshold warning in the plan, got %v", plan.Warnings)
+	}
+
+	// An operator-confirmed run proceeds.
+	target = newTarget()
+	syncer, _ = newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target}, WithAllowMassDeletes(true))
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if len(target.deleted) != 10 {
+		t.Errorf("expected 10 deletions, got %d", len(target.deleted))
+	}
+}
+
+func TestSyncBuckets_DefaultDeleteGuard(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	newTarget := func() *fakeTargetProvider {
+		return &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+			"a.txt": {Name: "a.txt", Size: 1},
+			"b.txt": {Name: "b.txt", Size: 1},
+		}}
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	tests := []struct {
+		name        string
+		percent     float64
+		source      map[string]*interfaces.ObjectInfo
+		wantErr     bool
+		wantDeleted int
+	}{
+		{name: "empty source", source: map[string]*interfaces.ObjectInfo{}, wantErr: true},
+		{name: "empty source, no limit", percent: 100, source: map[string]*interfaces.ObjectInfo{}, wantDeleted: 2},
+		{name: "replaced objects", source: map[string]*interfaces.ObjectInfo{"c.txt": {Name: "c.txt", Size: 1, LastModified: now, ETag: "c"}}, wantDeleted: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSourceProvider{objects: tt.source, data: map[string][]byte{"c.txt": []byte("c")}}
+			target := newTarget()
+			mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt",
+				AdoptExisting: true, MaxDeletePercent: tt.percent}
+			syncer, _ := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}},
+				map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+			err := syncer.SyncBuckets(context.Background(), mapping, logger)
+			if tt.wantErr != errors.Is(err, ErrDeleteThresholdExceeded) {
+				t.Fatalf("SyncBuckets error = %v, want ErrDeleteThresholdExceeded %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			if len(target.deleted) != tt.wantDeleted {
+				t.Errorf("deleted = %v, want %d objects", target.deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_DeleteGuardIgnoresForeignObjects(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	source := &fakeSourceProvider{objects: map[string]*interfaces.ObjectInfo{}, data: map[string][]byte{}}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+		"a.txt":       {Name: "a.txt", Size: 1},
+		"b.txt":       {Name: "b.txt", Size: 1},
+		"foreign.txt": {Name: "foreign.txt", Size: 1}, // Uploaded by another team
+	}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt"}
+	syncer, db := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}},
+		map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := db.UpsertFileMetadata(&database.FileMetadata{MappingID: mappingKey(mapping), ObjectName: name, Size: 1, LastModified: now, ETag: "e", LastSynced: now, SyncStatus: statusSuccess, Owned: true}); err != nil {
+			t.Fatalf("UpsertFileMetadata failed: %v", err)
+		}
+	}
+
+	err := syncer.SyncBuckets(context.Background(), mapping, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if !errors.Is(err, ErrDeleteThresholdExceeded) {
+		t.Fatalf("SyncBuckets error = %v, want ErrDeleteThresholdExceeded", err)
+	}
+	if len(target.deleted) != 0 {
+		t.Errorf("deleted = %v, want nothing", target.deleted)
+	}
+}
+
+func TestSyncBuckets_DeletePercentOfManagedObjects(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"kept.txt": {Name: "kept.txt", Size: 1, LastModified: now, ETag: "k"}},
+		data:    map[string][]byte{"kept.txt": []byte("k")},
+	}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+		"kept.txt": {Name: "kept.txt", Size: 1},
+		"a.txt":    {Name: "a.txt", Size: 1},
+		"b.txt":    {Name: "b.txt", Size: 1},
+	}}
+	// Objects another team uploaded do not dilute the share of removals.
+	for i := range 5 {
+		name := fmt.Sprintf("foreign-%d.txt", i)
+		target.objects[name] = &interfaces.ObjectInfo{Name: name, Size: 1}
+	}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt", MaxDeletePercent: 50}
+	syncer, db := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}},
+		map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+	for _, name := range []string{"kept.txt", "a.txt", "b.txt"} {
+		if err := db.UpsertFileMetadata(&database.FileMetadata{MappingID: mappingKey(mapping), ObjectName: name, Size: 1, LastModified: now, ETag: "e", LastSynced: now, SyncStatus: statusSuccess, Owned: true}); err != nil {
+			t.Fatalf("UpsertFileMetadata failed: %v", err)
+		}
+	}
+
+	err := syncer.SyncBuckets(context.Background(), mapping, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if !errors.Is(err, ErrDeleteThresholdExceeded) || !strings.Contains(err.Error(), "66.7%") {
+		t.Fatalf("SyncBuckets error = %v, want 66.7%% of managed objects to exceed the threshold", err)
+	}
+	if len(target.deleted) != 0 {
+		t.Errorf("deleted = %v, want nothing", target.deleted)
+	}
+}
+
+func TestSyncBuckets_Filters(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	srcObjects := map[string]*interfaces.ObjectInfo{
+		"exports/2024/a.parquet": {Name: "exports/2024/a.parquet", Size: 1, LastModified: now, ETag: "a"},
+		"exports/2024/a.csv":     {Name: "exports/2024/a.csv", Size: 1, LastModified: now, ETag: "b"},
+		"tmp/exports/x.parquet":  {Name: "tmp/exports/x.parquet", Size: 1, LastModified: now, ETag: "c"},
+	}
+	source := &fakeSourceProvider{objects: srcObjects, data: map[string][]byte{}}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+		"manual/readme.txt":   {Name: "manual/readme.txt", Size: 1},
+		"exports/old.parquet": {Name: "exports/old.parquet", Size: 1},
+	}}
+	cfg := &config.Config{Mappings: []config.BucketMapping{{
+		SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt",
+		Include:       []filter.Rule{{Type: filter.Glob, Pattern: "**/*.parquet"}},
+		Exclude:       []filter.Rule{{Type: filter.Prefix, Pattern: "tmp/"}},
+		AdoptExisting: true,
+	}}}
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	if len(target.uploaded) != 1 {
+		t.Errorf("expected only exports/2024/a.parquet to be uploaded, got %v", target.uploaded)
+	}
+	if _, ok := target.uploaded["exports/2024/a.parquet"]; !ok {
+		t.Errorf("expected exports/2024/a.parquet to be uploaded")
+	}
+	if fmt.Sprint(target.deleted) != "[exports/old.parquet]" {
+		t.Errorf("expected only exports/old.parquet to be deleted, got %v", target.deleted)
+	}
+}
+
+func TestSyncBuckets_PrefixRemap(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	srcObjects := map[string]*interfaces.ObjectInfo{
+		"events/click.json": {Name: "events/click.json", Size: 5, LastModified: modified, ETag: "a"},
+		"other/skip.json":   {Name: "other/skip.json", Size: 4, LastModified: modified, ETag: "b"},
+	}
+	srcData := map[string][]byte{"events/click.json": []byte("click")}
+
+	tests := []struct {
+		name        string
+		keyTemplate string
+		wantKey     string
+	}{
+		{name: "prefix only", wantKey: "landing/events/click.json"},
+		{name: "template", keyTemplate: "{{.Year}}/{{.Month}}/{{.Key}}", wantKey: "landing/events/2024/05/click.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSourceProvider{objects: srcObjects, data: srcData}
+			target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{
+				"landing/events/stale.json": {Name: "landing/events/stale.json", Size: 1},
+				"elsewhere/keep.json":       {Name: "elsewhere/keep.json", Size: 1},
+			}}
+			cfg := &config.Config{Mappings: []config.BucketMapping{{
+				SourceProviderID: "src", SourceBucket: "raw", SourcePrefix: "events/",
+				TargetProviderID: "tgt", TargetBucket: "lake", TargetPrefix: "landing/events/",
+				KeyTemplate: tt.keyTemplate, AdoptExisting: true,
+			}}}
+			syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+
+			if len(target.uploaded) != 1 || !bytes.Equal(target.uploaded[tt.wantKey], []byte("click")) {
+				t.Errorf("expected a single upload to %s, got %v", tt.wantKey, target.uploaded)
+			}
+			if fmt.Sprint(target.deleted) != "[landing/events/stale.json]" {
+				t.Errorf("expected only the stale object under the target prefix to be deleted, got %v", target.deleted)
+			}
+
+			meta, err := db.GetFileMetadata("src:raw/events/->tgt:lake/landing/events/", "events/click.json")
+			if err != nil {
+				t.Fatalf("GetFileMetadata failed: %v", err)
+			}
+			if meta == nil || meta.TargetKey != tt.wantKey || meta.SyncStatus != statusSuccess {
+				t.Errorf("unexpected metadata: %+v", meta)
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_TargetKeyCollision(t *testing.T) {
+	modified := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{
+			"eu/report.csv": {Name: "eu/report.csv", Size: 2, LastModified: modified, ETag: "a"},
+			"us/report.csv": {Name: "us/report.csv", Size: 2, LastModified: modified, ETag: "b"},
+			"us/other.csv":  {Name: "us/other.csv", Size: 2, LastModified: modified, ETag: "c"},
+		},
+		data: map[string][]byte{"eu/report.csv": []byte("eu"), "us/report.csv": []byte("us"), "us/other.csv": []byte("ot")},
+	}
+	// A copy from an earlier run is kept rather than deleted as orphaned.
+	target := &fakeTargetProvider{uploaded: map[string][]byte{"2024/report.csv": []byte("eu")}, objects: map[string]*interfaces.ObjectInfo{
+		"2024/report.csv": {Name: "2024/report.csv", Size: 2},
+	}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "raw", TargetProviderID: "tgt", TargetBucket: "lake",
+		KeyTemplate: "{{.Year}}/{{.Base}}", AdoptExisting: true}
+	syncer, _ := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}},
+		map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+	plans, err := syncer.Plan(context.Background(), mapping)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if len(plans[0].Warnings) != 1 || !strings.Contains(plans[0].Warnings[0], "eu/report.csv, us/report.csv") {
+		t.Errorf("plan warnings = %v, want the colliding objects", plans[0].Warnings)
+	}
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if len(target.uploaded) != 2 || !bytes.Equal(target.uploaded["2024/other.csv"], []byte("ot")) || !bytes.Equal(target.uploaded["2024/report.csv"], []byte("eu")) {
+		t.Errorf("target holds %v, want only other.csv uploaded and report.csv untouched", target.uploaded)
+	}
+	if len(target.deleted) != 0 {
+		t.Errorf("deleted = %v, want nothing", target.deleted)
+	}
+	runs, err := syncer.db.ListSyncRuns(MappingID(mapping), "", 1)
+	if err != nil || len(runs) != 1 || runs[0].Status != database.RunStatusPartial || runs[0].Failed != 2 {
+		t.Errorf("ListSyncRuns = %+v, %v; want a partial run with the two colliding objects failed", runs, err)
+	}
+}
+
+// failingTargetProvider rejects every upload without reading the stream.
+type failingTargetProvider struct {
+	*fakeTargetProvider
+}
+
+func (f failingTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	return nil, errors.New("upload rejected")
+}
+
+func TestSyncBuckets_FanOut(t *testing.T) {
+	now := time.Now().UTC()
+	payload := bytes.Repeat([]byte("0123456789"), 10000) // Larger than a single copy buffer
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"big.bin": {Name: "big.bin", Size: int64(len(payload)), LastModified: now, ETag: "a"}},
+		data:    map[string][]byte{"big.bin": payload},
+	}
+	primary := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}
+	backup := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}
+	broken := failingTargetProvider{&fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}}
+
+	cfg := &config.Config{Mappings: []config.BucketMapping{{
+		SourceProviderID: "src", SourceBucket: "raw",
+		Targets: []config.MappingTarget{
+			{ProviderID: "primary", Bucket: "lake"},
+			{ProviderID: "backup", Bucket: "archive", Prefix: "raw/"},
+			{ProviderID: "broken", Bucket: "lake"},
+		},
+	}}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{
+		"src": source, "primary": primary, "backup": backup, "broken": broken,
+	})
+	syncer.failures.BaseDelay = 0 // Retry failed objects on the very next run
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	if got := source.gets.Load(); got != 1 {
+		t.Errorf("expected the source object to be read once, got %d reads", got)
+	}
+	if !bytes.Equal(primary.uploaded["big.bin"], payload) {
+		t.Errorf("primary target received %d bytes, want %d", len(primary.uploaded["big.bin"]), len(payload))
+	}
+	if !bytes.Equal(backup.uploaded["raw/big.bin"], payload) {
+		t.Errorf("backup target received %d bytes, want %d", len(backup.uploaded["raw/big.bin"]), len(payload))
+	}
+
+	wantStatus := map[string]string{
+		"src:raw->primary:lake":        statusSuccess,
+		"src:raw->backup:archive/raw/": statusSuccess,
+		"src:raw->broken:lake":         statusFailedUpload,
+	}
+	for mappingID, want := range wantStatus {
+		meta, err := db.GetFileMetadata(mappingID, "big.bin")
+		if err != nil {
+			t.Fatalf("GetFileMetadata failed: %v", err)
+		}
+		if meta == nil || meta.SyncStatus != want {
+			t.Errorf("%s: expected status %s, got %+v", mappingID, want, meta)
+		}
+	}
+
+	// Only the failed target is retried on the next run.
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("second SyncBuckets returned error: %v", err)
+	}
+	if got := source.gets.Load(); got != 2 {
+		t.Errorf("expected one more source read for the failed target, got %d reads in total", got)
+	}
+}
+
+func TestSyncBuckets_VerifiesChecksums(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"a.txt": {Name: "a.txt", Size: 5, LastModified: now, ETag: "a"}},
+		data:    map[string][]byte{"a.txt": []byte("hello")},
+	}
+	wantChecksum := "md5:5d41402abc4b2a76b9719d911017c592"
+
+	tests := []struct {
+		name       string
+		corrupt    bool
+		wantStatus string
+	}{
+		{name: "intact", wantStatus: statusSuccess},
+		{name: "corrupted", corrupt: true, wantStatus: statusFailedVerify},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}, corrupt: tt.corrupt}
+			cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt"}}}
+			syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+
+			meta, err := db.GetFileMetadata("src:src->tgt:tgt", "a.txt")
+			if err != nil {
+				t.Fatalf("GetFileMetadata failed: %v", err)
+			}
+			if meta == nil || meta.SyncStatus != tt.wantStatus || meta.Checksum != wantChecksum {
+				t.Errorf("expected status %s and checksum %s, got %+v", tt.wantStatus, wantChecksum, meta)
+			}
+		})
+	}
+}
+
+// flakyTargetProvider drops the connection on its first upload after reading
+// part of the stream.
+type flakyTargetProvider struct {
+	*fakeTargetProvider
+	attempts atomic.Int32
+}
+
+func (f *flakyTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	if f.attempts.Add(1) == 1 {
+		io.CopyN(io.Discard, reader, 1)
+		return nil, errors.New("read tcp: connection reset by peer")
+	}
+	return f.fakeTargetProvider.UploadObject(ctx, bucketName, objectName, reader, size, opts)
+}
+
+func TestSyncBuckets_RetriesUploadWithFreshSourceStream(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"a.txt": {Name: "a.txt", Size: 5, LastModified: now, ETag: "a"}},
+		data:    map[string][]byte{"a.txt": []byte("hello")},
+	}
+	flaky := &flakyTargetProvider{fakeTargetProvider: &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	target := retry.Wrap(flaky, retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond}, logger)
+
+	cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt"}}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	if got := source.gets.Load(); got != 2 {
+		t.Errorf("expected the source to be reopened once, got %d reads", got)
+	}
+	if !bytes.Equal(flaky.uploaded["a.txt"], []byte("hello")) {
+		t.Errorf("expected the complete object on the target, got %q", flaky.uploaded["a.txt"])
+	}
+	meta, err := db.GetFileMetadata("src:src->tgt:tgt", "a.txt")
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if meta == nil || meta.SyncStatus != statusSuccess {
+		t.Errorf("expected a successful sync, got %+v", meta)
+	}
+}
+
+// vanishingSourceProvider lists objects that are gone by the time they are read.
+type vanishingSourceProvider struct {
+	*fakeSourceProvider
+}
+
+func (f vanishingSourceProvider) GetObject(ctx context.Context, bucketName, objectName string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	return nil, nil, fmt.Errorf("error getting object %s: %w", objectName, interfaces.Classify(interfaces.ErrNotFound, errors.New("NoSuchKey")))
+}
+
+func TestSyncBuckets_SkipsObjectsRemovedDuringRun(t *testing.T) {
+	source := vanishingSourceProvider{&fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"gone.txt": {Name: "gone.txt", Size: 1, LastModified: time.Now().UTC(), ETag: "a"}},
+	}}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}
+	cfg := &config.Config{Mappings: []config.BucketMapping{{SourceProviderID: "src", SourceBucket: "src", TargetProviderID: "tgt", TargetBucket: "tgt"}}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "tgt": target})
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if err := syncer.SyncBuckets(context.Background(), cfg.Mappings[0], logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	meta, err := db.GetFileMetadata("src:src->tgt:tgt", "gone.txt")
+	if err != nil {
+		t.Fatalf("GetFileMetadata failed: %v", err)
+	}
+	if meta != nil {
+		t.Errorf("expected no failure to be recorded for a vanished object, got %+v", meta)
+	}
+}
+
+func TestSyncBuckets_BacksOffAndQuarantinesFailingObjects(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"bad.bin": {Name: "bad.bin", Size: 3, LastModified: now, ETag: "a"}},
+		data:    map[string][]byte{"bad.bin": []byte("bad")},
+	}
+	broken := failingTargetProvider{&fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb"}
+	cfg := &config.Config{
+		Failures: &config.FailureConfig{MaxAttempts: 3, BaseDelay: config.Duration(time.Hour)},
+		Mappings: []config.BucketMapping{mapping},
+	}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": broken})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mappingID := "src:sb->dst:tb"
+
+	run := func() *database.FileMetadata {
+		t.Helper()
+		if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+			t.Fatalf("SyncBuckets returned error: %v", err)
+		}
+		meta, err := db.GetFileMetadata(mappingID, "bad.bin")
+		if err != nil || meta == nil {
+			t.Fatalf("GetFileMetadata returned %+v, %v", meta, err)
+		}
+		return meta
+	}
+
+	meta := run()
+	if meta.SyncStatus != statusFailedUpload || meta.AttemptCount != 1 || meta.LastError != "upload rejected" {
+		t.Fatalf("expected a first failed attempt with its error, got %+v", meta)
+	}
+	if !meta.NextRetryAt.After(now.Add(59 * time.Minute)) {
+		t.Errorf("expected the next retry about an hour out, got %s", meta.NextRetryAt)
+	}
+
+	// The backoff has not elapsed, so the object is not read again.
+	run()
+	if got := source.gets.Load(); got != 1 {
+		t.Fatalf("expected the object to wait for its retry time, got %d reads", got)
+	}
+
+	syncer.failures.BaseDelay = 0
+	meta.NextRetryAt = time.Now().Add(-time.Second)
+	if err := db.UpsertFileMetadata(meta); err != nil {
+		t.Fatalf("UpsertFileMetadata failed: %v", err)
+	}
+	run()
+	meta = run()
+	if meta.SyncStatus != statusQuarantined || meta.AttemptCount != 3 {
+		t.Fatalf("expected the object quarantined after 3 attempts, got %+v", meta)
+	}
+
+	// Quarantined objects are skipped, and show up as such in plans.
+	run()
+	if got := source.gets.Load(); got != 3 {
+		t.Errorf("expected no read of a quarantined object, got %d reads", got)
+	}
+	plans, err := syncer.Plan(context.Background(), mapping)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if item := plans[0].Items[0]; item.Action != ActionSkip || item.Reason != ReasonQuarantined {
+		t.Errorf("expected a quarantined skip in the plan, got %+v", item)
+	}
+
+	if n, err := db.RequeueQuarantined(mappingID, "bad.bin"); err != nil || n != 1 {
+		t.Fatalf("RequeueQuarantined returned %d, %v", n, err)
+	}
+	meta = run()
+	if got := source.gets.Load(); got != 4 {
+		t.Errorf("expected a requeued object to be retried, got %d reads", got)
+	}
+	if meta.SyncStatus != statusFailedUpload || meta.AttemptCount != 1 {
+		t.Errorf("expected a requeued object to start counting again, got %+v", meta)
+	}
+}
+
+// multipartTargetProvider assembles objects from uploaded parts. The upload of
+// failPart fails once, as an interrupted transfer would.
+type multipartTargetProvider struct {
+	*fakeTargetProvider
+	failPart int
+	failed   bool
+	uploads  int                       // CreateMultipartUpload calls
+	parts    map[string]map[int][]byte // Parts by upload ID
+}
+
+func (f *multipartTargetProvider) CreateMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.uploads++
+	upload.UploadID = fmt.Sprintf("upload-%d", f.uploads)
+	if f.options == nil {
+		f.options = make(map[string]interfaces.UploadOptions)
+	}
+	f.options[upload.Key] = upload.Options
+	f.parts[upload.UploadID] = make(map[int][]byte)
+	return nil
+}
+func (f *multipartTargetProvider) UploadPart(ctx context.Context, upload *interfaces.MultipartUpload, number int, reader io.Reader, size int64) (*interfaces.CompletedPart, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if number == f.failPart && !f.failed {
+		f.failed = true
+		return nil, errors.New("read tcp: connection reset by peer")
+	}
+	f.parts[upload.UploadID][number] = data
+	sum := md5.Sum(data)
+	return &interfaces.CompletedPart{Number: number, ETag: fmt.Sprintf("etag-%d", number), Size: size, ContentMD5: hex.EncodeToString(sum[:])}, nil
+}
+func (f *multipartTargetProvider) CompleteMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload, parts []interfaces.CompletedPart) (*interfaces.UploadInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var content []byte
+	for _, part := range parts {
+		content = append(content, f.parts[upload.UploadID][part.Number]...)
+	}
+	f.uploaded[upload.Key] = content
+	return &interfaces.UploadInfo{Bucket: upload.Bucket, Key: upload.Key, Size: int64(len(content))}, nil
+}
+func (f *multipartTargetProvider) AbortMultipartUpload(ctx context.Context, upload *interfaces.MultipartUpload) error {
+	return nil
+}
+
+func TestSyncBuckets_ResumesMultipartUpload(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 5) // 50 bytes in 5 parts
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"big.bin": {Name: "big.bin", Size: int64(len(payload)), LastModified: time.Now().UTC(), ETag: "a"}},
+		data:    map[string][]byte{"big.bin": payload},
+	}
+	target := &multipartTargetProvider{
+		fakeTargetProvider: &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}},
+		failPart:           3,
+		parts:              make(map[string]map[int][]byte),
+	}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb"}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	syncer.multipart = multipartOptions{threshold: 20, partSize: 10}
+	syncer.failures.BaseDelay = 0
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	mappingID := "src:sb->dst:tb"
+
+	// The first run stops at the failing part, keeping the parts before it.
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	upload, err := db.GetMultipartUpload(mappingID, "big.bin")
+	if err != nil || upload == nil {
+		t.Fatalf("expected the unfinished upload to be recorded, got %+v, %v", upload, err)
+	}
+	if len(upload.Parts) != 2 {
+		t.Errorf("expected 2 recorded parts, got %+v", upload.Parts)
+	}
+
+	// The second run uploads only the parts that are missing.
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if got := source.ranges.Load(); got != 3+3 {
+		t.Errorf("expected 6 ranged reads, got %d", got)
+	}
+	if got := source.gets.Load(); got != 0 {
+		t.Errorf("expected no whole-object reads, got %d", got)
+	}
+	if target.uploads != 1 {
+		t.Errorf("expected the upload to be resumed, got %d uploads", target.uploads)
+	}
+	if !bytes.Equal(target.uploaded["big.bin"], payload) {
+		t.Errorf("expected the assembled object to match the source, got %q", target.uploaded["big.bin"])
+	}
+
+	meta, err := db.GetFileMetadata(mappingID, "big.bin")
+	if err != nil || meta == nil || meta.SyncStatus != statusSuccess {
+		t.Fatalf("expected a successful sync, got %+v, %v", meta, err)
+	}
+	if upload, err := db.GetMultipartUpload(mappingID, "big.bin"); err != nil || upload != nil {
+		t.Errorf("expected the completed upload to be forgotten, got %+v, %v", upload, err)
+	}
+}
+
+func TestSyncBuckets_MultipartUploadOptions(t *testing.T) {
+	now := time.Now().UTC()
+	payload := bytes.Repeat([]byte("0123456789"), 3)
+	listed := &interfaces.ObjectInfo{Name: "site.tar", Size: int64(len(payload)), LastModified: now, ETag: "a", ContentType: "application/x-tar"}
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"site.tar": listed},
+		data:    map[string][]byte{"site.tar": payload},
+		// Headers and metadata that only a HEAD request reports.
+		heads: map[string]*interfaces.ObjectInfo{"site.tar": {Name: "site.tar", Size: listed.Size, LastModified: now, ETag: "a",
+			ContentType: "application/octet-stream", ContentEncoding: "gzip", CacheControl: "no-cache", Metadata: map[string]string{"owner": "web"}}},
+	}
+	target := &multipartTargetProvider{
+		fakeTargetProvider: &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}},
+		parts:              make(map[string]map[int][]byte),
+	}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb"}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	syncer.multipart = multipartOptions{threshold: 20, partSize: 10}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if target.uploads != 1 || !bytes.Equal(target.uploaded["site.tar"], payload) {
+		t.Fatalf("expected the object to be uploaded in parts, got %d uploads", target.uploads)
+	}
+	opts := target.options["site.tar"]
+	if opts.ContentType != "application/x-tar" || opts.ContentEncoding != "gzip" || opts.CacheControl != "no-cache" || opts.Metadata["owner"] != "web" {
+		t.Errorf("unexpected upload options: %+v", opts)
+	}
+}
+
+func TestSyncBuckets_ThrottlesUploads(t *testing.T) {
+	payload := make([]byte, 64<<10)
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"a.bin": {Name: "a.bin", Size: int64(len(payload)), LastModified: time.Now().UTC(), ETag: "a"}},
+		data:    map[string][]byte{"a.bin": payload},
+	}
+	target := &fakeTargetProvider{uploaded: make(map[string][]byte), objects: map[string]*interfaces.ObjectInfo{}}
+	// The first 32 KiB go out at once, the rest at the mapping's rate.
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", MaxBytesPerSecond: 128 << 10}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	start := time.Now()
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the upload to be throttled, finished in %s", elapsed)
+	}
+	if !bytes.Equal(target.uploaded["a.bin"], payload) {
+		t.Errorf("expected the complete object on the target, got %d bytes", len(target.uploaded["a.bin"]))
+	}
+}
+
+func TestSyncAll_RecordsRunHistory(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{
+			"a.txt": {Name: "a.txt", Size: 5, LastModified: now, ETag: "a"},
+			"b.txt": {Name: "b.txt", Size: 3, LastModified: now, ETag: "b"},
+		},
+		data: map[string][]byte{"a.txt": []byte("hello"), "b.txt": []byte("bye")},
+	}
+	target := &fakeTargetProvider{
+		uploaded: make(map[string][]byte),
+		objects:  map[string]*interfaces.ObjectInfo{"old.txt": {Name: "old.txt", Size: 1, LastModified: now}},
+	}
+	good := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", AdoptExisting: true}
+	missing := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "gone", TargetBucket: "tb"}
+	cfg := &config.Config{Mappings: []config.BucketMapping{missing, good}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+
+	err := syncer.SyncAll(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "src:sb->gone:tb") {
+		t.Fatalf("expected the error of the failing mapping, got %v", err)
+	}
+	if len(target.uploaded) != 2 {
+		t.Errorf("expected the other mapping to be synchronized, got %d uploads", len(target.uploaded))
+	}
+
+	runs, err := db.ListSyncRuns("src:sb->dst:tb", "", 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListSyncRuns returned %+v, %v", runs, err)
+	}
+	if run := runs[0]; run.Status != database.RunStatusSuccess || run.Synced != 2 || run.Deleted != 1 ||
+		run.BytesTransferred != 8 || run.FinishedAt.IsZero() {
+		t.Errorf("unexpected run record: %+v", run)
+	}
+
+	runs, err = db.ListSyncRuns("src:sb->gone:tb", "", 0)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("ListSyncRuns returned %+v, %v", runs, err)
+	}
+	if run := runs[0]; run.Status != database.RunStatusFailed || run.Error == "" {
+		t.Errorf("expected a failed run with its error, got %+v", run)
+	}
+}
+
+func TestSyncBuckets_DeletesOnlyOwnedObjects(t *testing.T) {
+	now := time.Now().UTC()
+	for _, adopt := range []bool{false, true} {
+		t.Run(fmt.Sprintf("adoptExisting=%t", adopt), func(t *testing.T) {
+			source := &fakeSourceProvider{
+				objects: map[string]*interfaces.ObjectInfo{"mine.txt": {Name: "mine.txt", Size: 4, LastModified: now, ETag: "a"}},
+				data:    map[string][]byte{"mine.txt": []byte("mine")},
+			}
+			target := &fakeTargetProvider{
+				uploaded: map[string][]byte{"theirs.txt": []byte("theirs")},
+				objects:  map[string]*interfaces.ObjectInfo{"theirs.txt": {Name: "theirs.txt", Size: 6, LastModified: now}},
+			}
+			mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", AdoptExisting: adopt}
+			cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+			syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+
+			// Once the object is gone from the source, the copy this mapping
+			// wrote is removed; the object uploaded by someone else only when
+			// existing objects are adopted.
+			target.objects = map[string]*interfaces.ObjectInfo{
+				"mine.txt":   {Name: "mine.txt", Size: 4, LastModified: now},
+				"theirs.txt": {Name: "theirs.txt", Size: 6, LastModified: now},
+			}
+			// A new object keeps the source from listing empty, which the
+			// default deletion guard would refuse.
+			source.objects = map[string]*interfaces.ObjectInfo{"new.txt": {Name: "new.txt", Size: 3, LastModified: now, ETag: "b"}}
+			source.data["new.txt"] = []byte("new")
+			target.deleted = nil
+			if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+
+			want := "[mine.txt]"
+			if adopt {
+				want = "[mine.txt theirs.txt]"
+			}
+			if got := fmt.Sprint(target.deleted); got != want {
+				t.Errorf("deleted = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_TargetDrift(t *testing.T) {
+	now := time.Now().UTC()
+	const etag = "8d777f385d3dfec8815d20f7496026dc" // MD5 of "data"
+
+	tests := []struct {
+		name       string
+		policy     config.DriftPolicy
+		listing    map[string]*interfaces.ObjectInfo // Target listing on the second run
+		wantReason string
+		wantUpload bool
+	}{
+		{"intact", config.DriftPolicyRepair, map[string]*interfaces.ObjectInfo{"f.txt": {Name: "f.txt", Size: 4, ETag: etag}}, ReasonUnchanged, false},
+		{"missing, report", "", map[string]*interfaces.ObjectInfo{}, ReasonTargetMissing, false},
+		{"missing, repair", config.DriftPolicyRepair, map[string]*interfaces.ObjectInfo{}, ReasonTargetMissing, true},
+		{"overwritten, repair", config.DriftPolicyRepair, map[string]*interfaces.ObjectInfo{"f.txt": {Name: "f.txt", Size: 4, ETag: `"other"`}}, ReasonTargetChanged, true},
+		{"resized, report", config.DriftPolicyReport, map[string]*interfaces.ObjectInfo{"f.txt": {Name: "f.txt", Size: 9, ETag: etag}}, ReasonTargetChanged, false},
+		{"missing, ignore", config.DriftPolicyIgnore, map[string]*interfaces.ObjectInfo{}, ReasonUnchanged, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &fakeSourceProvider{
+				objects: map[string]*interfaces.ObjectInfo{"f.txt": {Name: "f.txt", Size: 4, LastModified: now, ETag: "a"}},
+				data:    map[string][]byte{"f.txt": []byte("data")},
+			}
+			target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+			mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", DriftPolicy: tt.policy}
+			cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+			syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+			if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			stored, err := db.GetFileMetadata(mappingKey(mapping), "f.txt")
+			if err != nil || stored == nil || stored.TargetETag != `"`+etag+`"` || stored.TargetSize != 4 {
+				t.Fatalf("expected the target ETag and size to be recorded, got %+v, %v", stored, err)
+			}
+
+			target.objects = tt.listing
+			plans, err := syncer.Plan(context.Background(), mapping)
+			if err != nil {
+				t.Fatalf("Plan returned error: %v", err)
+			}
+			if item := plans[0].Items[0]; item.Reason != tt.wantReason || (item.Action == ActionUpload) != tt.wantUpload {
+				t.Errorf("planned %s (%s), want reason %s, upload %t", item.Action, item.Reason, tt.wantReason, tt.wantUpload)
+			}
+
+			source.gets.Store(0)
+			if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			if uploaded := source.gets.Load() > 0; uploaded != tt.wantUpload {
+				t.Errorf("uploaded again = %t, want %t", uploaded, tt.wantUpload)
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_PropagatesMetadata(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{
+			"app.js": {Name: "app.js", Size: 4, LastModified: now, ETag: "a", ContentType: "text/javascript",
+				ContentEncoding: "gzip", CacheControl: "max-age=3600", Metadata: map[string]string{"owner": "web"}},
+			"plain.txt": {Name: "plain.txt", Size: 4, LastModified: now, ETag: "b", ContentType: "text/plain"},
+		},
+		data: map[string][]byte{"app.js": []byte("code"), "plain.txt": []byte("text")},
+	}
+	target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb",
+		DriftPolicy: config.DriftPolicyIgnore}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	opts := target.options["app.js"]
+	if opts.ContentType != "text/javascript" || opts.ContentEncoding != "gzip" || opts.CacheControl != "max-age=3600" || opts.Metadata["owner"] != "web" {
+		t.Errorf("unexpected upload options: %+v", opts)
+	}
+
+	// Rows recorded before metadata was tracked only carried the content type.
+	legacy, err := db.GetFileMetadata(mappingKey(mapping), "plain.txt")
+	if err != nil || legacy == nil {
+		t.Fatalf("expected metadata for plain.txt, got %v", err)
+	}
+	legacy.MetadataHash = ""
+	if err := db.UpsertFileMetadata(legacy); err != nil {
+		t.Fatalf("UpsertFileMetadata returned error: %v", err)
+	}
+
+	source.objects["app.js"].Metadata = map[string]string{"owner": "ops"}
+	plans, err := syncer.Plan(context.Background(), mapping)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	reasons := make(map[string]string)
+	for _, item := range plans[0].Items {
+		reasons[item.Object] = item.Reason
+	}
+	if reasons["app.js"] != ReasonMetadataChanged || reasons["plain.txt"] != ReasonUnchanged {
+		t.Errorf("planned reasons = %v, want app.js %s and plain.txt %s", reasons, ReasonMetadataChanged, ReasonUnchanged)
+	}
+
+	source.gets.Store(0)
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if got := source.gets.Load(); got != 1 {
+		t.Errorf("expected only app.js to be uploaded again, got %d reads", got)
+	}
+	if owner := target.options["app.js"].Metadata["owner"]; owner != "ops" {
+		t.Errorf("owner metadata on target = %q, want ops", owner)
+	}
+}
+
+// taggedSourceProvider is a source whose objects have tags.
+type taggedSourceProvider struct {
+	*fakeSourceProvider
+	tags map[string]map[string]string
+}
+
+func (f *taggedSourceProvider) GetObjectTags(ctx context.Context, bucketName, objectName string) (map[string]string, error) {
+	return f.tags[objectName], nil
+}
+
+func TestSyncBuckets_ReplicatesTags(t *testing.T) {
+	now := time.Now().UTC()
+	source := &taggedSourceProvider{
+		fakeSourceProvider: &fakeSourceProvider{
+			objects: map[string]*interfaces.ObjectInfo{
+				"a.csv": {Name: "a.csv", Size: 1, LastModified: now, ETag: "a"},
+				"b.csv": {Name: "b.csv", Size: 1, LastModified: now, ETag: "b"},
+			},
+			data: map[string][]byte{"a.csv": []byte("a"), "b.csv": []byte("b")},
+		},
+		tags: map[string]map[string]string{"a.csv": {"tier": "cold", "origin": "s3"}},
+	}
+
+	tests := []struct {
+		name      string
+		replicate bool
+		static    map[string]string
+		want      map[string]map[string]string
+	}{
+		{"off", false, nil, map[string]map[string]string{"a.csv": nil, "b.csv": nil}},
+		{"replicate", true, nil, map[string]map[string]string{"a.csv": {"tier": "cold", "origin": "s3"}, "b.csv": nil}},
+		{"static", false, map[string]string{"replica": "true"}, map[string]map[string]string{
+			"a.csv": {"replica": "true"}, "b.csv": {"replica": "true"}}},
+		{"static wins", true, map[string]string{"origin": "sync"}, map[string]map[string]string{
+			"a.csv": {"tier": "cold", "origin": "sync"}, "b.csv": {"origin": "sync"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+			mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb",
+				ReplicateTags: tt.replicate, Tags: tt.static}
+			cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+			syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+
+			if err := syncer.SyncBuckets(context.Background(), mapping, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := target.options[name].Tags; fmt.Sprint(got) != fmt.Sprint(want) {
+					t.Errorf("tags of %s = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_ResyncsChangedTags(t *testing.T) {
+	now := time.Now().UTC()
+	source := &taggedSourceProvider{
+		fakeSourceProvider: &fakeSourceProvider{
+			objects: map[string]*interfaces.ObjectInfo{
+				"a.csv": {Name: "a.csv", Size: 1, LastModified: now, ETag: "a"},
+				"b.csv": {Name: "b.csv", Size: 1, LastModified: now, ETag: "b"},
+			},
+			data: map[string][]byte{"a.csv": []byte("a"), "b.csv": []byte("b")},
+		},
+		tags: map[string]map[string]string{"a.csv": {"tier": "hot"}},
+	}
+	target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb",
+		ReplicateTags: true, DriftPolicy: config.DriftPolicyIgnore}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		change  func()
+		want    map[string]string // Planned reason by object
+		wantTag map[string]string // Tags written to a.csv
+	}{
+		{
+			name:    "source tags",
+			change:  func() { source.tags["a.csv"] = map[string]string{"tier": "cold"} },
+			want:    map[string]string{"a.csv": ReasonTagsChanged, "b.csv": ReasonUnchanged},
+			wantTag: map[string]string{"tier": "cold"},
+		},
+		{
+			name:    "static tags",
+			change:  func() { mapping.Tags = map[string]string{"origin": "sync"} },
+			want:    map[string]string{"a.csv": ReasonTagsChanged, "b.csv": ReasonTagsChanged},
+			wantTag: map[string]string{"origin": "sync", "tier": "cold"},
+		},
+		{
+			name:    "unchanged",
+			change:  func() {},
+			want:    map[string]string{"a.csv": ReasonUnchanged, "b.csv": ReasonUnchanged},
+			wantTag: map[string]string{"origin": "sync", "tier": "cold"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.change()
+			plans, err := syncer.Plan(context.Background(), mapping)
+			if err != nil {
+				t.Fatalf("Plan returned error: %v", err)
+			}
+			reasons := make(map[string]string)
+			for _, item := range plans[0].Items {
+				reasons[item.Object] = item.Reason
+			}
+			if fmt.Sprint(reasons) != fmt.Sprint(tt.want) {
+				t.Errorf("planned reasons = %v, want %v", reasons, tt.want)
+			}
+
+			if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			if got := target.options["a.csv"].Tags; fmt.Sprint(got) != fmt.Sprint(tt.wantTag) {
+				t.Errorf("tags of a.csv = %v, want %v", got, tt.wantTag)
+			}
+		})
+	}
+}
+
+func TestMergeTags(t *testing.T) {
+	source := make(map[string]string)
+	for i := range 12 {
+		source[fmt.Sprintf("s%02d", i)] = "x"
+	}
+	tags, dropped := mergeTags(source, map[string]string{"s00": "static", "replica": "true"})
+	if len(tags) != config.MaxObjectTags || tags["s00"] != "static" || tags["replica"] != "true" {
+		t.Errorf("merged tags = %v, want %d tags including the static ones", tags, config.MaxObjectTags)
+	}
+	if want := []string{"s09", "s10", "s11"}; fmt.Sprint(dropped) != fmt.Sprint(want) {
+		t.Errorf("dropped = %v, want %v", dropped, want)
+	}
+
+	if tags, dropped := mergeTags(nil, nil); tags != nil || dropped != nil {
+		t.Errorf("mergeTags(nil, nil) = %v, %v, want nil", tags, dropped)
+	}
+}
+
+func TestSyncBuckets_StorageClass(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{
+			"a.csv": {Name: "a.csv", Size: 1, LastModified: now, ETag: "a", StorageClass: "NEARLINE"},
+			"b.csv": {Name: "b.csv", Size: 1, LastModified: now, ETag: "b", StorageClass: "custom-tier"},
+			"c.csv": {Name: "c.csv", Size: 1, LastModified: now, ETag: "c"},
+		},
+		data: map[string][]byte{"a.csv": []byte("a"), "b.csv": []byte("b"), "c.csv": []byte("c")},
+	}
+
+	tests := []struct {
+		name  string
+		class string
+		want  map[string]string
+	}{
+		{"default", "", map[string]string{"a.csv": "", "b.csv": "", "c.csv": ""}},
+		{"fixed", "GLACIER_IR", map[string]string{"a.csv": "GLACIER_IR", "b.csv": "GLACIER_IR", "c.csv": "GLACIER_IR"}},
+		{"preserve", storageclass.Preserve, map[string]string{"a.csv": "NEARLINE", "b.csv": "", "c.csv": ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+			mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb",
+				StorageClass: tt.class}
+			cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+			syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+
+			if err := syncer.SyncBuckets(context.Background(), mapping, slog.New(slog.NewTextHandler(io.Discard, nil))); err != nil {
+				t.Fatalf("SyncBuckets returned error: %v", err)
+			}
+			for name, want := range tt.want {
+				if got := target.options[name].StorageClass; got != want {
+					t.Errorf("storage class of %s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSyncBuckets_Encryption(t *testing.T) {
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"a.csv": {Name: "a.csv", Size: 1, LastModified: now, ETag: "a"}},
+		data:    map[string][]byte{"a.csv": []byte("a")},
+	}
+	target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb",
+		DriftPolicy: config.DriftPolicyIgnore}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	if enc := target.options["a.csv"].Encryption; enc != nil {
+		t.Errorf("upload without encryption settings used %+v, want the provider default", enc)
+	}
+
+	// Requiring KMS encryption rewrites the copy made without it, once.
+	mapping.Encryption = &config.EncryptionConfig{Mode: interfaces.EncryptionKMS, KMSKeyID: "replicas"}
+	plans, err := syncer.Plan(context.Background(), mapping)
+	if err != nil {
+		t.Fatalf("Plan returned error: %v", err)
+	}
+	if items := plans[0].Items; len(items) != 1 || items[0].Reason != ReasonEncryptionChanged {
+		t.Errorf("plan items = %+v, want one upload for %s", items, ReasonEncryptionChanged)
+	}
+	for run := range 2 {
+		target.uploaded = map[string][]byte{}
+		if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+			t.Fatalf("SyncBuckets returned error: %v", err)
+		}
+		if _, uploaded := target.uploaded["a.csv"]; uploaded != (run == 0) {
+			t.Errorf("run %d uploaded the object: %v, want %v", run, uploaded, run == 0)
+		}
+	}
+	if enc := target.options["a.csv"].Encryption; enc == nil || enc.Mode != interfaces.EncryptionKMS || enc.KeyID != "replicas" {
+		t.Errorf("upload encryption = %+v, want KMS key replicas", enc)
+	}
+	stored, err := db.GetFileMetadata(mappingKey(mapping), "a.csv")
+	if err != nil || stored == nil || stored.Encryption != string(interfaces.EncryptionKMS) {
+		t.Errorf("stored metadata = %+v (error %v), want encryption kms", stored, err)
+	}
+}
+
+func TestSyncBuckets_ClientEncryption(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "backup.key")
+	if err := os.WriteFile(keyFile, bytes.Repeat([]byte{7}, envelope.KeySize), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	content := bytes.Repeat([]byte("0123456789"), envelope.ChunkSize/4)
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"db.dump": {Name: "db.dump", Size: int64(len(content)), LastModified: now, ETag: "v1",
+			Metadata: map[string]string{"owner": "ops"}}},
+		data: map[string][]byte{"db.dump": content},
+	}
+	backup := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	restored := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	encrypt := config.BucketMapping{SourceProviderID: "src", SourceBucket: "data", TargetProviderID: "backup", TargetBucket: "vault",
+		ClientEncryption: &config.ClientEncryptionConfig{Mode: config.ClientEncryptionEncrypt, KeyFile: keyFile}}
+	cfg := &config.Config{Mappings: []config.BucketMapping{encrypt}}
+	syncer, _ := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "backup": backup, "restore": restored})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), encrypt, logger); err != nil {
+		t.Fatalf("SyncBuckets (encrypt) returned error: %v", err)
+	}
+	ciphertext := backup.uploaded["db.dump"]
+	if int64(len(ciphertext)) != envelope.EncryptedSize(int64(len(content))) || bytes.Contains(ciphertext, content[:100]) {
+		t.Fatalf("backup holds %d bytes, want %d encrypted bytes", len(ciphertext), envelope.EncryptedSize(int64(len(content))))
+	}
+	metadata := backup.options["db.dump"].Metadata
+	if !envelope.Encrypted(metadata) || metadata["owner"] != "ops" {
+		t.Errorf("backup metadata = %v, want the envelope and the source metadata", metadata)
+	}
+
+	// Restoring reads the backup bucket as the source of a decrypting mapping.
+	backupSource := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"db.dump": {Name: "db.dump", Size: int64(len(ciphertext)), LastModified: now, ETag: "b1", Metadata: metadata}},
+		data:    map[string][]byte{"db.dump": ciphertext},
+	}
+	decrypt := config.BucketMapping{SourceProviderID: "backup", SourceBucket: "vault", TargetProviderID: "restore", TargetBucket: "data",
+		ClientEncryption: &config.ClientEncryptionConfig{Mode: config.ClientEncryptionDecrypt, KeyFile: keyFile}}
+	syncer, _ = newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{decrypt}},
+		map[string]interfaces.StorageProvider{"backup": backupSource, "restore": restored})
+	if err := syncer.SyncBuckets(context.Background(), decrypt, logger); err != nil {
+		t.Fatalf("SyncBuckets (decrypt) returned error: %v", err)
+	}
+	if !bytes.Equal(restored.uploaded["db.dump"], content) {
+		t.Errorf("restored %d bytes differing from the original %d", len(restored.uploaded["db.dump"]), len(content))
+	}
+	if got := restored.options["db.dump"].Metadata; len(got) != 1 || got["owner"] != "ops" {
+		t.Errorf("restored metadata = %v, want only owner", got)
+	}
+}
+
+// sizedTargetProvider needs the size of uploads in advance and records it.
+type sizedTargetProvider struct {
+	*fakeTargetProvider
+	sizes map[string]int64
+}
+
+func (f *sizedTargetProvider) RequiresSize() bool { return true }
+func (f *sizedTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	f.mu.Lock()
+	f.sizes[objectName] = size
+	f.mu.Unlock()
+	return f.fakeTargetProvider.UploadObject(ctx, bucketName, objectName, reader, size, opts)
+}
+
+// upperTransform is a registered transform type writing content in upper
+// case under an .upper key.
+type upperTransform struct{}
+
+func (upperTransform) Key(key string) string { return key + ".upper" }
+func (upperTransform) Transform(obj TransformObject) (TransformObject, error) {
+	data, err := io.ReadAll(obj.Reader)
+	if err != nil {
+		return obj, err
+	}
+	obj.Reader = bytes.NewReader(bytes.ToUpper(data))
+	return obj, nil
+}
+
+func TestSyncBuckets_Transforms(t *testing.T) {
+	content := []byte(strings.Repeat(`{"event":"login","user":"alice"}`+"\n", 1000))
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"events.json": {Name: "events.json", Size: int64(len(content)), LastModified: now, ETag: "e1", ContentType: "application/json"}},
+		data:    map[string][]byte{"events.json": content},
+	}
+	streamed := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	sized := &sizedTargetProvider{fakeTargetProvider: &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}, sizes: map[string]int64{}}
+	restored := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	providers := map[string]interfaces.StorageProvider{"src": source, "streamed": streamed, "sized": sized, "restore": restored}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	for _, typ := range []string{config.TransformGzip, config.TransformZstd} {
+		t.Run(typ, func(t *testing.T) {
+			compress := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb",
+				Targets:    []config.MappingTarget{{ProviderID: "streamed", Bucket: "a"}, {ProviderID: "sized", Bucket: "b"}},
+				Transforms: []config.TransformConfig{{Type: typ}}}
+			syncer, _ := newTestSynchronizer(t, &config.Config{SpoolDir: t.TempDir(), Mappings: []config.BucketMapping{compress}}, providers)
+			if err := syncer.SyncBuckets(context.Background(), compress, logger); err != nil {
+				t.Fatalf("SyncBuckets (compress) returned error: %v", err)
+			}
+
+			key := "events.json" + codecs[typ].ext
+			compressed := streamed.uploaded[key]
+			if len(compressed) == 0 || len(compressed) >= len(content) || !bytes.Equal(sized.uploaded[key], compressed) {
+				t.Fatalf("uploaded %d and %d bytes under %s, want the same compressed copy of %d bytes",
+					len(compressed), len(sized.uploaded[key]), key, len(content))
+			}
+			if got := streamed.options[key].ContentType; got != codecs[typ].contentType {
+				t.Errorf("compressed content type = %q, want %q", got, codecs[typ].contentType)
+			}
+			// The size is unknown after compression; only the target that
+			// needs it gets the size of the spooled copy.
+			if got := sized.sizes[key]; got != int64(len(compressed)) {
+				t.Errorf("size passed to the sized target = %d, want %d", got, len(compressed))
+			}
+			if entries, _ := os.ReadDir(syncer.config.SpoolDir); len(entries) != 0 {
+				t.Errorf("spool directory holds %d files after the run, want none", len(entries))
+			}
+
+			// Restoring decompresses the copies back under their original key.
+			compressedSource := &fakeSourceProvider{
+				objects: map[string]*interfaces.ObjectInfo{key: {Name: key, Size: int64(len(compressed)), LastModified: now, ETag: "c1",
+					ContentType: codecs[typ].contentType}},
+				data: map[string][]byte{key: compressed},
+			}
+			decompress := config.BucketMapping{SourceProviderID: "backup", SourceBucket: "a", TargetProviderID: "restore", TargetBucket: "r",
+				Transforms: []config.TransformConfig{{Type: typ, Mode: config.TransformDecompress}}}
+			syncer, _ = newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{decompress}},
+				map[string]interfaces.StorageProvider{"backup": compressedSource, "restore": restored})
+			if err := syncer.SyncBuckets(context.Background(), decompress, logger); err != nil {
+				t.Fatalf("SyncBuckets (decompress) returned error: %v", err)
+			}
+			if !bytes.Equal(restored.uploaded["events.json"], content) {
+				t.Errorf("restored %d bytes differing from the original %d", len(restored.uploaded["events.json"]), len(content))
+			}
+			if got := restored.options["events.json"].ContentType; got != "application/json" {
+				t.Errorf("restored content type = %q, want application/json", got)
+			}
+		})
+	}
+
+	t.Run("registered type", func(t *testing.T) {
+		mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "streamed", TargetBucket: "a",
+			Transforms: []config.TransformConfig{{Type: "upper"}}}
+		syncer, _ := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}}, providers,
+			WithTransform("upper", func(config.TransformConfig) (Transformer, error) { return upperTransform{}, nil }))
+		if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+			t.Fatalf("SyncBuckets returned error: %v", err)
+		}
+		if got := streamed.uploaded["events.json.upper"]; !bytes.Equal(got, bytes.ToUpper(content)) {
+			t.Errorf("uploaded %.40q..., want the content in upper case", got)
+		}
+
+		mapping.Transforms[0].Type = "lower"
+		if err := syncer.SyncBuckets(context.Background(), mapping, logger); err == nil {
+			t.Error("SyncBuckets with an unregistered transform type succeeded")
+		}
+	})
+}
+
+// versionedSourceProvider is a source whose bucket keeps object versions.
+type versionedSourceProvider struct {
+	*fakeSourceProvider
+	versions map[string][]*interfaces.ObjectVersion
+	content  map[string][]byte // By version ID
+}
+
+func (f *versionedSourceProvider) ListObjectVersions(ctx context.Context, bucketName, prefix string) (map[string][]*interfaces.ObjectVersion, error) {
+	return f.versions, nil
+}
+func (f *versionedSourceProvider) GetObjectVersion(ctx context.Context, bucketName, objectName, versionID string) (*interfaces.ObjectInfo, io.ReadCloser, error) {
+	return &interfaces.ObjectInfo{Name: objectName, ContentType: "text/plain"}, io.NopCloser(bytes.NewReader(f.content[versionID])), nil
+}
+
+// historyTargetProvider records every write and delete in order.
+type historyTargetProvider struct {
+	*fakeTargetProvider
+	events map[string][]string // By key
+}
+
+func (f *historyTargetProvider) UploadObject(ctx context.Context, bucketName, objectName string, reader io.Reader, size int64, opts interfaces.UploadOptions) (*interfaces.UploadInfo, error) {
+	info, err := f.fakeTargetProvider.UploadObject(ctx, bucketName, objectName, reader, size, opts)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events[objectName] = append(f.events[objectName], "put "+string(f.uploaded[objectName]))
+	return info, err
+}
+func (f *historyTargetProvider) DeleteObject(ctx context.Context, bucketName, objectName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.events[objectName] = append(f.events[objectName], "delete")
+	return nil
+}
+
+func TestSyncBuckets_Hooks(t *testing.T) {
+	var mu gosync.Mutex
+	var events []hooks.Payload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var p hooks.Payload
+		if err := json.NewDecoder(req.Body).Decode(&p); err != nil {
+			t.Errorf("invalid hook payload: %v", err)
+		}
+		mu.Lock()
+		events = append(events, p)
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	now := time.Now().UTC()
+	source := &fakeSourceProvider{
+		objects: map[string]*interfaces.ObjectInfo{"a.csv": {Name: "a.csv", Size: 3, LastModified: now, ETag: "v1"}},
+		data:    map[string][]byte{"a.csv": []byte("a,b")},
+	}
+	target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	webhook := []hooks.Hook{{URL: server.URL}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "in", TargetProviderID: "dst", TargetBucket: "out",
+		Hooks: &hooks.Config{BeforeRun: []hooks.Hook{{Command: []string{"true"}}}, AfterUpload: webhook}}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping},
+		Hooks: &hooks.Config{BeforeRun: webhook, AfterRun: webhook, OnFailure: webhook}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+		t.Fatalf("SyncBuckets returned error: %v", err)
+	}
+	var got []hooks.Event
+	for _, p := range events {
+		got = append(got, p.Event)
+	}
+	if want := []hooks.Event{hooks.BeforeRun, hooks.AfterUpload, hooks.AfterRun}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("hook events = %v, want %v", got, want)
+	}
+	if object := events[1].Object; object == nil || object.Key != "a.csv" || object.TargetBucket != "out" || object.Size != 3 {
+		t.Errorf("afterUpload object = %+v", object)
+	}
+	if run := events[2].Run; run == nil || run.Status != database.RunStatusSuccess || run.Synced != 1 || run.FinishedAt == nil || run.ID == 0 {
+		t.Errorf("afterRun run = %+v", run)
+	}
+
+	// A failing beforeRun hook vetoes the run: nothing is copied and only
+	// the onFailure hooks run afterwards.
+	events = nil
+	source.objects["b.csv"] = &interfaces.ObjectInfo{Name: "b.csv", Size: 1, LastModified: now, ETag: "v1"}
+	source.data["b.csv"] = []byte("b")
+	mapping.Hooks.BeforeRun = []hooks.Hook{{Command: []string{"sh", "-c", "echo source not ready >&2; exit 1"}}}
+	err := syncer.SyncBuckets(context.Background(), mapping, logger)
+	if !errors.Is(err, ErrRunVetoed) || !strings.Contains(err.Error(), "source not ready") {
+		t.Fatalf("SyncBuckets error = %v, want ErrRunVetoed with the hook's output", err)
+	}
+	if _, ok := target.uploaded["b.csv"]; ok {
+		t.Error("vetoed run uploaded b.csv")
+	}
+	got = nil
+	for _, p := range events {
+		got = append(got, p.Event)
+	}
+	if want := []hooks.Event{hooks.BeforeRun, hooks.OnFailure}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("hook events of the vetoed run = %v, want %v", got, want)
+	}
+	if p := events[1]; p.Run == nil || p.Run.Status != database.RunStatusFailed || !strings.Contains(p.Error, "vetoed") {
+		t.Errorf("onFailure payload = %+v", p)
+	}
+	runs, err := db.ListSyncRuns(mappingKey(mapping), database.RunStatusFailed, 0)
+	if err != nil || len(runs) != 1 {
+		t.Errorf("ListSyncRuns(failed) = %d runs, %v; want the vetoed run", len(runs), err)
+	}
+}
+
+func TestSyncBuckets_ReplicatesVersions(t *testing.T) {
+	t0 := time.Now().UTC().Truncate(time.Second)
+	version := func(name, id, content string, at int, latest bool) *interfaces.ObjectVersion {
+		return &interfaces.ObjectVersion{Name: name, VersionID: id, Size: int64(len(content)), ETag: "etag-" + id,
+			LastModified: t0.Add(time.Duration(at) * time.Minute), IsLatest: latest, DeleteMarker: content == ""}
+	}
+	current := func(v *interfaces.ObjectVersion) *interfaces.ObjectInfo {
+		return &interfaces.ObjectInfo{Name: v.Name, Size: v.Size, ETag: v.ETag, LastModified: v.LastModified}
+	}
+
+	doc := []*interfaces.ObjectVersion{
+		version("doc.txt", "d1", "one", 1, false),
+		version("doc.txt", "d2", "two", 2, false),
+		version("doc.txt", "d3", "", 3, false), // Deleted, then created again
+		version("doc.txt", "d4", "three", 4, true),
+	}
+	gone := []*interfaces.ObjectVersion{
+		version("gone.txt", "g1", "bye", 1, false),
+		version("gone.txt", "g2", "", 2, true),
+	}
+	source := &versionedSourceProvider{
+		fakeSourceProvider: &fakeSourceProvider{
+			objects: map[string]*interfaces.ObjectInfo{"doc.txt": current(doc[3])},
+			data:    map[string][]byte{"doc.txt": []byte("three")},
+		},
+		versions: map[string][]*interfaces.ObjectVersion{"doc.txt": doc, "gone.txt": gone},
+		content:  map[string][]byte{"d1": []byte("one"), "d2": []byte("two"), "g1": []byte("bye")},
+	}
+	target := &historyTargetProvider{
+		fakeTargetProvider: &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}},
+		events:             map[string][]string{},
+	}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", ReplicateVersions: true}
+	cfg := &config.Config{Mappings: []config.BucketMapping{mapping}}
+	syncer, db := newTestSynchronizer(t, cfg, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	runSync := func() {
+		t.Helper()
+		target.events = map[string][]string{}
+		if err := syncer.SyncBuckets(context.Background(), mapping, logger); err != nil {
+			t.Fatalf("SyncBuckets returned error: %v", err)
+		}
+	}
+
+	// The history is replayed oldest first and the current version is
+	// written last.
+	runSync()
+	if got := fmt.Sprint(target.events); got != "map[doc.txt:[put one put two delete put three] gone.txt:[put bye delete]]" {
+		t.Fatalf("unexpected target history: %s", got)
+	}
+	recorded, err := db.ListObjectVersions(mappingKey(mapping), "")
+	if err != nil || len(recorded) != 5 {
+		t.Fatalf("expected 5 recorded versions, got %d, %v", len(recorded), err)
+	}
+
+	runSync()
+	if len(target.events) != 0 {
+		t.Fatalf("expected nothing to replicate again, got %v", target.events)
+	}
+
+	// A version synchronized while it was current is not copied again.
+	doc[3].IsLatest = false
+	d5 := version("doc.txt", "d5", "four", 5, true)
+	source.versions["doc.txt"] = append(doc, d5)
+	source.objects["doc.txt"], source.data["doc.txt"] = current(d5), []byte("four")
+	runSync()
+	if got := fmt.Sprint(target.events); got != "map[doc.txt:[put four]]" {
+		t.Errorf("unexpected target history: %s", got)
+	}
+}
+
+func TestSyncBuckets_ReplicateVersionsUnsupported(t *testing.T) {
+	source := &fakeSourceProvider{objects: map[string]*interfaces.ObjectInfo{}}
+	target := &fakeTargetProvider{uploaded: map[string][]byte{}, objects: map[string]*interfaces.ObjectInfo{}}
+	mapping := config.BucketMapping{SourceProviderID: "src", SourceBucket: "sb", TargetProviderID: "dst", TargetBucket: "tb", ReplicateVersions: true}
+	syncer, _ := newTestSynchronizer(t, &config.Config{Mappings: []config.BucketMapping{mapping}}, map[string]interfaces.StorageProvider{"src": source, "dst": target})
+
+	if err := syncer.SyncBuckets(context.Background(), mapping, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+		t.Fatal("expected an error for a source without version support")
+	}
+}
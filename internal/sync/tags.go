@@ -0,0 +1,88 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// objectTags returns the tags to write an object with: its source tags when
+// the mapping replicates them, with the mapping's static tags replacing
+// those with the same key. Older versions get the static tags only, since
+// the tags of a version cannot be read. It returns nil when there are none.
+func (s *Synchronizer) objectTags(ctx context.Context, run *mappingRun, objName string, srcObjInfo *interfaces.ObjectInfo) (map[string]string, error) {
+	var source map[string]string
+	if run.mapping.ReplicateTags && srcObjInfo.VersionID == "" {
+		reader, ok := run.source.(interfaces.TagReader)
+		if !ok {
+			return nil, fmt.Errorf("source provider %s does not support object tags", run.mapping.SourceProviderID)
+		}
+		var err error
+		if source, err = reader.GetObjectTags(ctx, run.mapping.SourceBucket, objName); err != nil {
+			return nil, err
+		}
+	}
+
+	tags, dropped := mergeTags(source, run.mapping.Tags)
+	if len(dropped) > 0 {
+		run.logger.Warn("Object has more tags than a target accepts, dropping some source tags",
+			"object_name", objName, "max_tags", config.MaxObjectTags, "dropped", dropped)
+	}
+	return tags, nil
+}
+
+// tagsHash returns a hash of the tags an object is written with, so that a
+// change to them alone is noticed, or "" when there are none. It does not
+// depend on the order of the keys.
+func tagsHash(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		fmt.Fprintf(h, "%q=%q\n", k, tags[k])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// mergeTags adds the static tags to the source tags. When the result has more
+// than config.MaxObjectTags tags, source tags are dropped from the end of the
+// key order and returned.
+func mergeTags(source, static map[string]string) (map[string]string, []string) {
+	if len(source) == 0 && len(static) == 0 {
+		return nil, nil
+	}
+
+	tags := make(map[string]string, len(source)+len(static))
+	for k, v := range static {
+		tags[k] = v
+	}
+	var keys []string
+	for k := range source {
+		if _, ok := static[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var dropped []string
+	for _, k := range keys {
+		if len(tags) >= config.MaxObjectTags {
+			dropped = append(dropped, k)
+			continue
+		}
+		tags[k] = source[k]
+	}
+	return tags, dropped
+}
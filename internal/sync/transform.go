@@ -0,0 +1,277 @@
+package sync
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Transformer rewrites the content of objects between the source and the
+// targets. The transformers of a mapping are applied in the order listed.
+type Transformer interface {
+	// Key returns the target key of an object written through the
+	// transformer, e.g. with an extension added. It must not depend on the
+	// content, since it is also used to find the copies to delete.
+	Key(key string) string
+	// Transform returns the object to pass on in place of obj. A returned
+	// reader that is an io.Closer is closed once the object is uploaded.
+	Transform(obj TransformObject) (TransformObject, error)
+}
+
+// TransformObject is the content of an object on its way to the targets.
+type TransformObject struct {
+	Key     string // Source key as rewritten by the Key of the previous transformers
+	Reader  io.Reader
+	Size    int64                    // interfaces.UnknownSize when not known before the content is read
+	Options interfaces.UploadOptions // Headers and metadata the object is uploaded with
+}
+
+// TransformFactory creates a transformer from one step of a mapping's
+// transforms.
+type TransformFactory func(cfg config.TransformConfig) (Transformer, error)
+
+// WithTransform registers a transform type that mappings can list in their
+// transforms, replacing any built-in type of the same name.
+func WithTransform(typ string, factory TransformFactory) Option {
+	return func(s *Synchronizer) {
+		if s.transforms == nil {
+			s.transforms = make(map[string]TransformFactory)
+		}
+		s.transforms[typ] = factory
+	}
+}
+
+// newTransformers creates the transform pipeline of a mapping.
+func (s *Synchronizer) newTransformers(cfgs []config.TransformConfig) ([]Transformer, error) {
+	transformers := make([]Transformer, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		factory, ok := s.transforms[cfg.Type]
+		if !ok {
+			switch cfg.Type {
+			case config.TransformGzip, config.TransformZstd:
+				factory = newCompression
+			default:
+				return nil, fmt.Errorf("transform %d has unknown type: %s", i, cfg.Type)
+			}
+		}
+		t, err := factory(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error creating transform %d (%s): %w", i, cfg.Type, err)
+		}
+		transformers = append(transformers, t)
+	}
+	return transformers, nil
+}
+
+// transformedKey returns the target key of an object written through
+// transformers.
+func transformedKey(transformers []Transformer, key string) string {
+	for _, t := range transformers {
+		key = t.Key(key)
+	}
+	return key
+}
+
+// transformContent runs an object read from the source through the content
+// pipeline of its mapping: client-side decryption, the transformers in order
+// and client-side encryption. Content whose size is unknown by then is
+// spooled to disk first when the encryption or a pending target needs the
+// size. The returned cleanup, never nil, closes and removes whatever the
+// pipeline created.
+func (s *Synchronizer) transformContent(run *mappingRun, objName string, reader io.Reader, size int64, opts interfaces.UploadOptions, pending []pendingUpload) (TransformObject, func(), error) {
+	var closers []func()
+	cleanup := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	obj := TransformObject{Key: objName, Reader: reader, Size: size, Options: opts}
+	encrypting := run.keyring != nil && run.mapping.ClientEncryption.Mode == config.ClientEncryptionEncrypt
+
+	var err error
+	if !encrypting {
+		if obj.Reader, obj.Size, obj.Options.Metadata, err = clientEncryption(run, objName, obj.Reader, obj.Size, obj.Options.Metadata); err != nil {
+			return obj, cleanup, err
+		}
+	}
+
+	for _, t := range run.transformers {
+		if obj, err = t.Transform(obj); err != nil {
+			return obj, cleanup, fmt.Errorf("error transforming object: %w", err)
+		}
+		obj.Key = t.Key(obj.Key)
+		if closer, ok := obj.Reader.(io.Closer); ok {
+			closers = append(closers, func() { closer.Close() })
+		}
+	}
+
+	if obj.Size == interfaces.UnknownSize && (encrypting || requiresSize(pending)) {
+		run.logger.Debug("Spooling transformed object to disk to learn its size", "object_name", objName)
+		file, err := s.spool(obj.Reader)
+		if file != nil {
+			closers = append(closers, func() {
+				file.Close()
+				os.Remove(file.Name())
+			})
+		}
+		if err != nil {
+			return obj, cleanup, err
+		}
+		info, err := file.Stat()
+		if err != nil {
+			return obj, cleanup, fmt.Errorf("error reading size of spooled object: %w", err)
+		}
+		obj.Reader, obj.Size = file, info.Size()
+	}
+
+	if encrypting {
+		if obj.Reader, obj.Size, obj.Options.Metadata, err = clientEncryption(run, objName, obj.Reader, obj.Size, obj.Options.Metadata); err != nil {
+			return obj, cleanup, err
+		}
+	}
+	return obj, cleanup, nil
+}
+
+// requiresSize reports whether any pending target needs the size of the
+// content in advance.
+func requiresSize(pending []pendingUpload) bool {
+	for _, p := range pending {
+		if sized, ok := p.target.target.(interfaces.SizeRequirer); ok && sized.RequiresSize() {
+			return true
+		}
+	}
+	return false
+}
+
+// spool copies r to a temporary file in the configured spool directory and
+// returns the file, rewound. The file is returned on error too, so that the
+// caller can remove it.
+func (s *Synchronizer) spool(r io.Reader) (*os.File, error) {
+	file, err := os.CreateTemp(s.config.SpoolDir, "cloud-data-sync-spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("error creating spool file: %w", err)
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		return file, fmt.Errorf("error spooling object: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return file, fmt.Errorf("error rewinding spool file: %w", err)
+	}
+	return file, nil
+}
+
+// codec describes a built-in compression format.
+type codec struct {
+	ext         string // Key extension of compressed objects
+	contentType string
+	encoding    string // Content-Encoding of compressed content
+}
+
+var codecs = map[string]codec{
+	config.TransformGzip: {ext: ".gz", contentType: "application/gzip", encoding: "gzip"},
+	config.TransformZstd: {ext: ".zst", contentType: "application/zstd", encoding: "zstd"},
+}
+
+// compression is the built-in gzip and zstd transform. Compressed objects
+// get the codec's extension and content type; decompression removes them
+// again.
+type compression struct {
+	typ        string
+	codec      codec
+	decompress bool
+	level      int
+}
+
+func newCompression(cfg config.TransformConfig) (Transformer, error) {
+	c := &compression{
+		typ:        cfg.Type,
+		codec:      codecs[cfg.Type],
+		decompress: cfg.Mode == config.TransformDecompress,
+		level:      cfg.Level,
+	}
+	if c.level == 0 {
+		c.level = gzip.DefaultCompression
+		if c.typ == config.TransformZstd {
+			c.level = 3 // The zstd command line default
+		}
+	}
+	return c, nil
+}
+
+func (c *compression) Key(key string) string {
+	if c.decompress {
+		return strings.TrimSuffix(key, c.codec.ext)
+	}
+	return key + c.codec.ext
+}
+
+func (c *compression) Transform(obj TransformObject) (TransformObject, error) {
+	if c.decompress {
+		return c.decompressObject(obj)
+	}
+
+	pr, pw := io.Pipe()
+	src := obj.Reader
+	go func() {
+		w, err := c.newWriter(pw)
+		if err == nil {
+			_, err = io.Copy(w, src)
+			if closeErr := w.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err) // A nil error closes the pipe with io.EOF
+	}()
+
+	obj.Reader = pr
+	obj.Size = interfaces.UnknownSize
+	obj.Options.ContentType = c.codec.contentType
+	obj.Options.ContentEncoding = ""
+	return obj, nil
+}
+
+func (c *compression) newWriter(w io.Writer) (io.WriteCloser, error) {
+	if c.typ == config.TransformZstd {
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level)), zstd.WithEncoderConcurrency(1))
+	}
+	return gzip.NewWriterLevel(w, c.level)
+}
+
+// decompressObject decompresses obj. The content type of the decompressed
+// object is guessed from its key when it was the codec's own.
+func (c *compression) decompressObject(obj TransformObject) (TransformObject, error) {
+	if c.typ == config.TransformZstd {
+		decoder, err := zstd.NewReader(obj.Reader, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return obj, err
+		}
+		obj.Reader = decoder.IOReadCloser()
+	} else {
+		reader, err := gzip.NewReader(obj.Reader)
+		if err != nil {
+			return obj, err
+		}
+		obj.Reader = reader
+	}
+
+	obj.Size = interfaces.UnknownSize
+	if obj.Options.ContentEncoding == c.codec.encoding {
+		obj.Options.ContentEncoding = ""
+	}
+	if contentType, _, _ := mime.ParseMediaType(obj.Options.ContentType); contentType == c.codec.contentType || contentType == "application/x-"+c.typ {
+		obj.Options.ContentType = mime.TypeByExtension(path.Ext(c.Key(obj.Key)))
+		if obj.Options.ContentType == "" {
+			obj.Options.ContentType = "application/octet-stream"
+		}
+	}
+	return obj, nil
+}
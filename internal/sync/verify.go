@@ -0,0 +1,77 @@
+package sync
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+)
+
+// ErrChecksumMismatch is returned when the content stored on the target does
+// not match the content read from the source.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumReader computes the MD5 and CRC32C of the bytes read through it, so
+// an upload can be verified without reading the object twice.
+type checksumReader struct {
+	r      io.Reader
+	md5    hash.Hash
+	crc32c hash.Hash32
+	n      int64
+}
+
+func newChecksumReader(r io.Reader) *checksumReader {
+	return &checksumReader{r: r, md5: md5.New(), crc32c: crc32.New(crc32cTable)}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.Write(p[:n])
+	return n, err
+}
+
+// Write adds p to the checksums, which lets content assembled from several
+// streams be hashed as one.
+func (c *checksumReader) Write(p []byte) (int, error) {
+	c.md5.Write(p)
+	c.crc32c.Write(p)
+	c.n += int64(len(p))
+	return len(p), nil
+}
+
+// Checksum returns the checksum recorded in the database for the content read
+// so far.
+func (c *checksumReader) Checksum() string {
+	return "md5:" + hex.EncodeToString(c.md5.Sum(nil))
+}
+
+// verify compares the content read so far with the checksums the target
+// reported for the upload. It reports false when the target did not report a
+// checksum the content can be compared with.
+func (c *checksumReader) verify(info *interfaces.UploadInfo, size int64) (bool, error) {
+	if size != interfaces.UnknownSize && c.n != size {
+		return false, fmt.Errorf("%w: streamed %d bytes, expected %d", ErrChecksumMismatch, c.n, size)
+	}
+	if info == nil || (info.ContentMD5 == "" && info.CRC32C == "") {
+		return false, nil
+	}
+
+	if info.ContentMD5 != "" {
+		if want := hex.EncodeToString(c.md5.Sum(nil)); info.ContentMD5 != want {
+			return false, fmt.Errorf("%w: target reported MD5 %s, source content has %s", ErrChecksumMismatch, info.ContentMD5, want)
+		}
+	}
+	if info.CRC32C != "" {
+		if want := hex.EncodeToString(c.crc32c.Sum(nil)); info.CRC32C != want {
+			return false, fmt.Errorf("%w: target reported CRC32C %s, source content has %s", ErrChecksumMismatch, info.CRC32C, want)
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,296 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/DjonatanS/cloud-data-sync/internal/config"
+	"github.com/DjonatanS/cloud-data-sync/internal/database"
+	"github.com/DjonatanS/cloud-data-sync/internal/interfaces"
+	"golang.org/x/sync/errgroup"
+)
+
+// versionTarget is the state of one target while the versions of an object
+// are replicated to it.
+type versionTarget struct {
+	target    *targetRun
+	targetKey string
+	done      map[string]bool        // Versions handled by earlier runs
+	stored    *database.FileMetadata // Metadata of the last sync of the current object, nil if none
+	wrote     bool                   // A version was written or deleted by this run
+	failed    bool                   // A version failed; later ones wait for the next run to keep the order
+	logger    *slog.Logger
+}
+
+// syncVersions replicates the older versions of the mapping's objects that
+// the targets do not have yet, before the current objects are synchronized.
+// The versions of an object are applied oldest first: content versions are
+// uploaded to the object's target key and delete markers delete it, so that
+// a versioned target ends up with the same history. The current version is
+// left to the object phase, which writes it again after any older version.
+func (s *Synchronizer) syncVersions(ctx context.Context, run *mappingRun, counters *syncCounters) error {
+	lister, ok := run.source.(interfaces.VersionLister)
+	if !ok {
+		return fmt.Errorf("source provider %s does not support object versions", run.mapping.SourceProviderID)
+	}
+
+	run.logger.Debug("Listing object versions from source bucket", "prefix", run.mapping.SourcePrefix)
+	listed, err := lister.ListObjectVersions(ctx, run.mapping.SourceBucket, run.mapping.SourcePrefix)
+	if err != nil {
+		run.logger.Error("Failed to list object versions from source bucket", "error", err)
+		return fmt.Errorf("error listing object versions from source bucket %s: %w", run.mapping.SourceBucket, err)
+	}
+
+	// Objects with nothing but a current version are left to the object phase.
+	var names []string
+	for name, versions := range listed {
+		if run.matcher.Match(name) && (len(versions) > 1 || versions[0].DeleteMarker) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	done := make([]map[string]map[string]bool, len(run.targets))
+	for i, target := range run.targets {
+		recorded, err := s.db.ListObjectVersions(target.mappingID, "")
+		if err != nil {
+			return fmt.Errorf("error loading replicated versions: %w", err)
+		}
+		done[i] = make(map[string]map[string]bool)
+		for _, v := range recorded {
+			if done[i][v.ObjectName] == nil {
+				done[i][v.ObjectName] = make(map[string]bool)
+			}
+			done[i][v.ObjectName][v.VersionID] = true
+		}
+	}
+
+	rewritten := make([][]bool, len(names))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(mappingConcurrency(run.mapping))
+
+	for i, name := range names {
+		if gctx.Err() != nil {
+			break
+		}
+
+		g.Go(func() error {
+			if err := s.acquireSlot(gctx); err != nil {
+				return err
+			}
+			defer s.releaseSlot()
+
+			targets := make([]*versionTarget, len(run.targets))
+			for t, target := range run.targets {
+				targets[t] = s.newVersionTarget(target, name, listed[name], done[t][name])
+			}
+			s.syncObjectVersions(gctx, run, name, listed[name], targets, counters)
+
+			rewritten[i] = make([]bool, len(run.targets))
+			for t, vt := range targets {
+				rewritten[i][t] = vt != nil && vt.wrote
+			}
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		run.logger.Error("Version replication phase interrupted", "error", err)
+		return fmt.Errorf("error replicating object versions for mapping %s: %w", run.mappingID, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	for i, name := range names {
+		for t, wrote := range rewritten[i] {
+			if wrote {
+				run.targets[t].rewrite[name] = true
+			}
+		}
+	}
+
+	run.logger.Info("Version replication phase complete", "objects_with_versions", len(names))
+	return nil
+}
+
+// newVersionTarget prepares the replication of the versions of an object to
+// a target. Every version is written to the key of the current object, or
+// of the newest version when the object was deleted. It returns nil when the
+// key cannot be computed or is another source object's.
+func (s *Synchronizer) newVersionTarget(target *targetRun, name string, versions []*interfaces.ObjectVersion, done map[string]bool) *versionTarget {
+	logger := target.logger.With("object_name", name)
+
+	targetKey, ok := target.targetKeys[name]
+	if !ok {
+		modified := versions[len(versions)-1].LastModified
+		for _, v := range versions {
+			if !v.DeleteMarker {
+				modified = v.LastModified
+			}
+		}
+		var err error
+		if targetKey, err = target.keys.TargetKey(name, modified); err != nil {
+			logger.Error("Failed to compute target key, skipping object versions", "error", err)
+			return nil
+		}
+		targetKey = transformedKey(target.transformers, targetKey)
+		if _, taken := target.sourceKeys[targetKey]; taken || target.collisions[targetKey] != nil {
+			logger.Error("Deleted object maps to the target key of another source object, skipping its versions", "target_key", targetKey)
+			return nil
+		}
+	}
+
+	stored, err := s.db.GetFileMetadata(target.mappingID, name)
+	if err != nil {
+		logger.Warn("Error fetching metadata from DB, replicating versions as if the object was never synced", "error", err)
+	}
+	return &versionTarget{target: target, targetKey: targetKey, done: done, stored: stored, logger: logger}
+}
+
+// syncObjectVersions applies the versions of one object the targets are
+// missing, in order, adding what it does to counters. Targets are nil when
+// the object has no target key on them.
+func (s *Synchronizer) syncObjectVersions(ctx context.Context, run *mappingRun, name string, versions []*interfaces.ObjectVersion, targets []*versionTarget, counters *syncCounters) {
+	for _, v := range versions {
+		if v.IsLatest && !v.DeleteMarker {
+			break // The current version is synchronized by the object phase
+		}
+
+		var missing []*versionTarget
+		for _, vt := range targets {
+			if vt != nil && !vt.failed && !vt.done[v.VersionID] {
+				missing = append(missing, vt)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+
+		if v.DeleteMarker {
+			for _, vt := range missing {
+				s.applyDeleteMarker(ctx, vt, name, v, counters)
+			}
+			continue
+		}
+		s.copyVersion(ctx, run, name, v, missing, counters)
+	}
+}
+
+// copyVersion uploads a content version to the targets missing it. A target
+// whose last synchronized object is this very version already has it.
+func (s *Synchronizer) copyVersion(ctx context.Context, run *mappingRun, name string, v *interfaces.ObjectVersion, targets []*versionTarget, counters *syncCounters) {
+	byTarget := make(map[*targetRun]*versionTarget, len(targets))
+	var pending []pendingUpload
+	for _, vt := range targets {
+		if !vt.wrote && syncedAsCurrent(vt.stored, v, vt.targetKey) {
+			vt.logger.Debug("Version was synchronized while it was current", "version_id", v.VersionID)
+			s.recordVersion(vt, name, v, database.VersionStatusReplicated)
+			continue
+		}
+		byTarget[vt.target] = vt
+		pending = append(pending, pendingUpload{target: vt.target, targetKey: vt.targetKey, logger: vt.logger.With("version_id", v.VersionID)})
+	}
+	if len(pending) == 0 {
+		return
+	}
+
+	srcObjInfo := &interfaces.ObjectInfo{
+		Name:         name,
+		Bucket:       run.mapping.SourceBucket,
+		Size:         v.Size,
+		ContentType:  v.ContentType,
+		LastModified: v.LastModified,
+		ETag:         v.ETag,
+		VersionID:    v.VersionID,
+	}
+	var results []transferResult
+	if tags, err := s.objectTags(ctx, run, name, srcObjInfo); err != nil {
+		results = failedTransfers(pending, err)
+	} else {
+		results = s.transferWithRetry(ctx, run, name, srcObjInfo, tags, pending)
+	}
+	for _, result := range results {
+		vt, p := byTarget[result.pending.target], result.pending
+		switch {
+		case result.status == statusSuccess:
+			p.logger.Info("Object version replicated", "target_key", p.targetKey, "verified", result.verified)
+			s.recordVersion(vt, name, v, database.VersionStatusReplicated)
+			vt.wrote = true
+			counters.synced.Add(1)
+			counters.bytes.Add(v.Size)
+			s.afterUpload(ctx, run, name, srcObjInfo, result)
+		case result.status == statusFailedGet && errors.Is(result.err, interfaces.ErrNotFound):
+			// Typically expired by a lifecycle rule; it will not be listed again.
+			p.logger.Info("Object version was removed from the source during the run, skipping", "error", result.err)
+		default:
+			p.logger.Error("Error replicating object version, later versions wait for the next run", "status", result.status, "error", result.err)
+			vt.failed = true
+			counters.errors.Add(1)
+		}
+	}
+}
+
+// syncedAsCurrent reports whether the stored metadata shows that version v
+// was copied to targetKey while it was the current version of the object.
+func syncedAsCurrent(stored *database.FileMetadata, v *interfaces.ObjectVersion, targetKey string) bool {
+	return stored != nil && stored.SyncStatus == statusSuccess && stored.TargetKey == targetKey &&
+		trimETag(stored.ETag) == trimETag(v.ETag) && stored.Size == v.Size
+}
+
+// applyDeleteMarker replicates a delete marker by deleting the object from
+// the target, which leaves a delete marker there too when the target bucket
+// is versioned. Only the mirror delete policy deletes, and only objects the
+// mapping owns. A current delete marker is left to the deletion phase unless
+// this run wrote the object after the phase's target listing.
+func (s *Synchronizer) applyDeleteMarker(ctx context.Context, vt *versionTarget, name string, v *interfaces.ObjectVersion, counters *syncCounters) {
+	logger := vt.logger.With("version_id", v.VersionID)
+	_, listed := vt.target.targetObjects[vt.targetKey]
+	if v.IsLatest && (!vt.wrote || listed) {
+		return
+	}
+
+	owned := vt.wrote || vt.target.mapping.AdoptExisting ||
+		(vt.stored != nil && vt.stored.Owned && vt.stored.TargetKey == vt.targetKey)
+	if deletePolicy(vt.target.mapping) != config.DeletePolicyMirror || !owned {
+		logger.Debug("Delete marker not applied to the target", "delete_policy", deletePolicy(vt.target.mapping), "owned", owned)
+		s.recordVersion(vt, name, v, database.VersionStatusSkipped)
+		return
+	}
+
+	err := vt.target.target.DeleteObject(ctx, vt.target.mapping.TargetBucket, vt.targetKey)
+	if err != nil && !errors.Is(err, interfaces.ErrNotFound) {
+		logger.Error("Error replicating delete marker, later versions wait for the next run", "target_key", vt.targetKey, "error", err)
+		vt.failed = true
+		counters.errors.Add(1)
+		return
+	}
+
+	logger.Info("Delete marker replicated", "target_key", vt.targetKey)
+	s.recordVersion(vt, name, v, database.VersionStatusReplicated)
+	vt.wrote = true
+	counters.deleted.Add(1)
+}
+
+// recordVersion records that a version was handled for a target.
+func (s *Synchronizer) recordVersion(vt *versionTarget, name string, v *interfaces.ObjectVersion, status string) {
+	err := s.db.RecordObjectVersion(&database.ObjectVersion{
+		MappingID:    vt.target.mappingID,
+		ObjectName:   name,
+		VersionID:    v.VersionID,
+		DeleteMarker: v.DeleteMarker,
+		Size:         v.Size,
+		ETag:         v.ETag,
+		LastModified: v.LastModified,
+		TargetKey:    vt.targetKey,
+		Status:       status,
+		SyncedAt:     time.Now().UTC(),
+	})
+	if err != nil {
+		vt.logger.Error("Error recording object version in DB", "version_id", v.VersionID, "error", err)
+	}
+}
@@ -0,0 +1,163 @@
+// Package throttle limits the rate at which object content is transferred.
+package throttle
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// chunkSize is the largest read a throttled reader makes at once. Small reads
+// let concurrent transfers sharing a Limiter take turns.
+const chunkSize = 32 << 10
+
+// Window sets the transfer rate during part of each day.
+type Window struct {
+	Start             string `json:"start"`             // Local time "HH:MM" the window starts at
+	End               string `json:"end"`               // Local time "HH:MM" the window ends at; before start for windows spanning midnight
+	MaxBytesPerSecond int64  `json:"maxBytesPerSecond"` // Rate during the window (0 = unlimited)
+}
+
+// window is a parsed Window, with times as offsets from midnight.
+type window struct {
+	start, end time.Duration
+	rate       int64
+}
+
+func (w window) contains(clock time.Duration) bool {
+	if w.start < w.end {
+		return clock >= w.start && clock < w.end
+	}
+	return clock >= w.start || clock < w.end
+}
+
+// Limiter caps the combined rate of every reader wrapped with it. A nil
+// Limiter does not limit anything.
+type Limiter struct {
+	rate    int64 // Rate outside the windows (0 = unlimited)
+	windows []window
+	limiter *rate.Limiter
+}
+
+// New returns a Limiter allowing maxBytesPerSecond, or the rate of the first
+// window containing the current local time. It returns nil when no rate is
+// ever limited.
+func New(maxBytesPerSecond int64, windows []Window) (*Limiter, error) {
+	if maxBytesPerSecond < 0 {
+		return nil, fmt.Errorf("maxBytesPerSecond must not be negative: %d", maxBytesPerSecond)
+	}
+
+	l := &Limiter{rate: maxBytesPerSecond}
+	limited := maxBytesPerSecond > 0
+	for i, w := range windows {
+		start, err := parseClock(w.Start)
+		if err != nil {
+			return nil, fmt.Errorf("window %d has invalid start: %w", i, err)
+		}
+		end, err := parseClock(w.End)
+		if err != nil {
+			return nil, fmt.Errorf("window %d has invalid end: %w", i, err)
+		}
+		if start == end {
+			return nil, fmt.Errorf("window %d starts and ends at %s", i, w.Start)
+		}
+		if w.MaxBytesPerSecond < 0 {
+			return nil, fmt.Errorf("window %d has negative maxBytesPerSecond: %d", i, w.MaxBytesPerSecond)
+		}
+		l.windows = append(l.windows, window{start: start, end: end, rate: w.MaxBytesPerSecond})
+		limited = limited || w.MaxBytesPerSecond > 0
+	}
+	if !limited {
+		return nil, nil
+	}
+
+	l.limiter = rate.NewLimiter(rate.Inf, chunkSize)
+	return l, nil
+}
+
+// parseClock parses "HH:MM" into the time elapsed since midnight.
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("time of day must be HH:MM: %q", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// LimitAt returns the rate in bytes per second in effect at t (0 = unlimited).
+func (l *Limiter) LimitAt(t time.Time) int64 {
+	if l == nil {
+		return 0
+	}
+
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	for _, w := range l.windows {
+		if w.contains(clock) {
+			return w.rate
+		}
+	}
+	return l.rate
+}
+
+// wait blocks until n more bytes may be transferred under the current rate.
+func (l *Limiter) wait(ctx context.Context, n int) error {
+	limit := l.LimitAt(time.Now())
+	if limit == 0 {
+		l.limiter.SetLimit(rate.Inf)
+		return nil
+	}
+
+	if rate.Limit(limit) != l.limiter.Limit() {
+		l.limiter.SetLimit(rate.Limit(limit))
+	}
+
+	// The bucket holds a single chunk, so idle time does not build up into a
+	// burst.
+	for n > 0 {
+		take := min(n, chunkSize)
+		if err := l.limiter.WaitN(ctx, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
+
+// NewReader returns a reader that reads from r no faster than every one of
+// limiters allows. Nil limiters are ignored, and r is returned as is when all
+// of them are nil.
+func NewReader(ctx context.Context, r io.Reader, limiters ...*Limiter) io.Reader {
+	var active []*Limiter
+	for _, l := range limiters {
+		if l != nil {
+			active = append(active, l)
+		}
+	}
+	if len(active) == 0 {
+		return r
+	}
+	return &reader{ctx: ctx, r: r, limiters: active}
+}
+
+type reader struct {
+	ctx      context.Context
+	r        io.Reader
+	limiters []*Limiter
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) > chunkSize {
+		p = p[:chunkSize]
+	}
+
+	n, err := r.r.Read(p)
+	for _, l := range r.limiters {
+		if werr := l.wait(r.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,101 @@
+package throttle
+
+import (
+	"bytes"
+	"context"
+	"io"
+	gosync "sync"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name        string
+		rate        int64
+		windows     []Window
+		wantLimiter bool
+		wantErr     bool
+	}{
+		{"unlimited", 0, nil, false, false},
+		{"global rate", 1 << 20, nil, true, false},
+		{"window only", 0, []Window{{Start: "08:00", End: "18:00", MaxBytesPerSecond: 1 << 20}}, true, false},
+		{"unlimited window", 0, []Window{{Start: "08:00", End: "18:00"}}, false, false},
+		{"negative rate", -1, nil, false, true},
+		{"invalid clock", 0, []Window{{Start: "8am", End: "18:00", MaxBytesPerSecond: 1}}, false, true},
+		{"empty window", 0, []Window{{Start: "08:00", End: "08:00", MaxBytesPerSecond: 1}}, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := New(tt.rate, tt.windows)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (l != nil) != tt.wantLimiter {
+				t.Errorf("New() limiter = %v, want limiter %v", l, tt.wantLimiter)
+			}
+		})
+	}
+}
+
+func TestLimiter_LimitAt(t *testing.T) {
+	l, err := New(100, []Window{
+		{Start: "08:00", End: "18:00", MaxBytesPerSecond: 10},
+		{Start: "22:00", End: "06:00"}, // Unlimited overnight
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	day := time.Date(2025, 5, 1, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		clock time.Duration
+		want  int64
+	}{
+		{8 * time.Hour, 10},
+		{17*time.Hour + 59*time.Minute, 10},
+		{18 * time.Hour, 100},
+		{23 * time.Hour, 0},
+		{3 * time.Hour, 0},
+		{6 * time.Hour, 100},
+	}
+	for _, tt := range tests {
+		if got := l.LimitAt(day.Add(tt.clock)); got != tt.want {
+			t.Errorf("LimitAt(%s) = %d, want %d", tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestNewReader_SharesRateAcrossReaders(t *testing.T) {
+	// The bucket starts with one chunk; the second chunk has to wait for the
+	// rate whichever reader asks for it.
+	l, err := New(4*chunkSize, nil)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	start := time.Now()
+	var wg gosync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := io.Copy(io.Discard, NewReader(context.Background(), bytes.NewReader(make([]byte, chunkSize)), l))
+			if err != nil || n != chunkSize {
+				t.Errorf("Copy returned %d, %v", n, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+		t.Errorf("expected the readers to share the rate, finished in %s", elapsed)
+	}
+}
+
+func TestNewReader_WithoutLimits(t *testing.T) {
+	r := bytes.NewReader(nil)
+	if got := NewReader(context.Background(), r, nil, nil); got != r {
+		t.Errorf("expected the reader to be returned unwrapped, got %T", got)
+	}
+}